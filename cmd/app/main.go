@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"telegram-doctor-recipe-helper-bot/internal/app/config"
 	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	adminController "telegram-doctor-recipe-helper-bot/internal/modules/admin/controller"
+	adminRouter "telegram-doctor-recipe-helper-bot/internal/modules/admin/router"
+	adminUsecase "telegram-doctor-recipe-helper-bot/internal/modules/admin/usecase"
 	"telegram-doctor-recipe-helper-bot/internal/modules/bot/controller"
 	"telegram-doctor-recipe-helper-bot/internal/modules/bot/router"
 	"telegram-doctor-recipe-helper-bot/internal/modules/bot/usecase"
+	queueController "telegram-doctor-recipe-helper-bot/internal/modules/queue/controller"
+	queueRouter "telegram-doctor-recipe-helper-bot/internal/modules/queue/router"
+	queueUsecase "telegram-doctor-recipe-helper-bot/internal/modules/queue/usecase"
+	"time"
 )
 
 func main() {
@@ -15,18 +23,214 @@ func main() {
 
 	app := config.NewFiber(cfg)
 
+	sinks, sheetJournal := newPrescriptionSinks(cfg)
+	log.Printf("Prescriptions are recorded to: %v", sinks.Names())
 
-	sheetService, err := utils.NewSheetService("bot-credentials.json", cfg.SheetID)
-    if err != nil {
-        log.Fatalf("Failed to create sheet service: %v", err)
-    }
-	messageUseCase := usecase.NewMessageUseCase(sheetService)
-	botController := controller.NewBotController(messageUseCase)
+	stateStore, err := utils.NewStateStore(cfg.StateStore, cfg.StateFilePath)
+	if err != nil {
+		log.Fatalf("Failed to create state store: %v", err)
+	}
+	queueCounter, err := utils.NewFileQueueCounter(cfg.QueueFilePath)
+	if err != nil {
+		log.Fatalf("Failed to create queue counter: %v", err)
+	}
+	seenMessages, err := utils.NewSeenSet(cfg.DedupFilePath, time.Duration(cfg.DedupTTLMinutes)*time.Minute, cfg.DedupMaxEntries)
+	if err != nil {
+		log.Fatalf("Failed to create processed message set: %v", err)
+	}
+	contacts, err := utils.NewContactRegistry(cfg.ContactsFilePath)
+	if err != nil {
+		log.Fatalf("Failed to create contact registry: %v", err)
+	}
+	seedContacts(cfg, contacts)
 
-	router.Route(app, botController)
+	gateway := utils.NewGowaGateway(cfg.WhatsAppAPIURL, cfg.GowaAdmin, cfg.GowaPassword, time.Duration(cfg.GatewayTimeoutSeconds)*time.Second)
+	outbox, err := utils.NewOutbox(cfg.OutboxFilePath, gateway, cfg.OutboxMaxAttempts, time.Duration(cfg.OutboxBackoffSeconds)*time.Second, time.Duration(cfg.OutboxMaxBackoffSecs)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to create outbox: %v", err)
+	}
+	go outbox.Run(context.Background(), time.Second)
+
+	prescriptions, err := utils.NewPrescriptionStore(cfg.PrescriptionsFilePath, time.Duration(cfg.PrescriptionRetentionDays)*24*time.Hour)
+	if err != nil {
+		log.Fatalf("Failed to create prescription store: %v", err)
+	}
+	// Remember the WhatsApp ID of each order message so the pharmacist can reply to it
+	outbox.OnDelivered(prescriptions.LinkOrderMessage)
+
+	// Every bot reply comes from the template files, a broken template stops the start
+	templates, err := utils.LoadMessageTemplates(cfg.TemplatesDir, cfg.DefaultLocale, usecase.MessageTemplateSamples())
+	if err != nil {
+		log.Fatalf("Failed to load message templates: %v", err)
+	}
+	locales, err := utils.NewLocaleStore(cfg.LocalesFilePath)
+	if err != nil {
+		log.Fatalf("Failed to create locale store: %v", err)
+	}
+
+	// Without a formulary the medication names are not checked and no stock is tracked
+	var formulary *utils.Formulary
+	var inventory *utils.Inventory
+	if cfg.FormularyPath != "" {
+		formulary, err = utils.LoadFormulary(cfg.FormularyPath)
+		if err != nil {
+			log.Fatalf("Failed to load formulary: %v", err)
+		}
+		log.Printf("Loaded %d drugs from formulary %s", len(formulary.Entries()), cfg.FormularyPath)
+
+		inventory, err = utils.NewInventory(cfg.InventoryFilePath, formulary, cfg.LowStockThreshold)
+		if err != nil {
+			log.Fatalf("Failed to create inventory: %v", err)
+		}
+		// Stock goes down when the patient picks up the prescription
+		prescriptions.OnChange(inventory.Dispense)
+	}
+
+	// Without an interaction table only duplicate ingredients are flagged
+	var interactions *utils.InteractionTable
+	if cfg.InteractionsPath != "" {
+		interactions, err = utils.LoadInteractions(cfg.InteractionsPath)
+		if err != nil {
+			log.Fatalf("Failed to load interactions: %v", err)
+		}
+		log.Printf("Loaded %d interactions from %s", interactions.Len(), cfg.InteractionsPath)
+	}
+
+	patients, err := utils.NewPatientRegistry(cfg.PatientsFilePath)
+	if err != nil {
+		log.Fatalf("Failed to create patient registry: %v", err)
+	}
+	// The hospital's patient master data wins over what doctors typed before
+	if cfg.PatientsImportPath != "" {
+		imported, err := patients.Import(cfg.PatientsImportPath)
+		if err != nil {
+			log.Fatalf("Failed to import patients: %v", err)
+		}
+		log.Printf("Imported %d patients from %s", imported, cfg.PatientsImportPath)
+	}
+	// Patients of earlier prescriptions are known too, and every new prescription adds its patient
+	for _, prescription := range prescriptions.List(nil) {
+		if err := patients.Remember(&prescription.Details); err != nil {
+			log.Fatalf("Failed to remember patients of earlier prescriptions: %v", err)
+		}
+	}
+	prescriptions.OnChange(patients.RememberPrescription)
+
+	patientLimiter := utils.NewRateLimiter(cfg.PatientQueryLimit, time.Duration(cfg.PatientQueryWindowMinutes)*time.Minute)
+
+	messageUseCase := usecase.NewMessageUseCase(cfg, sinks, stateStore, queueCounter, seenMessages, outbox, contacts, prescriptions, patientLimiter, templates, locales, formulary, inventory, interactions, patients)
+	botController := controller.NewBotController(messageUseCase, cfg)
+	// The doctor hears about an order the pharmacy never got
+	outbox.OnDeadLetter(messageUseCase.ReportFailedOrder)
+
+	// Pickup reminders are optional, READY_REMINDER_MINUTES=0 turns them off
+	if cfg.ReadyReminderMinutes > 0 {
+		reminderUseCase := usecase.NewReminderUseCase(prescriptions, outbox, templates, locales, time.Duration(cfg.ReadyReminderMinutes)*time.Minute)
+		go reminderUseCase.Run(context.Background(), time.Minute)
+	}
+
+	boardUseCase := queueUsecase.NewBoardUseCase(prescriptions)
+	boardController := queueController.NewBoardController(boardUseCase)
+
+	adminUseCase := adminUsecase.NewAdminUseCase(outbox, sheetJournal, prescriptions, inventory)
+	adminController := adminController.NewAdminController(adminUseCase)
+
+	// Without a secret anybody who finds the webhook URL can send messages as a doctor
+	if cfg.WebhookSecret == "" {
+		if !cfg.WebhookInsecure {
+			log.Fatal("WHATSAPP_WEBHOOK_SECRET is empty, set it or set WEBHOOK_INSECURE=true to accept unsigned webhooks")
+		}
+		log.Println("WHATSAPP_WEBHOOK_SECRET is empty and WEBHOOK_INSECURE=true, webhook signatures will not be verified")
+	}
+
+	router.Route(app, botController, cfg.AdminAPIKey)
+	adminRouter.Route(app, adminController, cfg.AdminAPIKey)
+	queueRouter.Route(app, boardController)
 
 	// Start server
 	port := cfg.AppPort
 	log.Printf("🚀 Bot server starting on port %s", port)
 	log.Fatal(app.Listen(fmt.Sprintf(":%s", port)))
 }
+
+// newPrescriptionSinks creates every prescription sink switched on in the config.
+// The sheet journal is returned as well because the admin API inspects it, it is nil when Google Sheets is off.
+func newPrescriptionSinks(cfg *config.Config) (*utils.MultiSink, *utils.SheetJournal) {
+	var sinks []utils.PrescriptionSink
+	var sheetJournal *utils.SheetJournal
+
+	if cfg.SinkSheets {
+		sheetService, err := utils.NewSheetService("bot-credentials.json", cfg.SheetID)
+		if err != nil {
+			log.Fatalf("Failed to create sheet service: %v", err)
+		}
+		sheetJournal, err = utils.NewSheetJournal(cfg.SheetJournalPath, sheetService, time.Duration(cfg.OutboxBackoffSeconds)*time.Second, time.Duration(cfg.OutboxMaxBackoffSecs)*time.Second)
+		if err != nil {
+			log.Fatalf("Failed to create sheet journal: %v", err)
+		}
+		go sheetJournal.Run(context.Background(), time.Minute)
+		sinks = append(sinks, sheetJournal)
+	}
+
+	if cfg.SinkExcel {
+		excelLedger, err := utils.NewExcelLedger(cfg.ExcelOutputPath)
+		if err != nil {
+			log.Fatalf("Failed to create Excel ledger: %v", err)
+		}
+		sinks = append(sinks, excelLedger)
+	}
+
+	if cfg.SinkCSV {
+		csvSink, err := utils.NewCSVSink(cfg.CSVOutputPath)
+		if err != nil {
+			log.Fatalf("Failed to create CSV sink: %v", err)
+		}
+		sinks = append(sinks, csvSink)
+	}
+
+	if cfg.SinkSQL {
+		sqlSink, err := utils.NewSQLSink(cfg.SQLDSN)
+		if err != nil {
+			log.Fatalf("Failed to create SQL sink: %v", err)
+		}
+		sinks = append(sinks, sqlSink)
+	}
+
+	return utils.NewMultiSink(sinks...), sheetJournal
+}
+
+// seedContacts registers the numbers from the environment on first start.
+// After that the contact registry is managed with the admin chat commands.
+func seedContacts(cfg *config.Config, contacts *utils.ContactRegistry) {
+	seeds := []utils.Contact{
+		{PhoneNumber: cfg.AllowedNumber, Name: "Dokter", Role: utils.RoleDoctor},
+		{PhoneNumber: cfg.NewDoctor, Name: "Dokter Baru", Role: utils.RoleDoctor},
+		{PhoneNumber: cfg.PharmacyNumber, Name: "Apoteker", Role: utils.RolePharmacist},
+		{PhoneNumber: cfg.AdminNumber, Name: "Admin", Role: utils.RoleAdmin},
+	}
+
+	// One number cannot have two roles, and seeding keeps whichever comes first,
+	// so a number set for different roles is left for the admin to register
+	roles := make(map[string]string)
+	collides := make(map[string]bool)
+	for _, seed := range seeds {
+		if seed.PhoneNumber == "" || seed.PhoneNumber == "-" {
+			continue
+		}
+		key := utils.NormalizePhone(seed.PhoneNumber)
+		if role, ok := roles[key]; ok && role != seed.Role {
+			collides[key] = true
+		}
+		roles[key] = seed.Role
+	}
+
+	for _, seed := range seeds {
+		if collides[utils.NormalizePhone(seed.PhoneNumber)] {
+			log.Printf("Not seeding %s as %s, the number is set for more than one role in ALLOWED_NUMBER, NEW_DOCTOR, PHARMACY_NUMBER and ADMIN_NUMBER", seed.PhoneNumber, seed.Role)
+			continue
+		}
+		if err := contacts.Seed(seed.PhoneNumber, seed.Name, seed.Role); err != nil {
+			log.Fatalf("Failed to seed contact %s: %v", seed.PhoneNumber, err)
+		}
+	}
+}
@@ -9,16 +9,53 @@ import (
 )
 
 type Config struct {
-	AppPort         string
-	WhatsAppAPIURL  string
-	AllowedNumber   string
-	ExcelOutputPath string
-	GowaAdmin       string
-	GowaPassword    string
-	SheetLink       string
-	PharmacyNumber  string
-	SheetID         string
-	NewDoctor       string
+	AppPort                   string
+	WhatsAppAPIURL            string
+	AllowedNumber             string
+	ExcelOutputPath           string
+	GowaAdmin                 string
+	GowaPassword              string
+	SheetLink                 string
+	PharmacyNumber            string
+	SheetID                   string
+	NewDoctor                 string
+	WebhookSecret             string
+	WebhookInsecure           bool // Accept unsigned webhooks when WebhookSecret is empty, for local development only
+	StateStore                string
+	StateFilePath             string
+	QueueFilePath             string
+	DedupFilePath             string
+	DedupTTLMinutes           int
+	DedupMaxEntries           int
+	GatewayTimeoutSeconds     int
+	OutboxFilePath            string
+	OutboxMaxAttempts         int
+	OutboxBackoffSeconds      int
+	OutboxMaxBackoffSecs      int
+	AdminAPIKey               string
+	SheetJournalPath          string
+	SinkSheets                bool
+	SinkExcel                 bool
+	SinkCSV                   bool
+	SinkSQL                   bool
+	CSVOutputPath             string
+	SQLDSN                    string
+	ContactsFilePath          string
+	AdminNumber               string
+	PrescriptionsFilePath     string
+	PrescriptionRetentionDays int
+	ReadyReminderMinutes      int
+	PatientQueryLimit         int
+	PatientQueryWindowMinutes int
+	TemplatesDir              string
+	DefaultLocale             string
+	LocalesFilePath           string
+	FormularyPath             string
+	InventoryFilePath         string
+	LowStockThreshold         int
+	InteractionsPath          string
+	PatientsFilePath          string
+	PatientsImportPath        string
 }
 
 func LoadConfig() *Config {
@@ -29,16 +66,53 @@ func LoadConfig() *Config {
 
 	c := &Config{}
 	return &Config{
-		AppPort:         c.Get("APP_PORT", "8080"),
-		WhatsAppAPIURL:  c.Get("WHATSAPP_API_URL", "http://localhost:3000"),
-		AllowedNumber:   c.Get("ALLOWED_NUMBER", "089123456789"),
-		ExcelOutputPath: c.Get("EXCEL_OUTPUT_PATH", "./storage/orders.xlsx"),
-		GowaAdmin:       c.Get("GOWA_USERNAME", "admin"),
-		GowaPassword:    c.Get("GOWA_PASSWORD", "password"),
-		SheetLink:       c.Get("SHEET_LINK", ""),
-		PharmacyNumber:  c.Get("PHARMACY_NUMBER", "089123456789"),
-		SheetID:         c.Get("SHEET_ID", ""),
-		NewDoctor:       c.Get("NEW_DOCTOR", "089123456789"),
+		AppPort:                   c.Get("APP_PORT", "8080"),
+		WhatsAppAPIURL:            c.Get("WHATSAPP_API_URL", "http://localhost:3000"),
+		AllowedNumber:             c.Get("ALLOWED_NUMBER", "089123456789"),
+		ExcelOutputPath:           c.Get("EXCEL_OUTPUT_PATH", "./storage/orders.xlsx"),
+		GowaAdmin:                 c.Get("GOWA_USERNAME", "admin"),
+		GowaPassword:              c.Get("GOWA_PASSWORD", "password"),
+		SheetLink:                 c.Get("SHEET_LINK", ""),
+		PharmacyNumber:            c.Get("PHARMACY_NUMBER", "089123456789"),
+		SheetID:                   c.Get("SHEET_ID", ""),
+		NewDoctor:                 c.Get("NEW_DOCTOR", "089123456789"),
+		WebhookSecret:             c.Get("WHATSAPP_WEBHOOK_SECRET", ""),
+		WebhookInsecure:           c.GetBool("WEBHOOK_INSECURE", false),
+		StateStore:                c.Get("STATE_STORE", "file"),
+		StateFilePath:             c.Get("STATE_FILE_PATH", "./storage/states.json"),
+		QueueFilePath:             c.Get("QUEUE_FILE_PATH", "./storage/queue.json"),
+		DedupFilePath:             c.Get("DEDUP_FILE_PATH", "./storage/processed_messages.json"),
+		DedupTTLMinutes:           c.GetInt("DEDUP_TTL_MINUTES", 1440),
+		DedupMaxEntries:           c.GetInt("DEDUP_MAX_ENTRIES", 10000),
+		GatewayTimeoutSeconds:     c.GetInt("GATEWAY_TIMEOUT_SECONDS", 15),
+		OutboxFilePath:            c.Get("OUTBOX_FILE_PATH", "./storage/outbox.json"),
+		OutboxMaxAttempts:         c.GetInt("OUTBOX_MAX_ATTEMPTS", 8),
+		OutboxBackoffSeconds:      c.GetInt("OUTBOX_BACKOFF_SECONDS", 2),
+		OutboxMaxBackoffSecs:      c.GetInt("OUTBOX_MAX_BACKOFF_SECONDS", 300),
+		AdminAPIKey:               c.Get("ADMIN_API_KEY", ""),
+		SheetJournalPath:          c.Get("SHEET_JOURNAL_PATH", "./storage/sheet_journal.json"),
+		SinkSheets:                c.GetBool("SINK_SHEETS", true),
+		SinkExcel:                 c.GetBool("SINK_EXCEL", true),
+		SinkCSV:                   c.GetBool("SINK_CSV", false),
+		SinkSQL:                   c.GetBool("SINK_SQL", false),
+		CSVOutputPath:             c.Get("CSV_OUTPUT_PATH", "./storage/orders.csv"),
+		SQLDSN:                    c.Get("SQL_DSN", "./storage/prescriptions.db"),
+		ContactsFilePath:          c.Get("CONTACTS_FILE_PATH", "./storage/contacts.json"),
+		AdminNumber:               c.Get("ADMIN_NUMBER", ""),
+		PrescriptionsFilePath:     c.Get("PRESCRIPTIONS_FILE_PATH", "./storage/prescriptions.json"),
+		PrescriptionRetentionDays: c.GetInt("PRESCRIPTION_RETENTION_DAYS", 90),
+		ReadyReminderMinutes:      c.GetInt("READY_REMINDER_MINUTES", 0),
+		PatientQueryLimit:         c.GetInt("PATIENT_QUERY_LIMIT", 5),
+		PatientQueryWindowMinutes: c.GetInt("PATIENT_QUERY_WINDOW_MINUTES", 10),
+		TemplatesDir:              c.Get("TEMPLATES_DIR", "./templates"),
+		DefaultLocale:             c.Get("DEFAULT_LOCALE", "id"),
+		LocalesFilePath:           c.Get("LOCALES_FILE_PATH", "./storage/locales.json"),
+		FormularyPath:             c.Get("FORMULARY_PATH", ""),
+		InventoryFilePath:         c.Get("INVENTORY_FILE_PATH", "./storage/inventory.json"),
+		LowStockThreshold:         c.GetInt("LOW_STOCK_THRESHOLD", 10),
+		InteractionsPath:          c.Get("INTERACTIONS_PATH", ""),
+		PatientsFilePath:          c.Get("PATIENTS_FILE_PATH", "./storage/patients.json"),
+		PatientsImportPath:        c.Get("PATIENTS_IMPORT_PATH", ""),
 	}
 }
 
@@ -60,3 +134,13 @@ func (c *Config) GetInt(key string, def int) int {
 
 	return value
 }
+
+func (c *Config) GetBool(key string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		log.Printf("Failed to parse %s to bool, using default value: %t", key, def)
+		return def
+	}
+
+	return value
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -14,10 +15,14 @@ type (
 	InternalServerError struct{ Message string }
 	NotFoundError       struct{ Message string }
 	ValidationError     struct {
-		FailedField string
-		Tag         string
-		Value       any
+		FailedField string `json:"failed_field"`
+		Tag         string `json:"tag"`
+		Value       any    `json:"value"`
+		// Suggestions are valid values close to Value, e.g. drug names for a typo
+		Suggestions []string `json:"suggestions,omitempty"`
 	}
+	// ValidationErrors holds every field that failed validation
+	ValidationErrors []*ValidationError
 )
 
 func (e *BadRequestError) Error() string {
@@ -35,6 +40,16 @@ func (e *NotFoundError) Error() string {
 func (e *InternalServerError) Error() string {
 	return e.Message
 }
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.FailedField, e.Tag)
+}
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, ", ")
+}
 
 func PanicIfError(err error, ctx ...string) {
 	if err != nil {
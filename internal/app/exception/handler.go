@@ -14,6 +14,7 @@ func Handler(ctx *fiber.Ctx, err error) error {
 		forbiddenError      *ForbiddenError
 		internalServerError *InternalServerError
 		notFoundError       *NotFoundError
+		validationErrors    ValidationErrors
 	)
 
 	switch {
@@ -23,6 +24,12 @@ func Handler(ctx *fiber.Ctx, err error) error {
 			Message: "Bad Request",
 			Data:    badRequestError.Error(),
 		})
+	case errors.As(err, &validationErrors):
+		return ctx.Status(fiber.StatusBadRequest).JSON(model.Response{
+			Code:    fiber.StatusBadRequest,
+			Message: "Bad Request",
+			Data:    validationErrors,
+		})
 	case errors.As(err, &unauthorizedError):
 		return ctx.Status(fiber.StatusUnauthorized).JSON(model.Response{
 			Code:    fiber.StatusUnauthorized,
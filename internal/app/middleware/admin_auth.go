@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuth only lets through requests that carry apiKey as a bearer token or in the X-API-Key header.
+// When apiKey is empty every request is rejected, so the admin API is disabled by default.
+func AdminAuth(apiKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get("X-API-Key")
+		if token == "" {
+			token = strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		}
+
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+			return &exception.UnauthorizedError{Message: "Invalid API key"}
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// backoffDelay doubles base for every failed attempt after the first, capped at max.
+func backoffDelay(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
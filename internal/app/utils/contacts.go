@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+)
+
+// Contact roles
+const (
+	RoleDoctor     = "doctor"
+	RolePharmacist = "pharmacist"
+	RoleAdmin      = "admin"
+)
+
+// Contact is a known WhatsApp number and what it is allowed to do
+type Contact struct {
+	PhoneNumber string `json:"phone_number"`
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	Active      bool   `json:"active"`
+	Seeded      bool   `json:"seeded,omitempty"` // Added from the environment, its name is a placeholder until an admin sets one
+}
+
+// ContactRegistry keeps the contacts in a JSON file, keyed by normalized phone number.
+type ContactRegistry struct {
+	mu       sync.Mutex
+	path     string
+	contacts map[string]Contact
+}
+
+func NewContactRegistry(path string) (*ContactRegistry, error) {
+	r := &ContactRegistry{
+		path:     path,
+		contacts: make(map[string]Contact),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &r.contacts); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// IsValidRole reports whether role is one of the known contact roles
+func IsValidRole(role string) bool {
+	return role == RoleDoctor || role == RolePharmacist || role == RoleAdmin
+}
+
+// Seed adds the contact only when the number is not in the registry yet,
+// so numbers from the environment never overwrite changes made by an admin.
+func (r *ContactRegistry) Seed(phoneNumber, name, role string) error {
+	if phoneNumber == "" || phoneNumber == "-" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := NormalizePhone(phoneNumber)
+	if existing, exists := r.contacts[key]; exists {
+		// Registries saved before seeds were marked still hold the seed unchanged
+		if existing.Seeded || existing.Name != name || existing.Role != role {
+			return nil
+		}
+		existing.Seeded = true
+		r.contacts[key] = existing
+		return r.save()
+	}
+	r.contacts[key] = Contact{PhoneNumber: key, Name: name, Role: role, Active: true, Seeded: true}
+	return r.save()
+}
+
+// Get returns the contact of the phone number, active or not.
+func (r *ContactRegistry) Get(phoneNumber string) (*Contact, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contact, exists := r.contacts[NormalizePhone(phoneNumber)]
+	if !exists {
+		return nil, false
+	}
+	return &contact, true
+}
+
+// HasRole reports whether the phone number belongs to an active contact with the role.
+func (r *ContactRegistry) HasRole(phoneNumber, role string) bool {
+	contact, exists := r.Get(phoneNumber)
+	return exists && contact.Active && contact.Role == role
+}
+
+// IsActive reports whether the phone number belongs to any active contact.
+func (r *ContactRegistry) IsActive(phoneNumber string) bool {
+	contact, exists := r.Get(phoneNumber)
+	return exists && contact.Active
+}
+
+// Upsert adds the contact or replaces the existing one with the same number.
+// A number that belongs to a contact with another role is refused, so a pharmacist
+// or an admin is never turned into a doctor by accident.
+func (r *ContactRegistry) Upsert(contact Contact) (*Contact, error) {
+	if !IsValidRole(contact.Role) {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("Unknown role: %s", contact.Role)}
+	}
+
+	contact.PhoneNumber = NormalizePhone(contact.PhoneNumber)
+	if contact.PhoneNumber == "" || contact.PhoneNumber == "-" {
+		return nil, &exception.BadRequestError{Message: "Phone number is required"}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, exists := r.contacts[contact.PhoneNumber]; exists && existing.Role != contact.Role {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("%s already belongs to %s (%s)", contact.PhoneNumber, existing.Name, existing.Role)}
+	}
+
+	r.contacts[contact.PhoneNumber] = contact
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Deactivate keeps the contact but takes away its access.
+func (r *ContactRegistry) Deactivate(phoneNumber string) (*Contact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := NormalizePhone(phoneNumber)
+	contact, exists := r.contacts[key]
+	if !exists {
+		return nil, &exception.NotFoundError{Message: "Contact not found"}
+	}
+
+	contact.Active = false
+	r.contacts[key] = contact
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// List returns the contacts with the role sorted by name, every contact when role is empty.
+func (r *ContactRegistry) List(role string) []Contact {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contacts := []Contact{}
+	for _, contact := range r.contacts {
+		if role == "" || contact.Role == role {
+			contacts = append(contacts, contact)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return contacts[i].Name < contacts[j].Name
+	})
+	return contacts
+}
+
+func (r *ContactRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	return writeJSONFile(r.path, r.contacts)
+}
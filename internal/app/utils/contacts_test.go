@@ -0,0 +1,58 @@
+package utils
+
+import "testing"
+
+func TestContactUpsertRefusesAnotherRole(t *testing.T) {
+	contacts, err := NewContactRegistry("")
+	if err != nil {
+		t.Fatalf("NewContactRegistry: %v", err)
+	}
+	if err := contacts.Seed("6287777777777", "Admin", RoleAdmin); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+
+	if _, err := contacts.Upsert(Contact{PhoneNumber: "087777777777", Name: "Budi", Role: RoleDoctor, Active: true}); err == nil {
+		t.Fatal("Upsert turned the admin into a doctor")
+	}
+	if !contacts.HasRole("6287777777777", RoleAdmin) {
+		t.Fatal("the admin lost its role")
+	}
+
+	// A doctor can still be renamed or reactivated
+	if _, err := contacts.Upsert(Contact{PhoneNumber: "6281111111111", Name: "Budi", Role: RoleDoctor}); err != nil {
+		t.Fatalf("Upsert doctor: %v", err)
+	}
+	doctor, err := contacts.Upsert(Contact{PhoneNumber: "6281111111111", Name: "Budi Santoso", Role: RoleDoctor, Active: true})
+	if err != nil {
+		t.Fatalf("Upsert existing doctor: %v", err)
+	}
+	if doctor.Name != "Budi Santoso" || !contacts.HasRole("6281111111111", RoleDoctor) {
+		t.Fatalf("doctor = %+v, want the new name and active", doctor)
+	}
+}
+
+func TestContactSeedIsMarkedUntilAnAdminSetsTheName(t *testing.T) {
+	contacts, err := NewContactRegistry("")
+	if err != nil {
+		t.Fatalf("NewContactRegistry: %v", err)
+	}
+
+	// A registry saved before seeds were marked holds the seed unchanged
+	contacts.contacts["6281111111111"] = Contact{PhoneNumber: "6281111111111", Name: "Dokter", Role: RoleDoctor, Active: true}
+	if err := contacts.Seed("6281111111111", "Dokter", RoleDoctor); err != nil {
+		t.Fatalf("Seed: %v", err)
+	}
+	if doctor, _ := contacts.Get("6281111111111"); !doctor.Seeded {
+		t.Fatalf("doctor = %+v, want the unchanged seed marked", doctor)
+	}
+
+	if _, err := contacts.Upsert(Contact{PhoneNumber: "6281111111111", Name: "Budi Santoso", Role: RoleDoctor, Active: true}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := contacts.Seed("6281111111111", "Dokter", RoleDoctor); err != nil {
+		t.Fatalf("Seed again: %v", err)
+	}
+	if doctor, _ := contacts.Get("6281111111111"); doctor.Seeded || doctor.Name != "Budi Santoso" {
+		t.Fatalf("doctor = %+v, want the name the admin set", doctor)
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CSVSink appends every prescription to a local CSV file,
+// and its medication lines to a second file next to it (orders.csv gets orders_medications.csv)
+type CSVSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewCSVSink(path string) (*CSVSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %s: %v", path, err)
+	}
+	return &CSVSink{path: path}, nil
+}
+
+func (s *CSVSink) Name() string {
+	return "CSV"
+}
+
+func (s *CSVSink) Write(record *PrescriptionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	writeHeader := os.IsNotExist(err) || (err == nil && info.Size() == 0)
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", s.path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if writeHeader {
+		w.Write(csvRecord(ledgerHeader))
+	}
+
+	details := record.Details
+	w.Write([]string{
+		strconv.Itoa(record.QueueNumber),
+		details.DoctorName,
+		details.PatientName,
+		details.PatientBirthDate,
+		strings.TrimPrefix(details.RegistryNum, "'"),
+		details.Medication,
+		details.PatientPhoneNumber,
+		details.PaymentMethod,
+		record.FormattedTime(),
+		record.ID,
+	})
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("unable to write %s: %v", s.path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	return appendCSVRows(s.medicationsPath(), medicationHeader, medicationRows(record))
+}
+
+func (s *CSVSink) medicationsPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + "_medications.csv"
+}
+
+// appendCSVRows appends rows to a CSV file, writing the header when the file is new
+func appendCSVRows(path string, header []interface{}, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	writeHeader := os.IsNotExist(err) || (err == nil && info.Size() == 0)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if writeHeader {
+		w.Write(csvRecord(header))
+	}
+	for _, row := range rows {
+		w.Write(csvRecord(row))
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("unable to write %s: %v", path, err)
+	}
+	return f.Sync()
+}
+
+func csvRecord(values []interface{}) []string {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = fmt.Sprint(value)
+	}
+	return record
+}
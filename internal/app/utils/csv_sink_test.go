@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSinkRecord is a prescription with two medication lines, one of them without a quantity
+func testSinkRecord(queueNumber int) *PrescriptionRecord {
+	details := &PatientDetails{
+		DoctorName:         "Budi",
+		PatientName:        "Siti Aminah",
+		PatientBirthDate:   "01-02-1990",
+		RegistryNum:        "'012345",
+		Medication:         "1. Amoxicillin 500 mg tablet, No. XV (15), S. 3dd1\n2. Vitamin C",
+		PatientPhoneNumber: "6281234567890",
+		PaymentMethod:      "BPJS",
+		Medications: []MedicationItem{
+			{Name: "Amoxicillin", Strength: "500 mg", Form: "tablet", Quantity: 15, QuantityText: "XV", Signa: "3dd1", Raw: "Amoxicillin 500mg tab no. XV 3dd1"},
+			{Name: "Vitamin C", Raw: "Vitamin C"},
+		},
+	}
+	return NewPrescriptionRecord(details, "6289999999999", queueNumber, time.Date(2025, 3, 10, 9, 30, 0, 0, WIB))
+}
+
+func readCSVFile(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return rows
+}
+
+func TestCSVSinkWritesPrescriptionsAndMedications(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exports", "orders.csv")
+	sink, err := NewCSVSink(path)
+	if err != nil {
+		t.Fatalf("NewCSVSink: %v", err)
+	}
+	for _, queueNumber := range []int{1, 2} {
+		if err := sink.Write(testSinkRecord(queueNumber)); err != nil {
+			t.Fatalf("Write %d: %v", queueNumber, err)
+		}
+	}
+
+	// One header, then a row per prescription; the multi-line medication stays one quoted cell
+	rows := readCSVFile(t, path)
+	if len(rows) != 3 || rows[0][0] != "No Antrian" || rows[0][9] != "ID Resep" {
+		t.Fatalf("rows = %q, want a header and 2 prescriptions", rows)
+	}
+	want := []string{"1", "Budi", "Siti Aminah", "01-02-1990", "012345", testSinkRecord(1).Details.Medication, "6281234567890", "BPJS", "10 March 2025 09:30 WIB", "RX-20250310-6289999999999-001"}
+	if strings.Join(rows[1], "|") != strings.Join(want, "|") {
+		t.Fatalf("row = %q\nwant %q", rows[1], want)
+	}
+	if rows[2][0] != "2" || rows[2][9] != "RX-20250310-6289999999999-002" {
+		t.Fatalf("second row = %q", rows[2])
+	}
+
+	medications := readCSVFile(t, filepath.Join(filepath.Dir(path), "orders_medications.csv"))
+	if len(medications) != 5 || medications[0][0] != "ID Resep" {
+		t.Fatalf("medication rows = %q, want a header and 2 lines per prescription", medications)
+	}
+	wantLines := [][]string{
+		{"RX-20250310-6289999999999-001", "1", "Amoxicillin", "500 mg", "tablet", "15", "3dd1", "Amoxicillin 500mg tab no. XV 3dd1"},
+		// No quantity is an empty cell, not 0
+		{"RX-20250310-6289999999999-001", "2", "Vitamin C", "", "", "", "", "Vitamin C"},
+	}
+	for i, line := range wantLines {
+		if strings.Join(medications[i+1], "|") != strings.Join(line, "|") {
+			t.Errorf("medication row %d = %q, want %q", i+1, medications[i+1], line)
+		}
+	}
+}
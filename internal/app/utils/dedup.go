@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// SeenSet remembers recently processed message IDs so webhook retries are only handled once.
+// Entries expire after ttl and the oldest entries are evicted once maxEntries is reached.
+type SeenSet struct {
+	mu         sync.Mutex
+	path       string
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]time.Time
+	now        func() time.Time
+}
+
+// NewSeenSet loads the set stored at path. An empty path keeps it in memory only.
+func NewSeenSet(path string, ttl time.Duration, maxEntries int) (*SeenSet, error) {
+	s := &SeenSet{
+		path:       path,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]time.Time),
+		now:        time.Now,
+	}
+	if path != "" {
+		if err := readJSONFile(path, &s.entries); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add records id and reports whether it was seen for the first time.
+func (s *SeenSet) Add(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	if _, exists := s.entries[id]; exists {
+		return false, nil
+	}
+
+	s.entries[id] = now
+	s.evict()
+
+	if s.path != "" {
+		if err := writeJSONFile(s.path, s.entries); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Forget removes id so the message is handled again when it is delivered again.
+func (s *SeenSet) Forget(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.entries[id]; !exists {
+		return nil
+	}
+	delete(s.entries, id)
+
+	if s.path != "" {
+		return writeJSONFile(s.path, s.entries)
+	}
+	return nil
+}
+
+// prune removes the entries older than the ttl.
+func (s *SeenSet) prune(now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	for id, seenAt := range s.entries {
+		if now.Sub(seenAt) > s.ttl {
+			delete(s.entries, id)
+		}
+	}
+}
+
+// evict removes the oldest entries until the set fits in maxEntries.
+func (s *SeenSet) evict() {
+	if s.maxEntries <= 0 {
+		return
+	}
+	for len(s.entries) > s.maxEntries {
+		var oldestID string
+		var oldest time.Time
+		for id, seenAt := range s.entries {
+			if oldestID == "" || seenAt.Before(oldest) {
+				oldestID, oldest = id, seenAt
+			}
+		}
+		delete(s.entries, oldestID)
+	}
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ledgerHeader matches the columns of the Prescriptions spreadsheet
+var ledgerHeader = []interface{}{
+	"No Antrian",
+	"Nama Dokter",
+	"Nama Pasien",
+	"Tanggal Lahir Pasien",
+	"No Regis",
+	"Resep Obat",
+	"Nomor Telpon Pasien",
+	"Pembiayaan",
+	"Waktu",
+	"ID Resep",
+}
+
+// medicationHeader are the columns of the Medications sheet, one row per medication line
+var medicationHeader = []interface{}{
+	"ID Resep",
+	"No",
+	"Nama Obat",
+	"Kekuatan",
+	"Bentuk",
+	"Jumlah",
+	"Signa",
+	"Tertulis",
+}
+
+// medicationSheet is the sheet of the workbook with the medication lines of every day
+const medicationSheet = "Obat"
+
+// medicationRows returns a Medications row for every medication line of the record
+func medicationRows(record *PrescriptionRecord) [][]interface{} {
+	rows := make([][]interface{}, len(record.Details.Medications))
+	for i, item := range record.Details.Medications {
+		var quantity interface{} = ""
+		if item.QuantityText != "" {
+			quantity = item.Quantity
+		}
+		rows[i] = []interface{}{record.ID, i + 1, item.Name, item.Strength, item.Form, quantity, item.Signa, item.Raw}
+	}
+	return rows
+}
+
+// ExcelLedger writes every prescription to a local workbook with one sheet per day (WIB),
+// and its medication lines to the Obat sheet.
+type ExcelLedger struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewExcelLedger(path string) (*ExcelLedger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %s: %v", path, err)
+	}
+	return &ExcelLedger{path: path}, nil
+}
+
+func (l *ExcelLedger) Name() string {
+	return "Excel"
+}
+
+func (l *ExcelLedger) Write(record *PrescriptionRecord) error {
+	return l.AddPrescriptionRow(record)
+}
+
+func (l *ExcelLedger) AddPrescriptionRow(record *PrescriptionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sheet := record.CreatedAt.In(WIB).Format("2006-01-02")
+	if err := ensureLedgerSheet(f, sheet); err != nil {
+		return err
+	}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return fmt.Errorf("unable to read sheet %s: %v", sheet, err)
+	}
+
+	details := record.Details
+	row := []interface{}{
+		record.QueueNumber,
+		details.DoctorName,
+		details.PatientName,
+		details.PatientBirthDate,
+		strings.TrimPrefix(details.RegistryNum, "'"), // The quote only keeps the leading zero in Google Sheets
+		details.Medication,
+		details.PatientPhoneNumber,
+		details.PaymentMethod,
+		record.FormattedTime(),
+		record.ID,
+	}
+
+	cell, _ := excelize.CoordinatesToCellName(1, len(rows)+1)
+	if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+		return fmt.Errorf("unable to write row to sheet %s: %v", sheet, err)
+	}
+
+	if err := addMedicationRows(f, record); err != nil {
+		return err
+	}
+
+	return l.save(f)
+}
+
+// open loads the workbook, or creates it when it does not exist yet.
+func (l *ExcelLedger) open() (*excelize.File, error) {
+	f, err := excelize.OpenFile(l.path)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to open %s: %v", l.path, err)
+	}
+	return excelize.NewFile(), nil
+}
+
+// save writes the workbook to a temp file first so a crash never corrupts the ledger.
+func (l *ExcelLedger) save(f *excelize.File) error {
+	tmp := l.path + ".tmp.xlsx"
+	if err := f.SaveAs(tmp); err != nil {
+		return fmt.Errorf("unable to save %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("unable to replace %s: %v", l.path, err)
+	}
+	return nil
+}
+
+// addMedicationRows appends the medication lines of the record to the Obat sheet
+func addMedicationRows(f *excelize.File, record *PrescriptionRecord) error {
+	if len(record.Details.Medications) == 0 {
+		return nil
+	}
+
+	index, err := f.GetSheetIndex(medicationSheet)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		if _, err := f.NewSheet(medicationSheet); err != nil {
+			return fmt.Errorf("unable to create sheet %s: %v", medicationSheet, err)
+		}
+		if err := f.SetSheetRow(medicationSheet, "A1", &medicationHeader); err != nil {
+			return fmt.Errorf("unable to write header to sheet %s: %v", medicationSheet, err)
+		}
+	}
+
+	rows, err := f.GetRows(medicationSheet)
+	if err != nil {
+		return fmt.Errorf("unable to read sheet %s: %v", medicationSheet, err)
+	}
+	for i, row := range medicationRows(record) {
+		cell, _ := excelize.CoordinatesToCellName(1, len(rows)+i+1)
+		if err := f.SetSheetRow(medicationSheet, cell, &row); err != nil {
+			return fmt.Errorf("unable to write row to sheet %s: %v", medicationSheet, err)
+		}
+	}
+	return nil
+}
+
+// ensureLedgerSheet creates the sheet of the day with its header row.
+func ensureLedgerSheet(f *excelize.File, sheet string) error {
+	index, err := f.GetSheetIndex(sheet)
+	if err != nil {
+		return err
+	}
+	if index >= 0 {
+		return nil
+	}
+
+	if _, err := f.NewSheet(sheet); err != nil {
+		return fmt.Errorf("unable to create sheet %s: %v", sheet, err)
+	}
+	if err := f.SetSheetRow(sheet, "A1", &ledgerHeader); err != nil {
+		return fmt.Errorf("unable to write header to sheet %s: %v", sheet, err)
+	}
+
+	// A new workbook comes with an empty default sheet
+	if defaultIndex, _ := f.GetSheetIndex("Sheet1"); defaultIndex >= 0 {
+		if rows, _ := f.GetRows("Sheet1"); len(rows) == 0 {
+			if err := f.DeleteSheet("Sheet1"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Open the workbook on the latest day
+	index, err = f.GetSheetIndex(sheet)
+	if err != nil {
+		return err
+	}
+	f.SetActiveSheet(index)
+	return nil
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func newTestLedger(t *testing.T) *ExcelLedger {
+	t.Helper()
+
+	ledger, err := NewExcelLedger(filepath.Join(t.TempDir(), "ledger", "orders.xlsx"))
+	if err != nil {
+		t.Fatalf("NewExcelLedger: %v", err)
+	}
+	return ledger
+}
+
+func readLedgerRows(t *testing.T, ledger *ExcelLedger, sheet string) [][]string {
+	t.Helper()
+
+	f, err := excelize.OpenFile(ledger.path)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		t.Fatalf("read sheet %s: %v", sheet, err)
+	}
+	return rows
+}
+
+func TestExcelLedgerWritesRowsAndReadsThemBack(t *testing.T) {
+	ledger := newTestLedger(t)
+
+	details := PatientDetails{
+		DoctorName:         "Budi",
+		PatientName:        "Siti Aminah",
+		PatientBirthDate:   "01-02-1990",
+		RegistryNum:        "'012345",
+		PatientPhoneNumber: "6281234567890",
+		PaymentMethod:      "BPJS",
+	}
+	details.Medications, _ = ParseMedications("Amoxicillin 500mg tab No. XV 3dd1\nParacetamol 500mg tab No. X")
+	details.Medication = FormatMedications(details.Medications)
+	record := NewPrescriptionRecord(&details, "6289999999999", 7, time.Date(2025, 1, 2, 9, 30, 0, 0, WIB))
+
+	if err := ledger.Write(record); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rows := readLedgerRows(t, ledger, "2025-01-02")
+	if len(rows) != 2 {
+		t.Fatalf("sheet has %d rows, want the header and one prescription", len(rows))
+	}
+	if rows[0][0] != "No Antrian" {
+		t.Fatalf("header = %v", rows[0])
+	}
+	want := []string{"7", "Budi", "Siti Aminah", "01-02-1990", "012345", details.Medication, "6281234567890", "BPJS", "02 January 2025 09:30 WIB", record.ID}
+	for i, value := range want {
+		if rows[1][i] != value {
+			t.Fatalf("column %d = %q, want %q", i+1, rows[1][i], value)
+		}
+	}
+
+	medications := readLedgerRows(t, ledger, medicationSheet)
+	if len(medications) != 3 {
+		t.Fatalf("Obat sheet has %d rows, want the header and two lines", len(medications))
+	}
+	if medications[1][0] != record.ID || medications[1][2] != "Amoxicillin" || medications[1][5] != "15" {
+		t.Fatalf("first medication row = %v", medications[1])
+	}
+}
+
+func TestExcelLedgerConcurrentWrites(t *testing.T) {
+	ledger := newTestLedger(t)
+	createdAt := time.Date(2025, 1, 2, 9, 30, 0, 0, WIB)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(queueNumber int) {
+			defer wg.Done()
+			details := &PatientDetails{PatientName: fmt.Sprintf("Pasien %d", queueNumber)}
+			if err := ledger.Write(NewPrescriptionRecord(details, "6289999999999", queueNumber, createdAt)); err != nil {
+				t.Errorf("Write %d: %v", queueNumber, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	rows := readLedgerRows(t, ledger, "2025-01-02")
+	if len(rows) != writers+1 {
+		t.Fatalf("sheet has %d rows, want the header and %d prescriptions", len(rows), writers)
+	}
+
+	var queueNumbers []int
+	for _, row := range rows[1:] {
+		number, err := strconv.Atoi(row[0])
+		if err != nil {
+			t.Fatalf("queue number %q: %v", row[0], err)
+		}
+		queueNumbers = append(queueNumbers, number)
+	}
+	sort.Ints(queueNumbers)
+	for i, number := range queueNumbers {
+		if number != i+1 {
+			t.Fatalf("queue numbers = %v, a row was lost or written twice", queueNumbers)
+		}
+	}
+}
+
+func TestExcelLedgerStartsASheetEveryWIBDay(t *testing.T) {
+	ledger := newTestLedger(t)
+
+	// 16:59 UTC is still 2 January in Jakarta, 17:01 UTC is already 3 January
+	beforeMidnight := time.Date(2025, 1, 2, 16, 59, 0, 0, time.UTC)
+	afterMidnight := time.Date(2025, 1, 2, 17, 1, 0, 0, time.UTC)
+	for i, createdAt := range []time.Time{beforeMidnight, afterMidnight} {
+		if err := ledger.Write(NewPrescriptionRecord(&PatientDetails{}, "6289999999999", i+1, createdAt)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	f, err := excelize.OpenFile(ledger.path)
+	if err != nil {
+		t.Fatalf("open ledger: %v", err)
+	}
+	sheets := f.GetSheetList()
+	f.Close()
+	if len(sheets) != 2 || sheets[0] != "2025-01-02" || sheets[1] != "2025-01-03" {
+		t.Fatalf("sheets = %v, want one per WIB day and no default sheet", sheets)
+	}
+
+	for day, queueNumber := range map[string]string{"2025-01-02": "1", "2025-01-03": "2"} {
+		rows := readLedgerRows(t, ledger, day)
+		if len(rows) != 2 || rows[1][0] != queueNumber {
+			t.Fatalf("sheet %s = %v, want queue number %s", day, rows, queueNumber)
+		}
+	}
+}
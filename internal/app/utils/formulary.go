@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Validation tags of medication lines checked against the formulary
+const (
+	TagFormulary = "formulary"
+	TagStrength  = "strength"
+)
+
+// maxSuggestions is how many "did you mean" names are offered for an unknown drug
+const maxSuggestions = 3
+
+// FormularyEntry is a drug the pharmacy stocks, under its generic name and its brand names
+type FormularyEntry struct {
+	Generic     string   `json:"generic"`
+	Brands      []string `json:"brands,omitempty"`
+	Strengths   []string `json:"strengths,omitempty"`   // Empty when every strength is available
+	Ingredients []string `json:"ingredients,omitempty"` // Active ingredients of a combination, empty when it is the generic itself
+	Classes     []string `json:"classes,omitempty"`     // Drug classes, e.g. "Penicillin", checked against allergies
+}
+
+// Formulary is the list of drugs doctors can prescribe. It is read once from a CSV or XLSX file
+// whose first row names the columns: generic (or generik), brand (merek), strength (kekuatan),
+// ingredients (kandungan) and class (golongan). A cell can hold several values separated by ";" or "|", and rows
+// of the same generic name are merged.
+type Formulary struct {
+	entries []*FormularyEntry
+	names   map[string]*FormularyEntry // Lowercased generic and brand names
+}
+
+// formularyColumns are the header names each column may have
+var formularyColumns = map[string][]string{
+	"generic":     {"generic", "generik", "nama generik"},
+	"brand":       {"brand", "brands", "merek", "merk", "nama dagang"},
+	"strength":    {"strength", "strengths", "kekuatan", "sediaan"},
+	"ingredients": {"ingredients", "ingredient", "kandungan", "zat aktif", "komposisi"},
+	"class":       {"class", "classes", "golongan", "kelas"},
+}
+
+// LoadFormulary reads the formulary file, the format follows the extension (.csv or .xlsx)
+func LoadFormulary(path string) (*Formulary, error) {
+	rows, columns, err := readTable(path, formularyColumns, "generic")
+	if err != nil {
+		return nil, fmt.Errorf("unable to load formulary: %v", err)
+	}
+
+	f := &Formulary{names: make(map[string]*FormularyEntry)}
+	for _, row := range rows {
+		generic := strings.TrimSpace(cellAt(row, columns, "generic"))
+		if generic == "" {
+			continue
+		}
+
+		entry, ok := f.names[formularyKey(generic)]
+		if !ok {
+			entry = &FormularyEntry{Generic: generic}
+			f.entries = append(f.entries, entry)
+			f.names[formularyKey(generic)] = entry
+		}
+		for _, brand := range splitFormularyCell(cellAt(row, columns, "brand")) {
+			entry.Brands = appendNew(entry.Brands, brand, strings.ToLower)
+			f.names[formularyKey(brand)] = entry
+		}
+		for _, strength := range splitFormularyCell(cellAt(row, columns, "strength")) {
+			entry.Strengths = appendNew(entry.Strengths, strength, normalizeStrength)
+		}
+		for _, ingredient := range splitFormularyCell(cellAt(row, columns, "ingredients")) {
+			entry.Ingredients = appendNew(entry.Ingredients, ingredient, strings.ToLower)
+		}
+		for _, class := range splitFormularyCell(cellAt(row, columns, "class")) {
+			entry.Classes = appendNew(entry.Classes, class, strings.ToLower)
+		}
+	}
+	return f, nil
+}
+
+// appendNew appends value unless the list has it already, values are compared through key
+func appendNew(list []string, value string, key func(string) string) []string {
+	for _, known := range list {
+		if key(known) == key(value) {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// Entries returns every drug of the formulary in file order
+func (f *Formulary) Entries() []*FormularyEntry {
+	return f.entries
+}
+
+// Lookup finds the drug of a medication name by its whole generic or brand name, ignoring case
+// and spacing. A name with extra words, e.g. "Paracetamol CTM", is not found: the extra words may
+// be another drug that then would never be checked.
+func (f *Formulary) Lookup(name string) (*FormularyEntry, bool) {
+	entry, ok := f.names[formularyKey(name)]
+	return entry, ok
+}
+
+// formularyKey is the key of a name in Formulary.names, lowercased with single spaces
+func formularyKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Suggest returns the formulary names closest to an unknown name, at most maxSuggestions of them.
+// Names more edits away than a third of the longer name (at least 2) are not offered.
+func (f *Formulary) Suggest(name string) []string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		return nil
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	// The doctor may have written only the first word wrong, e.g. "Amoxilin forte"
+	spellings := []string{name, strings.Fields(name)[0]}
+
+	var candidates []candidate
+	for _, entry := range f.entries {
+		for _, option := range append([]string{entry.Generic}, entry.Brands...) {
+			for _, written := range spellings {
+				distance := levenshtein(written, strings.ToLower(option))
+				if distance <= max(2, max(len([]rune(written)), len([]rune(option)))/3) {
+					candidates = append(candidates, candidate{option, distance})
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.name)
+	}
+	return suggestions
+}
+
+// ActiveIngredients returns the ingredients of a combination drug, or the generic name
+func (e *FormularyEntry) ActiveIngredients() []string {
+	if len(e.Ingredients) > 0 {
+		return e.Ingredients
+	}
+	return []string{e.Generic}
+}
+
+// HasStrength reports whether the drug is available in the strength, "500 mg" matches "500mg"
+func (e *FormularyEntry) HasStrength(strength string) bool {
+	if strength == "" || len(e.Strengths) == 0 {
+		return true
+	}
+	for _, s := range e.Strengths {
+		if normalizeStrength(s) == normalizeStrength(strength) {
+			return true
+		}
+	}
+	return false
+}
+
+// strengthAs returns the strength as the formulary writes it, e.g. "500mg" becomes "500 mg"
+func (e *FormularyEntry) strengthAs(strength string) string {
+	for _, s := range e.Strengths {
+		if normalizeStrength(s) == normalizeStrength(strength) {
+			return s
+		}
+	}
+	return strength
+}
+
+// Check returns a field error for every medication line that is not in the formulary,
+// with the closest names as suggestions, or whose strength the pharmacy does not have.
+func (f *Formulary) Check(items []MedicationItem) exception.ValidationErrors {
+	var errs exception.ValidationErrors
+	for _, item := range items {
+		entry, ok := f.Lookup(item.Name)
+		if !ok {
+			errs = append(errs, &exception.ValidationError{
+				FailedField: FormMedication,
+				Tag:         TagFormulary,
+				Value:       item.Name,
+				Suggestions: f.Suggest(item.Name),
+			})
+			continue
+		}
+		if !entry.HasStrength(item.Strength) {
+			errs = append(errs, &exception.ValidationError{
+				FailedField: FormMedication,
+				Tag:         TagStrength,
+				Value:       item.Name + " " + item.Strength,
+				Suggestions: entry.Strengths,
+			})
+		}
+	}
+	return errs
+}
+
+// readTable reads the rows of a CSV or XLSX file, the format follows the extension. The first row
+// names the columns, each matched case-insensitively against the aliases of a column. The returned
+// rows leave out the header.
+func readTable(path string, aliases map[string][]string, required string) ([][]string, map[string]int, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = readCSVRows(path)
+	case ".xlsx":
+		rows, err = readXLSXRows(path)
+	default:
+		return nil, nil, fmt.Errorf("unsupported file %s, use .csv or .xlsx", path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%s is empty", path)
+	}
+
+	columns := make(map[string]int)
+	for i, cell := range rows[0] {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		for column, names := range aliases {
+			for _, alias := range names {
+				if name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns[required]; !ok {
+		return nil, nil, fmt.Errorf("%s has no %s column", path, required)
+	}
+	return rows[1:], columns, nil
+}
+
+func cellAt(row []string, columns map[string]int, column string) string {
+	i, ok := columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+func splitFormularyCell(cell string) []string {
+	var values []string
+	for _, value := range strings.FieldsFunc(cell, func(r rune) bool { return r == ';' || r == '|' }) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// normalizeStrength drops spaces and case and reads a decimal comma as a point, "2,5 ML" becomes "2.5ml"
+func normalizeStrength(strength string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.Join(strings.Fields(strength), "")), ",", ".")
+}
+
+func readCSVRows(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return rows, nil
+}
+
+// readXLSXRows reads the first sheet of the workbook
+func readXLSXRows(path string) ([][]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return rows, nil
+}
+
+// levenshtein is the number of single character edits between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
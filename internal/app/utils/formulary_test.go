@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// testFormularyCSV has Indonesian headers, brands, strengths, a combination drug and drug classes
+const testFormularyCSV = `Generik,Merek,Kekuatan,Kandungan,Golongan
+Amoxicillin,Amoxsan;Kalmoxillin,250 mg|500 mg,,Penicillin;Antibiotik
+Paracetamol,Panadol;Sanmol,500 mg,,Analgesik
+Paracetamol,,"2,5 ml",,
+Paracetamol,sanmol,500MG;2.5 ml,,Analgesik
+Ibuprofen,Proris,400 mg,,NSAID
+Amoxicillin clavulanate,Augmentin,625 mg,Amoxicillin;Clavulanic acid,Penicillin
+Cetirizine,,,,Antihistamin
+`
+
+// writeTestFile writes content to name in a temporary directory and returns its path
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func loadTestFormulary(t *testing.T) *Formulary {
+	t.Helper()
+
+	formulary, err := LoadFormulary(writeTestFile(t, "formulary.csv", testFormularyCSV))
+	if err != nil {
+		t.Fatalf("LoadFormulary: %v", err)
+	}
+	return formulary
+}
+
+func TestFormularyMergesRowsOfAGenericName(t *testing.T) {
+	// The third Paracetamol row repeats a brand, two strengths and the class in other spellings
+	formulary := loadTestFormulary(t)
+
+	if got := len(formulary.Entries()); got != 5 {
+		t.Fatalf("entries = %d, want 5", got)
+	}
+	paracetamol, ok := formulary.Lookup("Paracetamol")
+	if !ok {
+		t.Fatal("Paracetamol not found")
+	}
+	if strings.Join(paracetamol.Strengths, "|") != "500 mg|2,5 ml" || strings.Join(paracetamol.Brands, "|") != "Panadol|Sanmol" {
+		t.Fatalf("paracetamol = %+v, want the strengths and brands of the rows once", paracetamol)
+	}
+	if strings.Join(paracetamol.Classes, "|") != "Analgesik" {
+		t.Fatalf("classes = %v, want Analgesik once", paracetamol.Classes)
+	}
+}
+
+func TestFormularyLookup(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	tests := []struct {
+		name    string
+		generic string // Empty when the name is not in the formulary
+	}{
+		{"Amoxicillin", "Amoxicillin"},
+		{"AMOXICILLIN", "Amoxicillin"},
+		{"Kalmoxillin", "Amoxicillin"},
+		{"Panadol", "Paracetamol"},
+		{" paracetamol  ", "Paracetamol"},
+		{"Amoxicillin clavulanate", "Amoxicillin clavulanate"},
+		{"Augmentin", "Amoxicillin clavulanate"},
+		{"Amoxilin", ""},
+		// Extra words may be another drug, so only the whole name is found
+		{"Paracetamol forte", ""},
+		{"Paracetamol CTM Ambroxol", ""},
+		{"Forte paracetamol", ""},
+	}
+
+	for _, tt := range tests {
+		entry, ok := formulary.Lookup(tt.name)
+		switch {
+		case tt.generic == "" && ok:
+			t.Errorf("Lookup(%q) = %s, want not found", tt.name, entry.Generic)
+		case tt.generic != "" && (!ok || entry.Generic != tt.generic):
+			t.Errorf("Lookup(%q) = %+v, %v, want %s", tt.name, entry, ok, tt.generic)
+		}
+	}
+}
+
+func TestFormularySuggest(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		// One edit away from a generic and from a brand
+		{"Amoxicilin", []string{"Amoxicillin"}},
+		{"Panadoll", []string{"Panadol"}},
+		// Only the first word is misspelled
+		{"Ibuprofn forte", []string{"Ibuprofen"}},
+		// The closest name comes first
+		{"Paracetamol 5", []string{"Paracetamol"}},
+		// More edits than a third of the name is not a typo
+		{"Omeprazole", nil},
+		{"Cefixime", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got := formulary.Suggest(tt.name)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Suggest(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormularySuggestKeepsTheClosestNames(t *testing.T) {
+	formulary := &Formulary{names: map[string]*FormularyEntry{}}
+	for _, generic := range []string{"Cefadroxil", "Cefadroxyl", "Cefadroksil", "Cefadroxll", "Cefixime"} {
+		formulary.entries = append(formulary.entries, &FormularyEntry{Generic: generic})
+	}
+
+	got := formulary.Suggest("Cefadroxil forte")
+	if len(got) != maxSuggestions || got[0] != "Cefadroxil" {
+		t.Fatalf("Suggest = %v, want %d names starting with the exact first word", got, maxSuggestions)
+	}
+}
+
+func TestFormularyCheck(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	items := []MedicationItem{
+		{Name: "Amoxicillin", Strength: "500 mg"},
+		{Name: "Sanmol", Strength: "2.5 ml"},    // "2,5 ml" in the formulary
+		{Name: "Cetirizine", Strength: "10 mg"}, // Every strength is available
+		{Name: "Ibuprofen"},                     // No strength written
+		{Name: "Amoxicillin", Strength: "1 g"},
+		{Name: "Amoxilin", Strength: "500 mg"},
+	}
+
+	errs := formulary.Check(items)
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want the strength of Amoxicillin and the unknown name", errs)
+	}
+	if errs[0].Tag != TagStrength || errs[0].Value != "Amoxicillin 1 g" || strings.Join(errs[0].Suggestions, "|") != "250 mg|500 mg" {
+		t.Errorf("strength error = %+v", errs[0])
+	}
+	if errs[1].Tag != TagFormulary || errs[1].Value != "Amoxilin" || strings.Join(errs[1].Suggestions, "|") != "Amoxicillin|Kalmoxillin" {
+		t.Errorf("unknown name error = %+v", errs[1])
+	}
+}
+
+func TestFormularyStrengthAs(t *testing.T) {
+	amoxicillin := &FormularyEntry{Generic: "Amoxicillin", Strengths: []string{"250 mg", "500 mg"}}
+
+	for written, want := range map[string]string{"500mg": "500 mg", "500 MG": "500 mg", "1 g": "1 g"} {
+		if got := amoxicillin.strengthAs(written); got != want {
+			t.Errorf("strengthAs(%q) = %q, want %q", written, got, want)
+		}
+	}
+}
+
+func TestFormularyActiveIngredients(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	augmentin, _ := formulary.Lookup("Augmentin")
+	if got := strings.Join(augmentin.ActiveIngredients(), "|"); got != "Amoxicillin|Clavulanic acid" {
+		t.Fatalf("ingredients of Augmentin = %s", got)
+	}
+	ibuprofen, _ := formulary.Lookup("Ibuprofen")
+	if got := strings.Join(ibuprofen.ActiveIngredients(), "|"); got != "Ibuprofen" {
+		t.Fatalf("ingredients of Ibuprofen = %s, want the generic name", got)
+	}
+}
+
+func TestLoadFormularyHeaders(t *testing.T) {
+	// English headers in another order and case, and a column the formulary does not know
+	path := writeTestFile(t, "formulary.csv", "Notes,BRAND,Generic,Strength\nkeep cold,Amoxsan,Amoxicillin,500 mg\n")
+	formulary, err := LoadFormulary(path)
+	if err != nil {
+		t.Fatalf("LoadFormulary: %v", err)
+	}
+	entry, ok := formulary.Lookup("Amoxsan")
+	if !ok || entry.Generic != "Amoxicillin" || strings.Join(entry.Strengths, "|") != "500 mg" {
+		t.Fatalf("Amoxsan = %+v, %v", entry, ok)
+	}
+
+	if _, err := LoadFormulary(writeTestFile(t, "formulary.csv", "Merek,Kekuatan\nAmoxsan,500 mg\n")); err == nil {
+		t.Fatal("a formulary without a generic column was loaded")
+	}
+	if _, err := LoadFormulary(writeTestFile(t, "formulary.txt", testFormularyCSV)); err == nil {
+		t.Fatal("a formulary with an unknown extension was loaded")
+	}
+}
+
+func TestLoadFormularyXLSX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formulary.xlsx")
+	workbook := excelize.NewFile()
+	sheet := workbook.GetSheetName(0)
+	for i, row := range [][]interface{}{{"Nama Generik", "Nama Dagang", "Sediaan"}, {"Ibuprofen", "Proris", "200 mg; 400 mg"}} {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := workbook.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatalf("SetSheetRow: %v", err)
+		}
+	}
+	if err := workbook.SaveAs(path); err != nil {
+		t.Fatalf("SaveAs: %v", err)
+	}
+
+	formulary, err := LoadFormulary(path)
+	if err != nil {
+		t.Fatalf("LoadFormulary: %v", err)
+	}
+	entry, ok := formulary.Lookup("proris")
+	if !ok || strings.Join(entry.Strengths, "|") != "200 mg|400 mg" {
+		t.Fatalf("Proris = %+v, %v", entry, ok)
+	}
+}
+
+func TestFormularyCheckRejectsExtraWords(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	errs := formulary.Check([]MedicationItem{{Name: "Paracetamol CTM Ambroxol"}})
+	if len(errs) != 1 || errs[0].Tag != TagFormulary || errs[0].Value != "Paracetamol CTM Ambroxol" {
+		t.Fatalf("errors = %v, want the whole name reported as unknown", errs)
+	}
+	if got := strings.Join(errs[0].Suggestions, "|"); got != "Paracetamol" {
+		t.Fatalf("suggestions = %s, want Paracetamol from the first word", got)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
+)
+
+// MessageGateway delivers WhatsApp messages and returns the ID the engine assigned to them.
+type MessageGateway interface {
+	Send(ctx context.Context, phoneNumber, message string) (string, error)
+}
+
+// --- GowaGateway sends messages through the GOWA REST API ---
+type GowaGateway struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewGowaGateway(baseURL, username, password string, timeout time.Duration) *GowaGateway {
+	return &GowaGateway{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		// One shared client so connections to GOWA are kept alive between messages
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+// gowaSendResponse is the body GOWA returns from /send/message
+type gowaSendResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Results struct {
+		MessageID string `json:"message_id"`
+		Status    string `json:"status"`
+	} `json:"results"`
+}
+
+func (g *GowaGateway) Send(ctx context.Context, phoneNumber, message string) (string, error) {
+	url := fmt.Sprintf("%s/send/message", g.baseURL)
+
+	// Prepare the request payload
+	payload := map[string]interface{}{
+		"phone":   phoneNumber,
+		"message": message,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", &exception.InternalServerError{Message: "Failed to marshal message"}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", &exception.InternalServerError{Message: "Failed to create request"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(g.username, g.password)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", &exception.InternalServerError{Message: "Failed to send message: " + err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &exception.InternalServerError{Message: fmt.Sprintf("WhatsApp API returned error: %d - %s", resp.StatusCode, string(body))}
+	}
+
+	var result gowaSendResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		// The message was accepted, only the ID is unknown
+		return "", nil
+	}
+	return result.Results.MessageID, nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Interaction severities, from least to most serious
+const (
+	SeverityMinor    = "minor"
+	SeverityModerate = "moderate"
+	SeveritySevere   = "severe"
+)
+
+// severityAliases maps the severities an interaction table may use to the ones above
+var severityAliases = map[string]string{
+	"minor": SeverityMinor, "ringan": SeverityMinor, "low": SeverityMinor,
+	"moderate": SeverityModerate, "sedang": SeverityModerate, "medium": SeverityModerate,
+	"severe": SeveritySevere, "major": SeveritySevere, "berat": SeveritySevere, "high": SeveritySevere,
+	"contraindicated": SeveritySevere, "kontraindikasi": SeveritySevere,
+}
+
+// Kinds of medication warnings
+const (
+	WarningInteraction = "interaction"
+	WarningDuplicate   = "duplicate"
+)
+
+// Interaction is a pair of active ingredients that should not be given together
+type Interaction struct {
+	DrugA       string `json:"drug_a"`
+	DrugB       string `json:"drug_b"`
+	Severity    string `json:"severity"`
+	Description string `json:"description,omitempty"`
+}
+
+// InteractionTable holds the known interactions by pair of lowercased ingredient names.
+// It is read once from a CSV or XLSX file whose first row names the columns: drug_a (obat a),
+// drug_b (obat b), severity (tingkat) and description (keterangan).
+type InteractionTable struct {
+	pairs map[[2]string]Interaction
+}
+
+// interactionColumns are the header names each column may have
+var interactionColumns = map[string][]string{
+	"drug_a":      {"drug_a", "drug a", "obat a", "obat_a"},
+	"drug_b":      {"drug_b", "drug b", "obat b", "obat_b"},
+	"severity":    {"severity", "tingkat", "keparahan"},
+	"description": {"description", "keterangan", "deskripsi"},
+}
+
+// LoadInteractions reads the interaction table, the format follows the extension (.csv or .xlsx)
+func LoadInteractions(path string) (*InteractionTable, error) {
+	rows, columns, err := readTable(path, interactionColumns, "drug_a")
+	if err != nil {
+		return nil, fmt.Errorf("unable to load interactions: %v", err)
+	}
+	if _, ok := columns["drug_b"]; !ok {
+		return nil, fmt.Errorf("unable to load interactions: %s has no drug_b column", path)
+	}
+
+	t := &InteractionTable{pairs: make(map[[2]string]Interaction)}
+	for i, row := range rows {
+		interaction := Interaction{
+			DrugA:       strings.TrimSpace(cellAt(row, columns, "drug_a")),
+			DrugB:       strings.TrimSpace(cellAt(row, columns, "drug_b")),
+			Description: strings.TrimSpace(cellAt(row, columns, "description")),
+		}
+		if interaction.DrugA == "" || interaction.DrugB == "" {
+			continue
+		}
+
+		written := strings.ToLower(strings.TrimSpace(cellAt(row, columns, "severity")))
+		if written == "" {
+			written = SeverityModerate
+		}
+		severity, ok := severityAliases[written]
+		if !ok {
+			return nil, fmt.Errorf("unable to load interactions: unknown severity %q in row %d of %s", written, i+2, path)
+		}
+		interaction.Severity = severity
+		t.pairs[interactionKey(interaction.DrugA, interaction.DrugB)] = interaction
+	}
+	return t, nil
+}
+
+// Len returns the number of known interactions
+func (t *InteractionTable) Len() int {
+	return len(t.pairs)
+}
+
+// Find returns the interaction between two ingredients, in either order
+func (t *InteractionTable) Find(a, b string) (Interaction, bool) {
+	interaction, ok := t.pairs[interactionKey(a, b)]
+	return interaction, ok
+}
+
+// MedicationWarning is an interaction between two medication lines, or two lines with the same active ingredient
+type MedicationWarning struct {
+	Kind        string   // WarningInteraction or WarningDuplicate
+	Drugs       []string // The names of the two medication lines as the doctor wrote them
+	Ingredient  string   // The shared ingredient of a duplicate
+	Severity    string   // Severity of an interaction
+	Description string
+}
+
+// CheckMedications compares every pair of medication lines for duplicate active ingredients
+// and known interactions. Lines are resolved through the formulary when there is one, so brand
+// names count as their ingredients; without it the name of the line is the ingredient.
+// Either formulary or interactions may be nil. Severe interactions come first.
+func CheckMedications(items []MedicationItem, formulary *Formulary, interactions *InteractionTable) []MedicationWarning {
+	ingredients := make([][]string, len(items))
+	for i, item := range items {
+		ingredients[i] = ingredientsOf(item, formulary)
+	}
+
+	var warnings []MedicationWarning
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			drugs := []string{items[i].Name, items[j].Name}
+			for _, a := range ingredients[i] {
+				for _, b := range ingredients[j] {
+					if strings.EqualFold(a, b) {
+						warnings = append(warnings, MedicationWarning{Kind: WarningDuplicate, Drugs: drugs, Ingredient: a})
+						continue
+					}
+					if interactions == nil {
+						continue
+					}
+					if interaction, ok := interactions.Find(a, b); ok {
+						warnings = append(warnings, MedicationWarning{
+							Kind:        WarningInteraction,
+							Drugs:       drugs,
+							Severity:    interaction.Severity,
+							Description: interaction.Description,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Severity == SeveritySevere && warnings[j].Severity != SeveritySevere
+	})
+	return warnings
+}
+
+// HasSevereInteraction reports whether one of the warnings is a severe interaction
+func HasSevereInteraction(warnings []MedicationWarning) bool {
+	for _, warning := range warnings {
+		if warning.Kind == WarningInteraction && warning.Severity == SeveritySevere {
+			return true
+		}
+	}
+	return false
+}
+
+func ingredientsOf(item MedicationItem, formulary *Formulary) []string {
+	if formulary != nil {
+		if entry, ok := formulary.Lookup(item.Name); ok {
+			return entry.ActiveIngredients()
+		}
+	}
+	return []string{item.Name}
+}
+
+// interactionKey is the same for a pair in either order
+func interactionKey(a, b string) [2]string {
+	a, b = strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b))
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// testInteractionsCSV has Indonesian headers, severity aliases and a row without a severity
+const testInteractionsCSV = `Obat A,Obat B,Tingkat,Keterangan
+Ibuprofen,Paracetamol,ringan,Dipantau
+Clavulanic acid,Warfarin,Kontraindikasi,Risiko perdarahan
+Cetirizine,Ibuprofen,,
+Amoxicillin,Methotrexate,Major,Toksisitas methotrexate
+`
+
+func loadTestInteractions(t *testing.T) *InteractionTable {
+	t.Helper()
+
+	interactions, err := LoadInteractions(writeTestFile(t, "interactions.csv", testInteractionsCSV))
+	if err != nil {
+		t.Fatalf("LoadInteractions: %v", err)
+	}
+	return interactions
+}
+
+func TestLoadInteractions(t *testing.T) {
+	interactions := loadTestInteractions(t)
+
+	if interactions.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", interactions.Len())
+	}
+
+	tests := []struct {
+		a, b     string
+		severity string
+	}{
+		{"Ibuprofen", "Paracetamol", SeverityMinor},
+		// Pairs are found in either order and case
+		{"paracetamol", "IBUPROFEN", SeverityMinor},
+		{"Warfarin", "Clavulanic acid", SeveritySevere},
+		{"Methotrexate", "Amoxicillin", SeveritySevere},
+		// A row without a severity is moderate
+		{"Ibuprofen", "Cetirizine", SeverityModerate},
+	}
+	for _, tt := range tests {
+		interaction, ok := interactions.Find(tt.a, tt.b)
+		if !ok || interaction.Severity != tt.severity {
+			t.Errorf("Find(%s, %s) = %+v, %v, want severity %s", tt.a, tt.b, interaction, ok, tt.severity)
+		}
+	}
+	if _, ok := interactions.Find("Paracetamol", "Cetirizine"); ok {
+		t.Error("Find returned an interaction that is not in the table")
+	}
+}
+
+func TestLoadInteractionsRejectsBadFiles(t *testing.T) {
+	files := map[string]string{
+		"unknown severity": "drug_a,drug_b,severity\nIbuprofen,Warfarin,fatal\n",
+		"no drug_b column": "drug_a,severity\nIbuprofen,severe\n",
+		"no drug_a column": "obat b,tingkat\nIbuprofen,berat\n",
+	}
+	for name, content := range files {
+		if _, err := LoadInteractions(writeTestFile(t, "interactions.csv", content)); err == nil {
+			t.Errorf("%s: the table was loaded", name)
+		}
+	}
+}
+
+func TestCheckMedicationsDuplicates(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	tests := []struct {
+		name        string
+		items       []string
+		ingredients []string // Shared ingredient of each duplicate warning
+	}{
+		{"same generic", []string{"Paracetamol", "paracetamol"}, []string{"Paracetamol"}},
+		{"brand and generic", []string{"Sanmol", "Paracetamol"}, []string{"Paracetamol"}},
+		{"combination drug", []string{"Augmentin", "Amoxsan"}, []string{"Amoxicillin"}},
+		{"different drugs", []string{"Paracetamol", "Cetirizine"}, nil},
+		// Without a formulary entry the name is the ingredient
+		{"unknown names", []string{"Vitamin C", "vitamin c"}, []string{"Vitamin C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []MedicationItem
+			for _, name := range tt.items {
+				items = append(items, MedicationItem{Name: name})
+			}
+
+			warnings := CheckMedications(items, formulary, nil)
+			if len(warnings) != len(tt.ingredients) {
+				t.Fatalf("warnings = %+v, want %d duplicates", warnings, len(tt.ingredients))
+			}
+			for i, warning := range warnings {
+				if warning.Kind != WarningDuplicate || warning.Ingredient != tt.ingredients[i] || strings.Join(warning.Drugs, "|") != strings.Join(tt.items, "|") {
+					t.Errorf("warning %d = %+v, want a duplicate of %s", i, warning, tt.ingredients[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckMedicationsInteractions(t *testing.T) {
+	formulary := loadTestFormulary(t)
+	interactions := loadTestInteractions(t)
+
+	items := []MedicationItem{
+		{Name: "Proris"},    // Ibuprofen
+		{Name: "Panadol"},   // Paracetamol, minor with Ibuprofen
+		{Name: "Augmentin"}, // Amoxicillin and Clavulanic acid
+		{Name: "Warfarin"},  // Not in the formulary, severe with Clavulanic acid
+		{Name: "Cetirizine"},
+	}
+
+	warnings := CheckMedications(items, formulary, interactions)
+	if len(warnings) != 3 {
+		t.Fatalf("warnings = %+v, want 3 interactions", warnings)
+	}
+
+	// The severe interaction comes first, the others keep the order of the lines
+	want := []struct {
+		drugs    string
+		severity string
+	}{
+		{"Augmentin|Warfarin", SeveritySevere},
+		{"Proris|Panadol", SeverityMinor},
+		{"Proris|Cetirizine", SeverityModerate},
+	}
+	for i, w := range want {
+		if warnings[i].Kind != WarningInteraction || strings.Join(warnings[i].Drugs, "|") != w.drugs || warnings[i].Severity != w.severity {
+			t.Errorf("warning %d = %+v, want %s %s", i, warnings[i], w.drugs, w.severity)
+		}
+	}
+	if !HasSevereInteraction(warnings) {
+		t.Error("HasSevereInteraction = false")
+	}
+	if HasSevereInteraction(warnings[1:]) {
+		t.Error("HasSevereInteraction = true without a severe interaction")
+	}
+}
+
+func TestCheckMedicationsWithoutTables(t *testing.T) {
+	items := []MedicationItem{{Name: "Ibuprofen"}, {Name: "Paracetamol"}}
+
+	if warnings := CheckMedications(items, nil, nil); len(warnings) != 0 {
+		t.Fatalf("warnings = %+v, want none without an interaction table", warnings)
+	}
+	if warnings := CheckMedications(items, nil, loadTestInteractions(t)); len(warnings) != 1 {
+		t.Fatalf("warnings = %+v, want the interaction found by name", warnings)
+	}
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
+)
+
+// StockItem is how much the pharmacy has of a formulary drug in one strength.
+// Strength is empty for drugs the formulary lists without strengths.
+type StockItem struct {
+	Generic   string    `json:"generic"`
+	Strength  string    `json:"strength,omitempty"`
+	Quantity  int       `json:"quantity"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StockWarning is a medication line the pharmacy is low on or out of
+type StockWarning struct {
+	Name      string // The medication line as the doctor wrote it, e.g. "Amoxicillin 500 mg"
+	Available int
+	Needed    int
+	Out       bool // There is not enough for this prescription
+}
+
+// Inventory tracks the stock of formulary drugs in a JSON file, keyed by generic name and strength.
+// Only drugs that were restocked at least once are tracked, other medication lines are ignored.
+type Inventory struct {
+	mu        sync.Mutex
+	path      string
+	formulary *Formulary
+	lowAt     int // A quantity below this after dispensing is low stock
+	items     map[string]*StockItem
+}
+
+func NewInventory(path string, formulary *Formulary, lowAt int) (*Inventory, error) {
+	inv := &Inventory{
+		path:      path,
+		formulary: formulary,
+		lowAt:     lowAt,
+		items:     make(map[string]*StockItem),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &inv.items); err != nil {
+			return nil, err
+		}
+	}
+	return inv, nil
+}
+
+// List returns every tracked drug sorted by name and strength
+func (inv *Inventory) List() []StockItem {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	items := []StockItem{}
+	for _, item := range inv.items {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Generic != items[j].Generic {
+			return items[i].Generic < items[j].Generic
+		}
+		return items[i].Strength < items[j].Strength
+	})
+	return items
+}
+
+// Restock adds quantity to the stock of a drug. The name may be a generic or brand name of the
+// formulary, and the strength must be one the formulary lists for it.
+func (inv *Inventory) Restock(name, strength string, quantity int) (*StockItem, error) {
+	if quantity <= 0 {
+		return nil, &exception.BadRequestError{Message: "Quantity must be more than 0"}
+	}
+
+	entry, ok := inv.formulary.Lookup(name)
+	if !ok {
+		message := fmt.Sprintf("%s is not in the formulary", name)
+		if suggestions := inv.formulary.Suggest(name); len(suggestions) > 0 {
+			message += ", did you mean " + strings.Join(suggestions, ", ")
+		}
+		return nil, &exception.BadRequestError{Message: message}
+	}
+	if !entry.HasStrength(strength) {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("%s is not available in %s, the formulary lists %s", entry.Generic, strength, strings.Join(entry.Strengths, ", "))}
+	}
+	strength = entry.strengthAs(strength)
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	key := stockKey(entry.Generic, strength)
+	item, exists := inv.items[key]
+	if !exists {
+		item = &StockItem{Generic: entry.Generic, Strength: strength}
+		inv.items[key] = item
+	}
+	previous := *item
+	item.Quantity += quantity
+	item.UpdatedAt = time.Now()
+
+	if err := inv.save(); err != nil {
+		if exists {
+			*item = previous
+		} else {
+			delete(inv.items, key)
+		}
+		return nil, err
+	}
+	c := *item
+	return &c, nil
+}
+
+// Warnings returns the medication lines the pharmacy cannot fill or that leave it low on stock
+func (inv *Inventory) Warnings(medications []MedicationItem) []StockWarning {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	var warnings []StockWarning
+	for _, medication := range medications {
+		item := inv.find(medication)
+		if item == nil {
+			continue
+		}
+
+		warning := StockWarning{
+			Name:      strings.TrimSpace(medication.Name + " " + medication.Strength),
+			Available: item.Quantity,
+			Needed:    medication.Quantity,
+			Out:       item.Quantity <= 0 || item.Quantity < medication.Quantity,
+		}
+		if warning.Out || item.Quantity-medication.Quantity < inv.lowAt {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// Dispense takes the medications of a prescription out of stock once the patient picked it up.
+// It is meant to be used as a prescription store change hook.
+func (inv *Inventory) Dispense(prescription *Prescription) {
+	if prescription.Status != StatusPickedUp {
+		return
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	// Two lines of the same drug share an item, the first copy is the one to restore
+	previous := make(map[*StockItem]StockItem)
+	for _, medication := range prescription.Details.Medications {
+		item := inv.find(medication)
+		if item == nil || medication.Quantity == 0 {
+			continue
+		}
+		if _, ok := previous[item]; !ok {
+			previous[item] = *item
+		}
+		if item.Quantity < medication.Quantity {
+			log.Printf("Prescription %s dispensed %d of %s %s with only %d in stock", prescription.ID, medication.Quantity, item.Generic, item.Strength, item.Quantity)
+		}
+		item.Quantity = max(item.Quantity-medication.Quantity, 0)
+		item.UpdatedAt = time.Now()
+	}
+
+	if len(previous) > 0 {
+		if err := inv.save(); err != nil {
+			for item, stock := range previous {
+				*item = stock
+			}
+			log.Printf("Unable to save stock after dispensing prescription %s, the stock is unchanged: %v", prescription.ID, err)
+		}
+	}
+}
+
+// find returns the stock of a medication line, the stock without strength when the strength is not tracked.
+// The caller holds the lock.
+func (inv *Inventory) find(medication MedicationItem) *StockItem {
+	entry, ok := inv.formulary.Lookup(medication.Name)
+	if !ok {
+		return nil
+	}
+	if item, ok := inv.items[stockKey(entry.Generic, entry.strengthAs(medication.Strength))]; ok {
+		return item
+	}
+	return inv.items[stockKey(entry.Generic, "")]
+}
+
+func (inv *Inventory) save() error {
+	if inv.path == "" {
+		return nil
+	}
+	return writeJSONFile(inv.path, inv.items)
+}
+
+func stockKey(generic, strength string) string {
+	return strings.ToLower(generic) + "|" + normalizeStrength(strength)
+}
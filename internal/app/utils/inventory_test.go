@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestInventory(t *testing.T, lowAt int) *Inventory {
+	t.Helper()
+
+	inventory, err := NewInventory("", loadTestFormulary(t), lowAt)
+	if err != nil {
+		t.Fatalf("NewInventory: %v", err)
+	}
+	return inventory
+}
+
+func mustRestock(t *testing.T, inventory *Inventory, name, strength string, quantity int) {
+	t.Helper()
+
+	if _, err := inventory.Restock(name, strength, quantity); err != nil {
+		t.Fatalf("Restock %s %s: %v", name, strength, err)
+	}
+}
+
+func TestInventoryRestock(t *testing.T) {
+	inventory := newTestInventory(t, 10)
+
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 30)
+	// A brand name and another way of writing the strength add to the same stock
+	item, err := inventory.Restock("Kalmoxillin", "500MG", 20)
+	if err != nil {
+		t.Fatalf("Restock: %v", err)
+	}
+	if item.Generic != "Amoxicillin" || item.Strength != "500 mg" || item.Quantity != 50 {
+		t.Fatalf("item = %+v, want 50 of Amoxicillin 500 mg", item)
+	}
+	// A drug the formulary lists without strengths is tracked without one
+	mustRestock(t, inventory, "Cetirizine", "", 5)
+
+	items := inventory.List()
+	if len(items) != 2 || items[0].Generic != "Amoxicillin" || items[1].Generic != "Cetirizine" || items[1].Strength != "" {
+		t.Fatalf("items = %+v", items)
+	}
+
+	for _, bad := range []struct {
+		name, strength string
+		quantity       int
+	}{
+		{"Amoxicillin", "500 mg", 0},
+		{"Amoxilin", "500 mg", 10},
+		{"Amoxicillin", "1 g", 10},
+	} {
+		if _, err := inventory.Restock(bad.name, bad.strength, bad.quantity); err == nil {
+			t.Errorf("Restock(%s, %s, %d) succeeded", bad.name, bad.strength, bad.quantity)
+		}
+	}
+}
+
+func TestInventoryRestockCanBeRepeatedAfterASaveError(t *testing.T) {
+	inventory := newTestInventory(t, 10)
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 30)
+
+	// The pharmacist gets an error and sends the same /restock again, the delivery must count once
+	inventory.path = unwritablePath(t)
+	if _, err := inventory.Restock("Amoxicillin", "500 mg", 20); err == nil {
+		t.Fatal("Restock succeeded although the stock could not be saved")
+	}
+	if _, err := inventory.Restock("Ibuprofen", "400 mg", 20); err == nil {
+		t.Fatal("Restock of a new drug succeeded although the stock could not be saved")
+	}
+	inventory.path = ""
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 20)
+	mustRestock(t, inventory, "Ibuprofen", "400 mg", 20)
+
+	items := inventory.List()
+	if len(items) != 2 || items[0].Quantity != 50 || items[1].Quantity != 20 {
+		t.Fatalf("items = %+v, want 50 Amoxicillin and 20 Ibuprofen", items)
+	}
+}
+
+func TestInventoryWarnings(t *testing.T) {
+	inventory := newTestInventory(t, 10)
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 25)
+	mustRestock(t, inventory, "Paracetamol", "500 mg", 100)
+	mustRestock(t, inventory, "Cetirizine", "", 3)
+
+	warnings := inventory.Warnings([]MedicationItem{
+		{Name: "Amoxicillin", Strength: "500mg", Quantity: 20},  // 5 left is low
+		{Name: "Sanmol", Strength: "500 mg", Quantity: 10},      // Plenty left
+		{Name: "Cetirizine", Strength: "10 mg", Quantity: 5},    // Not enough, tracked without strength
+		{Name: "Amoxicillin", Strength: "250 mg", Quantity: 10}, // Not tracked
+		{Name: "Ibuprofen", Quantity: 10},                       // Never restocked
+		{Name: "Omeprazole", Quantity: 10},                      // Not in the formulary
+	})
+
+	want := []StockWarning{
+		{Name: "Amoxicillin 500mg", Available: 25, Needed: 20},
+		{Name: "Cetirizine 10 mg", Available: 3, Needed: 5, Out: true},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %+v, want %+v", warnings, want)
+	}
+	for i := range want {
+		if warnings[i] != want[i] {
+			t.Errorf("warning %d = %+v, want %+v", i, warnings[i], want[i])
+		}
+	}
+}
+
+func TestInventoryDispensesOnPickUpOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stock.json")
+	formulary := loadTestFormulary(t)
+	inventory, err := NewInventory(path, formulary, 10)
+	if err != nil {
+		t.Fatalf("NewInventory: %v", err)
+	}
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 30)
+	mustRestock(t, inventory, "Cetirizine", "", 4)
+
+	store, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	store.OnChange(inventory.Dispense)
+
+	details := &PatientDetails{PatientName: "Siti Aminah", Medications: []MedicationItem{
+		{Name: "Kalmoxillin", Strength: "500mg", Quantity: 15},
+		{Name: "Cetirizine", Strength: "10 mg", Quantity: 10}, // More than in stock
+		{Name: "Ibuprofen", Strength: "400 mg", Quantity: 10}, // Not tracked
+	}}
+	prescription, err := store.Add(NewPrescriptionRecord(details, "6289999999999", 1, time.Now()), "6289999999999", "6281111111111")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	for _, status := range []string{StatusPreparing, StatusReady} {
+		if _, err := store.UpdateStatus(prescription.ID, status, "6289999999999"); err != nil {
+			t.Fatalf("UpdateStatus %s: %v", status, err)
+		}
+	}
+	if items := inventory.List(); items[0].Quantity != 30 || items[1].Quantity != 4 {
+		t.Fatalf("items = %+v, stock was taken before the patient picked up", items)
+	}
+
+	if _, err := store.UpdateStatus(prescription.ID, StatusPickedUp, "6289999999999"); err != nil {
+		t.Fatalf("UpdateStatus picked up: %v", err)
+	}
+	if items := inventory.List(); items[0].Quantity != 15 || items[1].Quantity != 0 {
+		t.Fatalf("items = %+v, want 15 Amoxicillin and no Cetirizine left", items)
+	}
+
+	// A picked up prescription cannot be picked up again, so it is never dispensed twice
+	if _, err := store.UpdateStatus(prescription.ID, StatusPickedUp, "6289999999999"); err == nil {
+		t.Fatal("the prescription was picked up twice")
+	}
+
+	// The decrement was saved
+	reloaded, err := NewInventory(path, formulary, 10)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if items := reloaded.List(); items[0].Quantity != 15 || items[1].Quantity != 0 {
+		t.Fatalf("reloaded items = %+v", items)
+	}
+}
+
+func TestInventoryKeepsTheStockWhenDispensingCannotBeSaved(t *testing.T) {
+	inventory := newTestInventory(t, 10)
+	mustRestock(t, inventory, "Amoxicillin", "500 mg", 30)
+	inventory.path = unwritablePath(t)
+
+	// Both lines are the same stock item, restoring must not stop at 20 left after the first one
+	inventory.Dispense(&Prescription{
+		PrescriptionRecord: PrescriptionRecord{ID: "RX-1", Details: PatientDetails{Medications: []MedicationItem{
+			{Name: "Amoxicillin", Strength: "500 mg", Quantity: 10},
+			{Name: "Amoxsan", Strength: "500mg", Quantity: 5},
+		}}},
+		Status: StatusPickedUp,
+	})
+	if items := inventory.List(); items[0].Quantity != 30 {
+		t.Fatalf("items = %+v, want the 30 that were saved", items)
+	}
+}
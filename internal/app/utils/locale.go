@@ -0,0 +1,59 @@
+package utils
+
+import "sync"
+
+// LocaleStore remembers the language each phone number wants the bot to use.
+// It covers doctors, pharmacists, admins and patients alike, keyed by normalized phone number.
+type LocaleStore struct {
+	mu      sync.Mutex
+	path    string
+	locales map[string]string
+}
+
+func NewLocaleStore(path string) (*LocaleStore, error) {
+	s := &LocaleStore{
+		path:    path,
+		locales: make(map[string]string),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &s.locales); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Get returns the locale chosen by the phone number, or an empty string when it never chose one.
+func (s *LocaleStore) Get(phoneNumber string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.locales[NormalizePhone(phoneNumber)]
+}
+
+// Set stores the locale of the phone number.
+func (s *LocaleStore) Set(phoneNumber, locale string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := NormalizePhone(phoneNumber)
+	previous, existed := s.locales[key]
+	s.locales[key] = locale
+
+	if err := s.save(); err != nil {
+		if existed {
+			s.locales[key] = previous
+		} else {
+			delete(s.locales, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *LocaleStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return writeJSONFile(s.path, s.locales)
+}
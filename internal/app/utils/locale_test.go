@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocaleStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locales.json")
+	locales, err := NewLocaleStore(path)
+	if err != nil {
+		t.Fatalf("NewLocaleStore: %v", err)
+	}
+
+	if got := locales.Get("6281111111111"); got != "" {
+		t.Fatalf("locale of a new number = %q, want none", got)
+	}
+	if err := locales.Set("081111111111", "en"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := locales.Get("+62 811-1111-1111"); got != "en" {
+		t.Fatalf("locale = %q, want en for every way of writing the number", got)
+	}
+
+	reloaded, err := NewLocaleStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := reloaded.Get("6281111111111"); got != "en" {
+		t.Fatalf("reloaded locale = %q, want en", got)
+	}
+}
+
+func TestLocaleStoreAnswersInTheSavedLanguageAfterAFailedSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locales.json")
+	locales, err := NewLocaleStore(path)
+	if err != nil {
+		t.Fatalf("NewLocaleStore: %v", err)
+	}
+	if err := locales.Set("6281111111111", "en"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// /lang id is answered with an error, the next replies must not switch language until a restart switches back
+	locales.path = unwritablePath(t)
+	if err := locales.Set("6281111111111", "id"); err == nil {
+		t.Fatal("Set succeeded although the locales could not be saved")
+	}
+	if err := locales.Set("6282222222222", "en"); err == nil {
+		t.Fatal("Set of a new number succeeded although the locales could not be saved")
+	}
+
+	restarted, err := NewLocaleStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	for _, phoneNumber := range []string{"6281111111111", "6282222222222"} {
+		if got, want := locales.Get(phoneNumber), restarted.Get(phoneNumber); got != want {
+			t.Errorf("locale of %s = %q before the restart and %q after it", phoneNumber, got, want)
+		}
+	}
+}
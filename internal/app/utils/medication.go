@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MedicationItem is one line of a prescription, e.g. "Amoxicillin 500mg tab no. XV 3dd1"
+type MedicationItem struct {
+	Name         string `json:"name"`
+	Strength     string `json:"strength,omitempty"`      // e.g. "500 mg"
+	Form         string `json:"form,omitempty"`          // e.g. "tablet"
+	Quantity     int    `json:"quantity,omitempty"`      // 0 when the line has no quantity
+	QuantityText string `json:"quantity_text,omitempty"` // As written, e.g. "XV"
+	Signa        string `json:"signa,omitempty"`         // e.g. "3dd1"
+	Raw          string `json:"raw"`
+}
+
+// medicationForms maps the dosage forms doctors write to one name per form
+var medicationForms = map[string]string{
+	"tab": "tablet", "tabs": "tablet", "tablet": "tablet", "tablets": "tablet",
+	"cap": "kapsul", "caps": "kapsul", "kaps": "kapsul", "kapsul": "kapsul", "capsule": "kapsul", "capsules": "kapsul",
+	"syr": "sirup", "sir": "sirup", "sirup": "sirup", "syrup": "sirup",
+	"susp": "suspensi", "suspensi": "suspensi", "suspension": "suspensi",
+	"drop": "tetes", "drops": "tetes", "gtt": "tetes", "tetes": "tetes",
+	"inj": "injeksi", "injeksi": "injeksi", "injection": "injeksi",
+	"amp": "ampul", "ampul": "ampul", "ampoule": "ampul",
+	"ung": "salep", "salep": "salep", "ointment": "salep",
+	"cr": "krim", "crm": "krim", "krim": "krim", "cream": "krim",
+	"gel": "gel", "lotion": "lotion",
+	"supp": "suppositoria", "suppositoria": "suppositoria", "suppository": "suppositoria",
+	"pulv": "puyer", "puyer": "puyer",
+	"sach": "sachet", "sachet": "sachet",
+	"fl": "botol", "btl": "botol", "botol": "botol", "bottle": "botol",
+	"tube": "tube", "inhaler": "inhaler",
+}
+
+// strengthUnits are the units a strength can be written in, lowercased
+var strengthUnits = map[string]string{
+	"mg": "mg", "mcg": "mcg", "µg": "mcg", "ug": "mcg", "g": "g", "gr": "g",
+	"ml": "ml", "iu": "IU", "ui": "IU", "unit": "unit", "%": "%",
+}
+
+var (
+	// medicationNumbering is the "1.", "2)", "R/" or "-" a doctor may start a line with
+	medicationNumbering = regexp.MustCompile(`^(?i)(?:\d+\s*[.)]|r/|-)\s*`)
+	// quantityRegex finds "no. XV", "No 10", "#15" or "jml 2". Roman numerals go up to C, so words
+	// like the "mix" of "no mix" are not read as a quantity.
+	quantityRegex = regexp.MustCompile(`(?i)(?:^|\s)(?:no|nomor|jml|jumlah)(?:\.\s*|\s+)([ivxlc]+|\d+)\b|#\s*(\d+|[ivxlc]+)\b`)
+	// signaRegex finds a dosing instruction like "3dd1", "3 x 1", "S 2dd 1 tab" or "Signa: 3dd1" when there is no quantity
+	signaRegex = regexp.MustCompile(`(?i)(?:^|\s)(?:signa\s*:?\s*|s\.?\s*)?\d+\s*(?:dd|x)\s*\S.*$`)
+	// signaPrefix is the "S." or "Signa:" that starts a signa
+	signaPrefix = regexp.MustCompile(`^(?i)(?:signa\s*:?\s*|s(?:\.\s*|\s+))`)
+	// signaLineRegex matches a line that only holds the signa of the drug above, e.g. "Signa: 3dd1" or "S. 3dd1"
+	signaLineRegex = regexp.MustCompile(`^(?i)(?:signa\b|s\.|s\s+\d|\d+\s*(?:dd|x)\s*\d)`)
+	// strengthRegex finds "500mg", "2,5 ml", "1 g/5 ml" or "0.1%"
+	strengthRegex = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*([a-zA-Zµ%]+)(\s*/\s*\d*(?:[.,]\d+)?\s*[a-zA-Z]+)?`)
+	// romanRegex matches a valid Roman numeral
+	romanRegex = regexp.MustCompile(`^(?i)M{0,3}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+)
+
+// QuantityError is returned for a quantity that is neither a number nor a valid Roman numeral, e.g. "IIII"
+type QuantityError struct {
+	Line     string
+	Quantity string
+}
+
+func (e *QuantityError) Error() string {
+	return fmt.Sprintf("%s is not a valid quantity in %q", e.Quantity, e.Line)
+}
+
+// ParseMedications splits the medication field into lines, on new lines and on commas between
+// drugs, and parses every line.
+func ParseMedications(input string) ([]MedicationItem, error) {
+	var items []MedicationItem
+	for _, line := range splitMedicationLines(input) {
+		item, err := ParseMedicationLine(line)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// ParseMedicationLine reads drug name, strength, dosage form, quantity and signa from one line.
+// Parts it does not recognise stay in the name, so nothing the doctor wrote is lost.
+func ParseMedicationLine(line string) (*MedicationItem, error) {
+	line = strings.TrimSpace(medicationNumbering.ReplaceAllString(strings.TrimSpace(line), ""))
+	item := &MedicationItem{Raw: line}
+
+	head, tail := line, ""
+	if loc := quantityRegex.FindStringSubmatchIndex(line); loc != nil {
+		text := submatch(line, loc, 1)
+		if text == "" {
+			text = submatch(line, loc, 2)
+		}
+		quantity, ok := parseQuantity(text)
+		if !ok || quantity < 1 {
+			return nil, &QuantityError{Line: line, Quantity: text}
+		}
+		item.Quantity = quantity
+		item.QuantityText = strings.ToUpper(text)
+		head, tail = line[:loc[0]], line[loc[1]:]
+	} else if loc := signaRegex.FindStringIndex(line); loc != nil {
+		head, tail = line[:loc[0]], line[loc[0]:]
+	}
+
+	// The dosage form may also come after the quantity, e.g. "no. X tab 3dd1", and a comma may
+	// separate the quantity from the signa, e.g. "no. XV, 3dd1"
+	tailFields := strings.Fields(strings.TrimLeft(strings.TrimSpace(tail), ","))
+	if len(tailFields) > 0 {
+		if form, ok := medicationForms[strings.ToLower(strings.Trim(tailFields[0], "."))]; ok {
+			item.Form = form
+			tailFields = tailFields[1:]
+		}
+	}
+	item.Signa = signaPrefix.ReplaceAllString(strings.TrimLeft(strings.Join(tailFields, " "), ", "), "")
+
+	// Strength: the first number followed by a known unit
+	for _, loc := range strengthRegex.FindAllStringSubmatchIndex(head, -1) {
+		unit, ok := strengthUnits[strings.ToLower(submatch(head, loc, 2))]
+		if !ok {
+			continue
+		}
+		item.Strength = strings.Replace(submatch(head, loc, 1), ",", ".", 1)
+		if unit != "%" {
+			item.Strength += " "
+		}
+		item.Strength += unit
+		if per := strings.Join(strings.Fields(submatch(head, loc, 3)), ""); per != "" {
+			item.Strength += per
+		}
+		head = head[:loc[0]] + " " + head[loc[1]:]
+		break
+	}
+
+	// Dosage form and name from what is left
+	var name []string
+	for _, field := range strings.Fields(head) {
+		// A comma here separates the name from the strength, form or signa after it, e.g. "Ambroxol syr, 3x1 cth",
+		// every other comma split the line. A colon is the one of "Amoxicillin: 500mg".
+		if field = strings.TrimRight(field, ",:"); field == "" {
+			continue
+		}
+		if form, ok := medicationForms[strings.ToLower(strings.Trim(field, "."))]; ok {
+			// A second form word is the packaging, e.g. "krim tube"
+			item.Form = strings.TrimSpace(item.Form + " " + form)
+			continue
+		}
+		name = append(name, field)
+	}
+	item.Name = strings.Join(name, " ")
+	if item.Name == "" {
+		item.Name = line
+	}
+	return item, nil
+}
+
+// String renders the line the same way everywhere, e.g. "Amoxicillin 500 mg tablet, No. XV (15), S. 3dd1"
+func (m MedicationItem) String() string {
+	description := strings.Join(strings.Fields(strings.Join([]string{m.Name, m.Strength, m.Form}, " ")), " ")
+	parts := []string{description}
+	if m.QuantityText != "" {
+		quantity := "No. " + m.QuantityText
+		if m.QuantityText != strconv.Itoa(m.Quantity) {
+			quantity += fmt.Sprintf(" (%d)", m.Quantity)
+		}
+		parts = append(parts, quantity)
+	}
+	if m.Signa != "" {
+		parts = append(parts, "S. "+m.Signa)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatMedications numbers the lines, one per row
+func FormatMedications(items []MedicationItem) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, item)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitMedicationLines splits on new lines and on every comma between drugs, e.g. "Paracetamol, CTM".
+// A comma stays in the line when the text after it belongs to the drug before it: a decimal comma
+// like "2,5 mg", a signa like "3dd1" or an instruction like "sesudah makan". A line that only holds
+// a signa, e.g. "Signa: 3dd1", belongs to the drug above.
+func splitMedicationLines(input string) []string {
+	var lines []string
+	for _, text := range strings.Split(input, "\n") {
+		if trimmed := strings.TrimSpace(text); len(lines) > 0 && signaLineRegex.MatchString(trimmed) {
+			lines[len(lines)-1] += " " + trimmed
+			continue
+		}
+
+		line := ""
+		for i, piece := range splitOnCommas(text) {
+			if i > 0 && !continuesMedication(piece) {
+				if line = strings.TrimSpace(line); line != "" {
+					lines = append(lines, line)
+				}
+				line = ""
+			} else if i > 0 {
+				line += ","
+			}
+			line += piece
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitOnCommas splits on every comma that is not between two digits
+func splitOnCommas(text string) []string {
+	var pieces []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		isDecimalComma := i > 0 && i+1 < len(runes) && isDigit(runes[i-1]) && isDigit(runes[i+1])
+		if r == ',' && !isDecimalComma {
+			pieces = append(pieces, string(runes[start:i]))
+			start = i + 1
+		}
+	}
+	return append(pieces, string(runes[start:]))
+}
+
+// medicationInstructions are the words an instruction for the drug before a comma starts with,
+// lowercased, e.g. the "sesudah" of "3dd1, sesudah makan"
+var medicationInstructions = map[string]bool{
+	"sesudah": true, "setelah": true, "sebelum": true, "saat": true, "selama": true, "bila": true, "jika": true,
+	"kalau": true, "k/p": true, "prn": true, "pc": true, "ac": true, "ue": true, "ui": true, "cth": true,
+	"habiskan": true, "dihabiskan": true, "diminum": true, "dikunyah": true, "dioles": true, "oles": true,
+	"pagi": true, "siang": true, "sore": true, "malam": true,
+	"after": true, "before": true, "with": true, "as": true, "when": true, "if": true, "take": true,
+}
+
+// continuesMedication reports whether the text after a comma belongs to the drug before the comma:
+// it is empty, starts with a number, a quantity, a dosage form, a signa or an instruction word.
+func continuesMedication(piece string) bool {
+	piece = strings.TrimSpace(piece)
+	if piece == "" || signaPrefix.MatchString(piece) || signaLineRegex.MatchString(piece) {
+		return true
+	}
+	if numbered := medicationNumbering.FindString(piece); numbered != "" && numbered != piece {
+		// "Paracetamol 500mg, 2. CTM 4mg" numbers the next drug
+		first, _ := utf8.DecodeRuneInString(piece[len(numbered):])
+		if unicode.IsSpace(rune(numbered[len(numbered)-1])) && unicode.IsLetter(first) {
+			return false
+		}
+	}
+	first, _ := utf8.DecodeRuneInString(piece)
+	if !unicode.IsLetter(first) {
+		return true
+	}
+	if loc := quantityRegex.FindStringIndex(piece); loc != nil && loc[0] == 0 {
+		return true
+	}
+	word := strings.ToLower(strings.Trim(strings.Fields(piece)[0], ".:"))
+	if _, ok := medicationForms[word]; ok {
+		return true
+	}
+	return medicationInstructions[word]
+}
+
+// parseQuantity reads a quantity written in digits or as a Roman numeral
+func parseQuantity(text string) (int, bool) {
+	if n, err := strconv.Atoi(text); err == nil {
+		return n, true
+	}
+	if !romanRegex.MatchString(text) {
+		return 0, false
+	}
+
+	values := map[rune]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+	runes := []rune(strings.ToUpper(text))
+	total := 0
+	for i, r := range runes {
+		value := values[r]
+		if i+1 < len(runes) && value < values[runes[i+1]] {
+			total -= value
+		} else {
+			total += value
+		}
+	}
+	return total, true
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// submatch returns capture group n of a FindStringSubmatchIndex result, or "" when it did not match
+func submatch(s string, loc []int, n int) string {
+	if loc[2*n] < 0 {
+		return ""
+	}
+	return s[loc[2*n]:loc[2*n+1]]
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseMedicationLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want MedicationItem
+	}{
+		{
+			line: "Amoxicillin 500mg tab no. XV 3dd1",
+			want: MedicationItem{Name: "Amoxicillin", Strength: "500 mg", Form: "tablet", Quantity: 15, QuantityText: "XV", Signa: "3dd1"},
+		},
+		{
+			line: "Salbutamol 2mg no. x tab 3dd1",
+			want: MedicationItem{Name: "Salbutamol", Strength: "2 mg", Form: "tablet", Quantity: 10, QuantityText: "X", Signa: "3dd1"},
+		},
+		{
+			line: "R/ Cefadroxil 500mg caps #15 S. 2dd1",
+			want: MedicationItem{Name: "Cefadroxil", Strength: "500 mg", Form: "kapsul", Quantity: 15, QuantityText: "15", Signa: "2dd1"},
+		},
+		{
+			line: "Ambroxol 2,5 ml no 10 3x1",
+			want: MedicationItem{Name: "Ambroxol", Strength: "2.5 ml", Quantity: 10, QuantityText: "10", Signa: "3x1"},
+		},
+		{
+			line: "Paracetamol syr 120mg/5ml fl no. I S 3dd 1 cth",
+			want: MedicationItem{Name: "Paracetamol", Strength: "120 mg/5ml", Form: "sirup botol", Quantity: 1, QuantityText: "I", Signa: "3dd 1 cth"},
+		},
+		{
+			line: "Betamethasone 0,1% krim no IV ue",
+			want: MedicationItem{Name: "Betamethasone", Strength: "0.1%", Form: "krim", Quantity: 4, QuantityText: "IV", Signa: "ue"},
+		},
+		{
+			line: "1. Ibuprofen 400mg 3 x 1",
+			want: MedicationItem{Name: "Ibuprofen", Strength: "400 mg", Signa: "3 x 1"},
+		},
+		{
+			// "mix" is a word, not the Roman numeral 1009
+			line: "Puyer batuk no mix 3dd1",
+			want: MedicationItem{Name: "batuk no mix", Form: "puyer", Signa: "3dd1"},
+		},
+		{
+			line: "Vitamin B kompleks",
+			want: MedicationItem{Name: "Vitamin B kompleks"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := ParseMedicationLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseMedicationLine: %v", err)
+			}
+			got.Raw = ""
+			if *got != tt.want {
+				t.Fatalf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMedicationLineRejectsInvalidQuantity(t *testing.T) {
+	for _, line := range []string{"Vitamin C no. IIII", "CTM 4mg no VV", "Paracetamol #XIIII", "Paracetamol 500mg no 0"} {
+		_, err := ParseMedicationLine(line)
+		var quantityErr *QuantityError
+		if !errors.As(err, &quantityErr) {
+			t.Fatalf("%q: error = %v, want a QuantityError", line, err)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+		ok   bool
+	}{
+		{"15", 15, true},
+		{"XV", 15, true},
+		{"xv", 15, true},
+		{"IV", 4, true},
+		{"IX", 9, true},
+		{"XL", 40, true},
+		{"XC", 90, true},
+		{"IIII", 0, false},
+		{"VX", 0, false},
+		{"IL", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseQuantity(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseQuantity(%q) = %d, %v, want %d, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseMedicationsSplitsLines(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string // Names
+	}{
+		{"Paracetamol 500mg no X\nCTM 4mg no X", []string{"Paracetamol", "CTM"}},
+		{"1. Paracetamol 500mg no XX\n\n2) Ibuprofen 400mg no X", []string{"Paracetamol", "Ibuprofen"}},
+		// Every comma between drugs splits, whether or not the drug has a strength
+		{"Paracetamol, CTM, Ambroxol", []string{"Paracetamol", "CTM", "Ambroxol"}},
+		{"Paracetamol 500mg, CTM", []string{"Paracetamol", "CTM"}},
+		{"Paracetamol 500mg, CTM 4mg no. X", []string{"Paracetamol", "CTM"}},
+		{"Paracetamol 500mg, 2. CTM 4mg", []string{"Paracetamol", "CTM"}},
+		// A comma before a signa, an instruction, a quantity or a decimal stays in the line
+		{"Amoxicillin 500mg no. XV 3dd1, sesudah makan", []string{"Amoxicillin"}},
+		{"Amoxicillin 500mg no. XV, 3dd1", []string{"Amoxicillin"}},
+		{"Ambroxol syr 15mg/5ml, 3x1 cth", []string{"Ambroxol"}},
+		{"CTM 4mg, no. X, k/p", []string{"CTM"}},
+		{"Ambroxol 2,5 ml no 10", []string{"Ambroxol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			items, err := ParseMedications(tt.input)
+			if err != nil {
+				t.Fatalf("ParseMedications: %v", err)
+			}
+			var names []string
+			for _, item := range items {
+				names = append(names, item.Name)
+			}
+			if strings.Join(names, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("names = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMedicationsKeepsTheSignaAfterAComma(t *testing.T) {
+	tests := map[string]string{
+		"Amoxicillin 500mg no. XV 3dd1, sesudah makan": "3dd1, sesudah makan",
+		"Amoxicillin 500mg no. XV, 3dd1":               "3dd1",
+		"Ambroxol syr 15mg/5ml, 3x1 cth":               "3x1 cth",
+	}
+
+	for input, want := range tests {
+		items, err := ParseMedications(input)
+		if err != nil {
+			t.Fatalf("ParseMedications(%q): %v", input, err)
+		}
+		if len(items) != 1 || items[0].Signa != want {
+			t.Errorf("ParseMedications(%q) = %+v, want signa %q", input, items, want)
+		}
+	}
+
+	items, _ := ParseMedications("Amoxicillin 500mg no. XV, 3dd1")
+	if got := items[0].String(); got != "Amoxicillin 500 mg, No. XV (15), S. 3dd1" {
+		t.Fatalf("String = %q", got)
+	}
+}
+
+func TestFormatMedications(t *testing.T) {
+	items := []MedicationItem{
+		{Name: "Amoxicillin", Strength: "500 mg", Form: "tablet", Quantity: 15, QuantityText: "XV", Signa: "3dd1"},
+		{Name: "Ambroxol", Strength: "2.5 ml", Quantity: 10, QuantityText: "10"},
+		{Name: "Vitamin B kompleks"},
+	}
+
+	want := "1. Amoxicillin 500 mg tablet, No. XV (15), S. 3dd1\n2. Ambroxol 2.5 ml, No. 10\n3. Vitamin B kompleks"
+	if got := FormatMedications(items); got != want {
+		t.Fatalf("FormatMedications =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,370 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
+)
+
+// OutboxMessage is an outbound WhatsApp message waiting to be delivered
+type OutboxMessage struct {
+	ID            string    `json:"id"`
+	PhoneNumber   string    `json:"phone_number"`
+	Message       string    `json:"message"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	FailedAt      time.Time `json:"failed_at"`
+}
+
+// Delivery states reported by SendAndWait
+const (
+	DeliverySent   = "sent"
+	DeliveryQueued = "queued"
+	DeliveryFailed = "failed"
+)
+
+// Delivery is what happened to a message the caller waited for
+type Delivery struct {
+	OutboxID  string `json:"outbox_id"`
+	MessageID string `json:"message_id,omitempty"` // The ID the WhatsApp engine gave the message
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DeliveryGateway is a MessageGateway that can also wait until a message is actually delivered
+type DeliveryGateway interface {
+	MessageGateway
+	SendAndWait(ctx context.Context, phoneNumber, message string) (*Delivery, error)
+	// SendLinked is Send for messages whose ID has to be stored somewhere before they can be delivered,
+	// link is called with the ID before the message is handed to the delivery worker
+	SendLinked(ctx context.Context, phoneNumber, message string, link func(id string)) (string, error)
+}
+
+// outboxData is the layout of the outbox file
+type outboxData struct {
+	Pending     []OutboxMessage `json:"pending"`
+	DeadLetters []OutboxMessage `json:"dead_letters"`
+}
+
+// Outbox persists outbound messages and delivers them in the background.
+// Messages to the same recipient are delivered in the order they were enqueued,
+// failed sends are retried with exponential backoff and moved to the dead letters
+// once maxAttempts is reached.
+type Outbox struct {
+	mu           sync.Mutex
+	path         string
+	data         outboxData
+	gateway      MessageGateway
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	wake         chan struct{}
+	now          func() time.Time
+	onDelivered  func(outboxID, messageID string)
+	onDeadLetter func(outboxID string)
+	waiters      map[string]chan Delivery
+}
+
+func NewOutbox(path string, gateway MessageGateway, maxAttempts int, baseBackoff, maxBackoff time.Duration) (*Outbox, error) {
+	o := &Outbox{
+		path:        path,
+		gateway:     gateway,
+		maxAttempts: maxAttempts,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+		waiters:     make(map[string]chan Delivery),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &o.data); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Enqueue stores a message for delivery and returns its outbox ID.
+func (o *Outbox) Enqueue(phoneNumber, message string) (string, error) {
+	return o.enqueue(phoneNumber, message, nil, nil)
+}
+
+// SendAndWait enqueues the message and waits until it is delivered or ctx is done.
+// When ctx ends first the message stays in the outbox and the delivery is reported as queued.
+func (o *Outbox) SendAndWait(ctx context.Context, phoneNumber, message string) (*Delivery, error) {
+	waiter := make(chan Delivery, 1)
+	outboxID, err := o.enqueue(phoneNumber, message, waiter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case delivery := <-waiter:
+		return &delivery, nil
+	case <-ctx.Done():
+		o.mu.Lock()
+		delete(o.waiters, outboxID)
+		o.mu.Unlock()
+		return &Delivery{OutboxID: outboxID, Status: DeliveryQueued}, nil
+	}
+}
+
+// SendLinked enqueues the message like Send, link gets the outbox ID before the worker can deliver it,
+// so a delivery hook always finds what link stored.
+func (o *Outbox) SendLinked(ctx context.Context, phoneNumber, message string, link func(id string)) (string, error) {
+	return o.enqueue(phoneNumber, message, nil, link)
+}
+
+func (o *Outbox) enqueue(phoneNumber, message string, waiter chan Delivery, link func(id string)) (string, error) {
+	id := newID()
+	if link != nil {
+		link(id)
+	}
+
+	o.mu.Lock()
+	now := o.now()
+	msg := OutboxMessage{
+		ID:            id,
+		PhoneNumber:   phoneNumber,
+		Message:       message,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	o.data.Pending = append(o.data.Pending, msg)
+	err := o.save()
+	if err != nil {
+		// The caller is told the message was not sent, so it must not be delivered either
+		o.data.Pending = o.data.Pending[:len(o.data.Pending)-1]
+	} else if waiter != nil {
+		o.waiters[msg.ID] = waiter
+	}
+	o.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	o.notify()
+	return msg.ID, nil
+}
+
+// OnDelivered registers a hook that is called with the gateway message ID after each successful delivery.
+func (o *Outbox) OnDelivered(hook func(outboxID, messageID string)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.onDelivered = hook
+}
+
+// OnDeadLetter registers a hook that is called with the outbox ID of each message the outbox gives up on.
+func (o *Outbox) OnDeadLetter(hook func(outboxID string)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.onDeadLetter = hook
+}
+
+// Send implements MessageGateway by enqueueing the message, the returned ID is the outbox ID.
+func (o *Outbox) Send(ctx context.Context, phoneNumber, message string) (string, error) {
+	return o.Enqueue(phoneNumber, message)
+}
+
+// Pending returns a copy of the messages that are still waiting for delivery.
+func (o *Outbox) Pending() []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]OutboxMessage{}, o.data.Pending...)
+}
+
+// DeadLetters returns a copy of the messages that could not be delivered.
+func (o *Outbox) DeadLetters() []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]OutboxMessage{}, o.data.DeadLetters...)
+}
+
+// Replay moves a dead letter back to the pending queue with a fresh retry budget.
+func (o *Outbox) Replay(id string) (*OutboxMessage, error) {
+	o.mu.Lock()
+
+	index := -1
+	for i, msg := range o.data.DeadLetters {
+		if msg.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		o.mu.Unlock()
+		return nil, &exception.NotFoundError{Message: "Dead letter not found"}
+	}
+
+	previous := o.data
+	msg := o.data.DeadLetters[index]
+	o.data.DeadLetters = append(append([]OutboxMessage{}, o.data.DeadLetters[:index]...), o.data.DeadLetters[index+1:]...)
+
+	msg.Attempts = 0
+	msg.LastError = ""
+	msg.FailedAt = time.Time{}
+	msg.NextAttemptAt = o.now()
+
+	// Back in its place by creation time, so it still goes before later messages to the same recipient
+	position := len(o.data.Pending)
+	for i, pending := range o.data.Pending {
+		if pending.CreatedAt.After(msg.CreatedAt) {
+			position = i
+			break
+		}
+	}
+	pending := make([]OutboxMessage, 0, len(o.data.Pending)+1)
+	pending = append(pending, o.data.Pending[:position]...)
+	pending = append(pending, msg)
+	o.data.Pending = append(pending, o.data.Pending[position:]...)
+
+	err := o.save()
+	if err != nil {
+		o.data = previous
+	}
+	o.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	o.notify()
+	return &msg, nil
+}
+
+// Run delivers pending messages until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		o.flush(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// flush attempts the first due message of every recipient once.
+func (o *Outbox) flush(ctx context.Context) {
+	for _, msg := range o.dueMessages() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		messageID, err := o.gateway.Send(ctx, msg.PhoneNumber, msg.Message)
+		deadLettered := o.complete(msg.ID, messageID, err)
+
+		o.mu.Lock()
+		onDelivered, onDeadLetter := o.onDelivered, o.onDeadLetter
+		o.mu.Unlock()
+
+		if err == nil && onDelivered != nil {
+			onDelivered(msg.ID, messageID)
+		}
+		if deadLettered && onDeadLetter != nil {
+			onDeadLetter(msg.ID)
+		}
+	}
+}
+
+// dueMessages returns the head of each recipient's queue if it is due.
+func (o *Outbox) dueMessages() []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.now()
+	seen := make(map[string]bool)
+
+	var due []OutboxMessage
+	for _, msg := range o.data.Pending {
+		if seen[msg.PhoneNumber] {
+			continue
+		}
+		// Later messages to this recipient have to wait for this one
+		seen[msg.PhoneNumber] = true
+
+		if !msg.NextAttemptAt.After(now) {
+			due = append(due, msg)
+		}
+	}
+	return due
+}
+
+// complete records the result of a delivery attempt and reports whether the message was moved to the dead letters.
+func (o *Outbox) complete(id, messageID string, sendErr error) (deadLettered bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	index := -1
+	for i, msg := range o.data.Pending {
+		if msg.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false
+	}
+
+	if sendErr == nil {
+		o.data.Pending = append(o.data.Pending[:index], o.data.Pending[index+1:]...)
+		o.resolve(Delivery{OutboxID: id, MessageID: messageID, Status: DeliverySent})
+	} else {
+		msg := &o.data.Pending[index]
+		msg.Attempts++
+		msg.LastError = sendErr.Error()
+
+		if msg.Attempts >= o.maxAttempts {
+			log.Printf("Giving up on message %s to %s after %d attempts: %v", msg.ID, msg.PhoneNumber, msg.Attempts, sendErr)
+			msg.FailedAt = o.now()
+			o.data.DeadLetters = append(o.data.DeadLetters, *msg)
+			o.data.Pending = append(o.data.Pending[:index], o.data.Pending[index+1:]...)
+			o.resolve(Delivery{OutboxID: id, Status: DeliveryFailed, Error: sendErr.Error()})
+			deadLettered = true
+		} else {
+			msg.NextAttemptAt = o.now().Add(backoffDelay(o.baseBackoff, o.maxBackoff, msg.Attempts))
+			log.Printf("Message %s to %s failed (attempt %d), retrying at %s: %v", msg.ID, msg.PhoneNumber, msg.Attempts, msg.NextAttemptAt.Format(time.RFC3339), sendErr)
+		}
+	}
+
+	if err := o.save(); err != nil {
+		log.Printf("Unable to save outbox: %v", err)
+	}
+	return deadLettered
+}
+
+// resolve hands the final result to whoever waits for the message. The caller holds o.mu.
+func (o *Outbox) resolve(delivery Delivery) {
+	if waiter, ok := o.waiters[delivery.OutboxID]; ok {
+		waiter <- delivery
+		delete(o.waiters, delivery.OutboxID)
+	}
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) save() error {
+	if o.path == "" {
+		return nil
+	}
+	return writeJSONFile(o.path, o.data)
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// unwritablePath returns a path whose directory is a regular file, so saving to it always fails
+func unwritablePath(t *testing.T) string {
+	t.Helper()
+
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatalf("create blocker: %v", err)
+	}
+	return filepath.Join(blocker, "store.json")
+}
+
+// switchGateway fails every send while failing is set and records the messages it delivers
+type switchGateway struct {
+	mu        sync.Mutex
+	failing   bool
+	delivered []string
+}
+
+func (g *switchGateway) Send(ctx context.Context, phoneNumber, message string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failing {
+		return "", errors.New("engine offline")
+	}
+	g.delivered = append(g.delivered, message)
+	return "wa-" + message, nil
+}
+
+func TestOutboxNeverDeliversAMessageItCouldNotStore(t *testing.T) {
+	gateway := &switchGateway{}
+	o, err := NewOutbox("", gateway, 3, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	o.path = unwritablePath(t)
+
+	// The caller was told the order failed and will send it again, so this copy must never go out
+	if _, err := o.Enqueue("6281111111111", "order"); err == nil {
+		t.Fatal("Enqueue succeeded although the outbox could not be saved")
+	}
+	o.path = filepath.Join(t.TempDir(), "outbox.json")
+	if _, err := o.Enqueue("6281111111111", "order again"); err != nil {
+		t.Fatalf("Enqueue after the disk recovered: %v", err)
+	}
+	o.flush(context.Background())
+
+	if len(gateway.delivered) != 1 || gateway.delivered[0] != "order again" {
+		t.Fatalf("delivered %v, want only the message that was stored", gateway.delivered)
+	}
+}
+
+func TestOutboxReplayKeepsRecipientOrder(t *testing.T) {
+	gateway := &switchGateway{failing: true}
+	o, err := NewOutbox("", gateway, 1, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	now := time.Date(2025, 1, 2, 9, 0, 0, 0, WIB)
+	o.now = func() time.Time { return now }
+
+	// The first message dies while the engine is down, the next two wait behind it
+	first, _ := o.Enqueue("6281111111111", "first")
+	o.flush(context.Background())
+	if dead := o.DeadLetters(); len(dead) != 1 || dead[0].ID != first {
+		t.Fatalf("dead letters = %+v, want the first message", dead)
+	}
+	now = now.Add(time.Minute)
+	o.Enqueue("6281111111111", "second")
+	now = now.Add(time.Minute)
+	o.Enqueue("6281111111111", "third")
+
+	if _, err := o.Replay(first); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	gateway.failing = false
+	for i := 0; i < 3; i++ {
+		o.flush(context.Background())
+	}
+
+	want := []string{"first", "second", "third"}
+	if len(gateway.delivered) != len(want) {
+		t.Fatalf("delivered %v, want %v", gateway.delivered, want)
+	}
+	for i := range want {
+		if gateway.delivered[i] != want[i] {
+			t.Fatalf("delivered %v, want %v", gateway.delivered, want)
+		}
+	}
+}
+
+func TestOutboxReplayCanBeRepeatedAfterASaveError(t *testing.T) {
+	gateway := &switchGateway{failing: true}
+	o, err := NewOutbox("", gateway, 1, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	id, _ := o.Enqueue("6281111111111", "order")
+	o.flush(context.Background())
+	gateway.failing = false
+
+	// The admin sees the replay fail and the dead letter still listed, so they can press replay again
+	o.path = unwritablePath(t)
+	if _, err := o.Replay(id); err == nil {
+		t.Fatal("Replay succeeded although the outbox could not be saved")
+	}
+	if dead := o.DeadLetters(); len(dead) != 1 || dead[0].ID != id {
+		t.Fatalf("dead letters = %+v, want the message still there", dead)
+	}
+	o.flush(context.Background())
+	if len(gateway.delivered) != 0 {
+		t.Fatalf("delivered %v after a failed replay", gateway.delivered)
+	}
+
+	o.path = ""
+	if _, err := o.Replay(id); err != nil {
+		t.Fatalf("second Replay: %v", err)
+	}
+	o.flush(context.Background())
+	if len(gateway.delivered) != 1 || len(o.DeadLetters()) != 0 {
+		t.Fatalf("delivered %v with dead letters %+v, want the order delivered once", gateway.delivered, o.DeadLetters())
+	}
+}
+
+func TestOutboxSendLinkedLinksBeforeDelivery(t *testing.T) {
+	o, err := NewOutbox("", &switchGateway{}, 3, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	prescriptions, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	o.OnDelivered(prescriptions.LinkOrderMessage)
+
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 1, NowWIB())
+	if _, err := prescriptions.Add(record, "6289999999999", "6281111111111"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	outboxID, err := o.SendLinked(context.Background(), "6289999999999", "order", func(id string) {
+		if pending := o.Pending(); len(pending) != 0 {
+			t.Errorf("the message was pending before it was linked: %+v", pending)
+		}
+		if err := prescriptions.SetOrderOutboxID(record.ID, id); err != nil {
+			t.Errorf("SetOrderOutboxID: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("SendLinked: %v", err)
+	}
+	o.flush(context.Background())
+
+	stored := prescriptions.List(nil)
+	if len(stored) != 1 || stored[0].OrderOutboxID != outboxID || stored[0].OrderMessageID != "wa-order" {
+		t.Fatalf("stored prescriptions = %+v, want the order message linked", stored)
+	}
+}
+
+func TestOutboxDeadLetterMarksTheOrderUntilAReplayIsDelivered(t *testing.T) {
+	gateway := &switchGateway{failing: true}
+	o, err := NewOutbox("", gateway, 2, time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("NewOutbox: %v", err)
+	}
+	now := time.Date(2024, 5, 1, 9, 0, 0, 0, WIB)
+	o.now = func() time.Time { return now }
+
+	prescriptions, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	var deadLetters []string
+	o.OnDelivered(prescriptions.LinkOrderMessage)
+	o.OnDeadLetter(func(outboxID string) {
+		deadLetters = append(deadLetters, outboxID)
+		prescriptions.MarkOrderFailed(outboxID)
+	})
+
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 1, now)
+	if _, err := prescriptions.Add(record, "6289999999999", "6281111111111"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	outboxID, err := o.SendLinked(context.Background(), "6289999999999", "order", func(id string) {
+		prescriptions.SetOrderOutboxID(record.ID, id)
+	})
+	if err != nil {
+		t.Fatalf("SendLinked: %v", err)
+	}
+
+	// A retry is not a dead letter yet
+	o.flush(context.Background())
+	if stored, _ := prescriptions.Get(record.ID); len(deadLetters) != 0 || stored.OrderFailedAt != nil {
+		t.Fatalf("dead letters %v and failed at %v after the first attempt, want none", deadLetters, stored.OrderFailedAt)
+	}
+
+	now = now.Add(time.Minute)
+	o.flush(context.Background())
+	stored, _ := prescriptions.Get(record.ID)
+	if len(deadLetters) != 1 || deadLetters[0] != outboxID || stored.OrderFailedAt == nil {
+		t.Fatalf("dead letters %v and failed at %v after the last attempt, want the order marked", deadLetters, stored.OrderFailedAt)
+	}
+
+	gateway.mu.Lock()
+	gateway.failing = false
+	gateway.mu.Unlock()
+	if _, err := o.Replay(outboxID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	o.flush(context.Background())
+	stored, _ = prescriptions.Get(record.ID)
+	if stored.OrderFailedAt != nil || stored.OrderMessageID != "wa-order" {
+		t.Fatalf("prescription = %+v, want the mark cleared by the delivered replay", stored)
+	}
+}
+
+func TestMarkOrderFailedIgnoresOtherMessages(t *testing.T) {
+	prescriptions, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 1, NowWIB())
+	if _, err := prescriptions.Add(record, "6289999999999", "6281111111111"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := prescriptions.SetOrderOutboxID(record.ID, "outbox-order"); err != nil {
+		t.Fatalf("SetOrderOutboxID: %v", err)
+	}
+
+	for _, outboxID := range []string{"", "outbox-reply-to-patient"} {
+		if _, ok := prescriptions.MarkOrderFailed(outboxID); ok {
+			t.Errorf("MarkOrderFailed(%q) found a prescription", outboxID)
+		}
+	}
+	if stored, _ := prescriptions.Get(record.ID); stored.OrderFailedAt != nil {
+		t.Fatalf("OrderFailedAt = %v, want unset", stored.OrderFailedAt)
+	}
+}
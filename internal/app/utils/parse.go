@@ -1,71 +1,306 @@
 package utils
 
 import (
-	"fmt"
+	"errors"
 	"regexp"
+	"sort"
 	"strings"
 	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
 )
 
 type PatientDetails struct {
-	DoctorName         string
-	PatientName        string
-	PatientBirthDate   string
-	RegistryNum        string
-	Medication         string
-	PatientPhoneNumber string
-	PaymentMethod      string
+	DoctorName         string           `json:"doctor_name"`
+	PatientName        string           `json:"patient_name"`
+	PatientBirthDate   string           `json:"patient_birth_date"`
+	RegistryNum        string           `json:"registry_num"`
+	Medication         string           `json:"medication"` // The medication lines as shown to the pharmacy
+	Medications        []MedicationItem `json:"medications,omitempty"`
+	PatientPhoneNumber string           `json:"patient_phone_number"`
+	PaymentMethod      string           `json:"payment_method"`
+
+	// Fields taken from the patient registry and typed fields that disagree with it, only shown at confirmation
+	Prefilled  []PatientField    `json:"-"`
+	Mismatches []PatientMismatch `json:"-"`
+}
+
+// PatientField is a form field and its value
+type PatientField struct {
+	Field string // Form label
+	Value string
+}
+
+// PatientMismatch is a form field the doctor typed that disagrees with the patient registry
+type PatientMismatch struct {
+	Field  string // Form label
+	Typed  string
+	Stored string
+}
+
+// PatientLookup finds what is known about a patient by registry number, see PatientRegistry
+type PatientLookup interface {
+	Get(registryNum string) (*Patient, bool)
+}
+
+// FormDefaults fills in what a form leaves out
+type FormDefaults struct {
+	Patients   PatientLookup // Patient data and last payment method by registry number, may be nil
+	DoctorName string        // Name of the doctor who sends the form, may be empty
 }
 
-func ParsePatientDetails(message string) (*PatientDetails, error) {
-	details := &PatientDetails{}
-	lines := strings.Split(message, "\n")
+// Form labels as the bot asks for them
+const (
+	FormDoctorName         = "Nama Dokter"
+	FormPatientName        = "Nama Pasien"
+	FormPatientBirthDate   = "Tanggal Lahir Pasien"
+	FormRegistryNum        = "No Regis"
+	FormMedication         = "Resep Obat"
+	FormPatientPhoneNumber = "Nomor Telpon Pasien"
+	FormPaymentMethod      = "Pembiayaan"
+)
+
+// formLabelOrder is the position of each label in the form
+var formLabelOrder = map[string]int{
+	FormDoctorName:         0,
+	FormPatientName:        1,
+	FormPatientBirthDate:   2,
+	FormRegistryNum:        3,
+	FormMedication:         4,
+	FormPatientPhoneNumber: 5,
+	FormPaymentMethod:      6,
+}
+
+// formLabelAliases are the other labels accepted for each form label, e.g. from the English form
+var formLabelAliases = map[string][]string{
+	FormDoctorName:         {"Doctor Name", "Doctor's Name"},
+	FormPatientName:        {"Patient Name", "Patient's Name"},
+	FormPatientBirthDate:   {"Patient Birth Date", "Patient Date of Birth", "Date of Birth"},
+	FormRegistryNum:        {"Registry No", "Registry Number", "Registration No", "Registration Number"},
+	FormMedication:         {"Medication", "Prescription"},
+	FormPatientPhoneNumber: {"Patient Phone Number", "Patient Phone", "Phone Number"},
+	FormPaymentMethod:      {"Payment", "Payment Method"},
+}
 
+// formLabels maps every accepted label, lowercased, to its form label
+var formLabels = func() map[string]string {
+	labels := make(map[string]string)
+	for label, aliases := range formLabelAliases {
+		labels[strings.ToLower(label)] = label
+		for _, alias := range aliases {
+			labels[strings.ToLower(alias)] = label
+		}
+	}
+	return labels
+}()
+
+// Validation tags reported by ParsePatientForm
+const (
+	TagRequired = "required"
+	TagDate     = "date"
+	TagPhone    = "phone"
+	TagQuantity = "quantity"
+	TagUnknown  = "unknown" // A line with a label that is not on the form
+)
+
+// labelRegex matches what looks like a form label before a colon, e.g. "Catatan" but not "Paracetamol 500mg S"
+var labelRegex = regexp.MustCompile(`^[\p{L}][\p{L}' ]{0,30}$`)
+
+// birthDateLayouts are the accepted ways to write the patient's birth date
+var birthDateLayouts = []string{"2-1-2006", "2/1/2006", "2.1.2006", "2006-1-2", "2 January 2006", "2 Jan 2006"}
+
+// indonesianMonths lets birth dates like "17 Agustus 1990" parse with the English layouts
+var indonesianMonths = strings.NewReplacer(
+	"januari", "january", "februari", "february", "maret", "march", "mei", "may",
+	"juni", "june", "juli", "july", "agustus", "august", "oktober", "october",
+	"desember", "december", "agu", "aug", "okt", "oct", "des", "dec",
+)
+
+// phoneRegex matches a normalized Indonesian phone number
+var phoneRegex = regexp.MustCompile(`^62\d{8,13}$`)
+
+// ParsePatientForm reads the prescription form. Every line starts with a form label (or one of its
+// aliases), lines without a label continue the field above, e.g. a medication on several lines.
+// A missing doctor name is the sender's. When the registry knows the registry number, a missing patient
+// name, birth date, phone number or payment method is taken from it, and typed patient data that
+// disagrees with it is reported in Mismatches.
+// When fields are missing or malformed it returns exception.ValidationErrors with one entry per field.
+func ParsePatientForm(message string, defaults FormDefaults) (*PatientDetails, error) {
 	parsedFields := make(map[string]string)
+	var errs exception.ValidationErrors
 
-	for _, line := range lines {
+	current := ""
+	for _, line := range strings.Split(message, "\n") {
 		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
+		if len(parts) == 2 {
+			written := strings.TrimSpace(parts[0])
+			// Accept aliases such as "Doctor Name" for "Nama Dokter"
+			if label, ok := formLabels[strings.ToLower(written)]; ok {
+				current = label
+				parsedFields[label] = strings.TrimSpace(parts[1])
+				continue
+			}
+			// "Catatan: tolong cepat" is not part of the field above, it would reach the pharmacy as such.
+			// Medication lines have colons of their own, e.g. "Amoxicillin: 500mg 3x1", so there only
+			// a misspelled form label like "Nama Pasein:" is reported.
+			if labelRegex.MatchString(written) && (current != FormMedication || isNearFormLabel(written)) {
+				errs = append(errs, &exception.ValidationError{FailedField: written, Tag: TagUnknown, Value: strings.TrimSpace(line)})
+				continue
+			}
+		}
+
+		line = strings.TrimSpace(line)
+		if current == "" || line == "" {
 			continue
 		}
+		if parsedFields[current] != "" {
+			line = parsedFields[current] + "\n" + line
+		}
+		parsedFields[current] = line
+	}
+
+	// "No Regis: 012345" and the medication are enough for a patient the registry knows
+	var stored *Patient
+	var prefilled []PatientField
+	defaultFields := []PatientField{{Field: FormDoctorName, Value: defaults.DoctorName}}
+	if registryNum := parsedFields[FormRegistryNum]; defaults.Patients != nil && registryNum != "" {
+		if patient, ok := defaults.Patients.Get(registryNum); ok {
+			stored = patient
+			defaultFields = append(defaultFields, patient.fields()...)
+		}
+	}
+	for _, field := range defaultFields {
+		if parsedFields[field.Field] == "" && strings.TrimSpace(field.Value) != "" {
+			parsedFields[field.Field] = field.Value
+			prefilled = append(prefilled, field)
+		}
+	}
+
+	required := func(label string) string {
+		value, ok := parsedFields[label]
+		if !ok || value == "" {
+			errs = append(errs, &exception.ValidationError{FailedField: label, Tag: TagRequired, Value: value})
+		}
+		return value
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+	details := &PatientDetails{
+		DoctorName:       required(FormDoctorName),
+		PatientName:      required(FormPatientName),
+		PatientBirthDate: required(FormPatientBirthDate),
+		RegistryNum:      normalizeRegistryNum(required(FormRegistryNum)),
+		Medication:       required(FormMedication),
+		PaymentMethod:    required(FormPaymentMethod),
+	}
+	if details.Medication != "" {
+		medications, err := ParseMedications(details.Medication)
+		var quantityErr *QuantityError
+		if errors.As(err, &quantityErr) {
+			errs = append(errs, &exception.ValidationError{FailedField: FormMedication, Tag: TagQuantity, Value: quantityErr.Quantity})
+		}
+		details.Medications = medications
+		details.Medication = FormatMedications(medications)
+	}
 
-		parsedFields[key] = value
+	if details.PatientBirthDate != "" && !isValidBirthDate(details.PatientBirthDate) {
+		errs = append(errs, &exception.ValidationError{FailedField: FormPatientBirthDate, Tag: TagDate, Value: details.PatientBirthDate})
 	}
 
-	details.DoctorName = parsedFields["Nama Dokter"]
-	details.PatientName = parsedFields["Nama Pasien"]
-	details.PatientBirthDate = parsedFields["Tanggal Lahir Pasien"]
+	// The phone number may be "-" when the patient has none, but the line has to be there
+	rawPhone, ok := parsedFields[FormPatientPhoneNumber]
+	details.PatientPhoneNumber = NormalizePhone(rawPhone)
+	if !ok {
+		errs = append(errs, &exception.ValidationError{FailedField: FormPatientPhoneNumber, Tag: TagRequired})
+	} else if details.PatientPhoneNumber != "-" && !phoneRegex.MatchString(details.PatientPhoneNumber) {
+		errs = append(errs, &exception.ValidationError{FailedField: FormPatientPhoneNumber, Tag: TagPhone, Value: rawPhone})
+	}
 
-	rawRegistryNum := parsedFields["No Regis"]
-	details.RegistryNum = normalizeRegistryNum(rawRegistryNum)
+	if len(errs) > 0 {
+		// Report the fields in the order of the form
+		sort.SliceStable(errs, func(i, j int) bool {
+			return formPosition(errs[i].FailedField) < formPosition(errs[j].FailedField)
+		})
+		return nil, errs
+	}
 
-	rawMedication := parsedFields["Resep Obat"]
-	details.Medication = normalizeMedication(rawMedication)
+	details.Prefilled = prefilled
+	if stored != nil {
+		details.Mismatches = patientMismatches(details, stored)
+	}
+	return details, nil
+}
 
-	// Normalize phone number before assigning
-	rawPhone := parsedFields["Nomor Telpon Pasien"]
-	details.PatientPhoneNumber = normalizePhone(rawPhone)
+// isNearFormLabel reports whether the label is a form label with a typo, at most 2 edits away from one
+func isNearFormLabel(written string) bool {
+	written = strings.ToLower(written)
+	for label := range formLabels {
+		if levenshtein(written, label) <= 2 {
+			return true
+		}
+	}
+	return false
+}
 
-	details.PaymentMethod = parsedFields["Pembiayaan"]
+// formPosition is the position of the label in the form, labels that are not on the form come last
+func formPosition(label string) int {
+	if position, ok := formLabelOrder[label]; ok {
+		return position
+	}
+	return len(formLabelOrder)
+}
 
-	if details.DoctorName == "" ||
-		details.PatientName == "" ||
-		details.PatientBirthDate == "" ||
-		details.RegistryNum == "" ||
-		details.Medication == "" ||
-		details.PatientPhoneNumber == "" ||
-		details.PaymentMethod == "" {
-		return nil, &exception.BadRequestError{Message: "Missing required fields in the message"}
+// patientMismatches compares the typed patient fields with the registry, ignoring case, spacing,
+// the way a date is written and the phone number prefix. The payment method may change between visits.
+func patientMismatches(details *PatientDetails, stored *Patient) []PatientMismatch {
+	typed := map[string]string{
+		FormPatientName:        details.PatientName,
+		FormPatientBirthDate:   details.PatientBirthDate,
+		FormPatientPhoneNumber: details.PatientPhoneNumber,
 	}
 
-	return details, nil
+	var mismatches []PatientMismatch
+	for _, field := range stored.fields() {
+		value, compared := typed[field.Field]
+		if !compared || field.Value == "" || field.Value == "-" || sameFieldValue(field.Field, value, field.Value) {
+			continue
+		}
+		mismatches = append(mismatches, PatientMismatch{Field: field.Field, Typed: value, Stored: field.Value})
+	}
+	return mismatches
 }
 
-// normalizePhone cleans and converts phone number to Indonesian standard format: 628xxxxxxxxxx
-func normalizePhone(input string) string {
+func sameFieldValue(label, a, b string) bool {
+	switch label {
+	case FormPatientBirthDate:
+		dateA, okA := parseBirthDate(a)
+		dateB, okB := parseBirthDate(b)
+		if okA && okB {
+			return dateA.Equal(dateB)
+		}
+	case FormPatientPhoneNumber:
+		return NormalizePhone(a) == NormalizePhone(b)
+	}
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// isValidBirthDate reports whether the birth date is a real date that is not in the future
+func isValidBirthDate(input string) bool {
+	date, ok := parseBirthDate(input)
+	return ok && !date.After(NowWIB())
+}
+
+// parseBirthDate reads a birth date written in any of birthDateLayouts
+func parseBirthDate(input string) (time.Time, bool) {
+	value := indonesianMonths.Replace(strings.ToLower(strings.TrimSpace(input)))
+	for _, layout := range birthDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// NormalizePhone cleans and converts phone number to Indonesian standard format: 628xxxxxxxxxx
+func NormalizePhone(input string) string {
 	if input == "" || input == "-" {
 		return "-"
 	}
@@ -101,19 +336,3 @@ func normalizeRegistryNum(input string) string {
 	// Otherwise, return the original string
 	return input
 }
-
-func normalizeMedication(input string) string {
-	medParts := strings.Split(input, ",")
-
-	numberedMedParts := make([]string, len(medParts))
-
-	for i, part := range medParts {
-		trimmedPart := strings.TrimSpace(part)
-		// Format as "1. Item", "2. Item", etc.
-		numberedMedParts[i] = fmt.Sprintf("%d. %s", i+1, trimmedPart)
-	}
-
-	formattedMeds := strings.Join(numberedMedParts, ",\n")
-
-	return formattedMeds
-}
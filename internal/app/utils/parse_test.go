@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"testing"
+)
+
+// testPatientForm is a complete prescription form, with the medication on the line of its label
+const testPatientForm = `Nama Dokter: Budi
+Nama Pasien: Siti Aminah
+Tanggal Lahir Pasien: 01-02-1990
+No Regis: 012345
+Resep Obat: Paracetamol 500mg tab No. X 3dd1
+Nomor Telpon Pasien: 0812-3456-7890
+Pembiayaan: BPJS`
+
+// fieldErrors parses the form and returns its field errors as "field:tag", in the order they are reported
+func fieldErrors(t *testing.T, form string) []string {
+	t.Helper()
+
+	_, err := ParsePatientForm(form, FormDefaults{})
+	var errs exception.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ParsePatientForm error = %v, want ValidationErrors", err)
+	}
+
+	fields := make([]string, len(errs))
+	for i, e := range errs {
+		fields[i] = e.FailedField + ":" + e.Tag
+	}
+	return fields
+}
+
+func TestParsePatientForm(t *testing.T) {
+	details, err := ParsePatientForm(testPatientForm, FormDefaults{})
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+
+	if details.DoctorName != "Budi" || details.PatientName != "Siti Aminah" || details.PaymentMethod != "BPJS" {
+		t.Fatalf("details = %+v", details)
+	}
+	if details.RegistryNum != "'012345" {
+		t.Fatalf("registry number = %q, want the leading zero kept for the sheet", details.RegistryNum)
+	}
+	if details.PatientPhoneNumber != "6281234567890" {
+		t.Fatalf("phone number = %q, want it normalized", details.PatientPhoneNumber)
+	}
+	if details.Medication != "1. Paracetamol 500 mg tablet, No. X (10), S. 3dd1" || len(details.Medications) != 1 {
+		t.Fatalf("medication = %q, items = %+v", details.Medication, details.Medications)
+	}
+}
+
+func TestParsePatientFormMedicationOnSeveralLines(t *testing.T) {
+	form := strings.Replace(testPatientForm, "Resep Obat: Paracetamol 500mg tab No. X 3dd1", `Resep Obat:
+Amoxicillin: 500mg no. XV
+Signa: 3dd1
+CTM 4mg tab no X 3x1`, 1)
+
+	details, err := ParsePatientForm(form, FormDefaults{})
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+
+	want := []MedicationItem{
+		{Name: "Amoxicillin", Strength: "500 mg", Quantity: 15, QuantityText: "XV", Signa: "3dd1"},
+		{Name: "CTM", Strength: "4 mg", Form: "tablet", Quantity: 10, QuantityText: "X", Signa: "3x1"},
+	}
+	if len(details.Medications) != len(want) {
+		t.Fatalf("medications = %+v, want %d lines", details.Medications, len(want))
+	}
+	for i, item := range details.Medications {
+		item.Raw = ""
+		if item != want[i] {
+			t.Errorf("medication %d = %+v, want %+v", i, item, want[i])
+		}
+	}
+}
+
+func TestParsePatientFormReportsEveryField(t *testing.T) {
+	tests := []struct {
+		name string
+		form string
+		want []string
+	}{
+		{
+			name: "missing fields in form order",
+			form: "Resep Obat: Paracetamol\nNama Dokter: Budi",
+			want: []string{"Nama Pasien:required", "Tanggal Lahir Pasien:required", "No Regis:required", "Nomor Telpon Pasien:required", "Pembiayaan:required"},
+		},
+		{
+			name: "impossible birth date",
+			form: strings.Replace(testPatientForm, "01-02-1990", "31-02-1990", 1),
+			want: []string{"Tanggal Lahir Pasien:date"},
+		},
+		{
+			name: "phone number too short",
+			form: strings.Replace(testPatientForm, "0812-3456-7890", "0812", 1),
+			want: []string{"Nomor Telpon Pasien:phone"},
+		},
+		{
+			name: "invalid Roman quantity",
+			form: strings.Replace(testPatientForm, "No. X", "No. IIII", 1),
+			want: []string{"Resep Obat:quantity"},
+		},
+		{
+			name: "label that is not on the form",
+			form: testPatientForm + "\nCatatan: tolong cepat",
+			want: []string{"Catatan:unknown"},
+		},
+		{
+			name: "misspelled label after the medication",
+			form: strings.Replace(testPatientForm, "Nama Pasien: Siti Aminah\n", "", 1) + "\nResep Obat: CTM 4mg no X\nNama Pasein: Siti Aminah",
+			want: []string{"Nama Pasien:required", "Nama Pasein:unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fieldErrors(t, tt.form)
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Fatalf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatientFormPhoneNumberMayBeDash(t *testing.T) {
+	details, err := ParsePatientForm(strings.Replace(testPatientForm, "0812-3456-7890", "-", 1), FormDefaults{})
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+	if details.PatientPhoneNumber != "-" {
+		t.Fatalf("phone number = %q, want -", details.PatientPhoneNumber)
+	}
+}
+
+func TestParsePatientFormPrefillsFromRegistry(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+	stored := &PatientDetails{RegistryNum: "'012345", PatientName: "Siti Aminah", PatientBirthDate: "01-02-1990", PatientPhoneNumber: "6281234567890", PaymentMethod: "BPJS"}
+	if err := patients.Remember(stored); err != nil {
+		t.Fatalf("Remember: %v", err)
+	}
+	defaults := FormDefaults{Patients: patients, DoctorName: "Budi"}
+
+	// Two lines are enough for a known patient, a written line is used as written
+	details, err := ParsePatientForm("No Regis: 012345\nResep Obat: Paracetamol 500mg no X\nPembiayaan: Umum", defaults)
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+	if details.DoctorName != "Budi" || details.PatientName != "Siti Aminah" || details.PatientBirthDate != "01-02-1990" ||
+		details.PatientPhoneNumber != "6281234567890" || details.PaymentMethod != "Umum" {
+		t.Fatalf("details = %+v", details)
+	}
+	var prefilled []string
+	for _, field := range details.Prefilled {
+		prefilled = append(prefilled, field.Field)
+	}
+	if got := strings.Join(prefilled, "|"); got != "Nama Dokter|Nama Pasien|Tanggal Lahir Pasien|Nomor Telpon Pasien" {
+		t.Fatalf("prefilled = %s", got)
+	}
+	if len(details.Mismatches) != 0 {
+		t.Fatalf("mismatches = %+v, want none for prefilled fields", details.Mismatches)
+	}
+
+	// An unknown registry number gets nothing from the registry
+	if _, err := ParsePatientForm("No Regis: 999\nResep Obat: Paracetamol", defaults); err == nil {
+		t.Fatal("a form of an unknown patient without patient data was accepted")
+	}
+}
+
+func TestParsePatientFormFlagsMismatches(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+	stored := &PatientDetails{RegistryNum: "012345", PatientName: "Siti Aminah", PatientBirthDate: "01-02-1990", PatientPhoneNumber: "6281234567890", PaymentMethod: "Umum"}
+	if err := patients.Remember(stored); err != nil {
+		t.Fatalf("Remember: %v", err)
+	}
+	defaults := FormDefaults{Patients: patients}
+
+	// Case, spacing, the way the date is written, the phone prefix and the payment method are not mismatches
+	same := strings.NewReplacer("Siti Aminah", "SITI  aminah", "01-02-1990", "1 Februari 1990").Replace(testPatientForm)
+	details, err := ParsePatientForm(same, defaults)
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+	if len(details.Mismatches) != 0 {
+		t.Fatalf("mismatches = %+v, want none", details.Mismatches)
+	}
+
+	differs := strings.NewReplacer("Siti Aminah", "Siti Rahma", "01-02-1990", "02-01-1990").Replace(testPatientForm)
+	details, err = ParsePatientForm(differs, defaults)
+	if err != nil {
+		t.Fatalf("ParsePatientForm: %v", err)
+	}
+	want := []PatientMismatch{
+		{Field: FormPatientName, Typed: "Siti Rahma", Stored: "Siti Aminah"},
+		{Field: FormPatientBirthDate, Typed: "02-01-1990", Stored: "01-02-1990"},
+	}
+	if len(details.Mismatches) != len(want) {
+		t.Fatalf("mismatches = %+v, want %+v", details.Mismatches, want)
+	}
+	for i := range want {
+		if details.Mismatches[i] != want[i] {
+			t.Errorf("mismatch %d = %+v, want %+v", i, details.Mismatches[i], want[i])
+		}
+	}
+
+	// The typed values are sent, and the registry keeps what it had
+	if details.PatientName != "Siti Rahma" {
+		t.Fatalf("patient name = %q, want the typed name", details.PatientName)
+	}
+	if patient, _ := patients.Get("012345"); patient.Name != "Siti Aminah" {
+		t.Fatalf("registry name = %q, parsing changed the record", patient.Name)
+	}
+}
+
+func TestParsePatientFormEnglishLabels(t *testing.T) {
+	english := `doctor name: Budi
+Patient's Name: Siti Aminah
+Date of Birth: 01-02-1990
+Registry Number: 012345
+MEDICATION: Paracetamol 500mg tab No. X 3dd1
+Patient Phone: 0812-3456-7890
+Payment Method: BPJS`
+
+	want, err := ParsePatientForm(testPatientForm, FormDefaults{})
+	if err != nil {
+		t.Fatalf("ParsePatientForm Indonesian form: %v", err)
+	}
+	got, err := ParsePatientForm(english, FormDefaults{})
+	if err != nil {
+		t.Fatalf("ParsePatientForm English form: %v", err)
+	}
+	if got.DoctorName != want.DoctorName || got.PatientName != want.PatientName || got.PatientBirthDate != want.PatientBirthDate ||
+		got.RegistryNum != want.RegistryNum || got.Medication != want.Medication || got.PatientPhoneNumber != want.PatientPhoneNumber ||
+		got.PaymentMethod != want.PaymentMethod {
+		t.Fatalf("English form = %+v, want %+v", got, want)
+	}
+
+	// Field errors name the form label, whichever alias was written
+	if errs := fieldErrors(t, strings.Replace(english, "01-02-1990", "31-02-1990", 1)); strings.Join(errs, ", ") != "Tanggal Lahir Pasien:date" {
+		t.Fatalf("errors = %v", errs)
+	}
+}
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
+)
+
+// Allergy is something the patient must not get, a drug, an ingredient or a class like "Penicillin"
+type Allergy struct {
+	Name    string    `json:"name"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Patient is what is known about a patient between prescriptions
+type Patient struct {
+	RegistryNum   string    `json:"registry_num"`
+	Name          string    `json:"name,omitempty"`
+	BirthDate     string    `json:"birth_date,omitempty"`
+	PhoneNumber   string    `json:"phone_number,omitempty"`   // Normalized, "-" when the patient has none
+	PaymentMethod string    `json:"payment_method,omitempty"` // Of the last prescription
+	Allergies     []Allergy `json:"allergies"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AllergyWarning is a medication line that matches one of the patient's allergies
+type AllergyWarning struct {
+	Drug    string // The medication line as the doctor wrote it
+	Allergy string
+}
+
+// PatientRegistry keeps the patients in a JSON file, keyed by registry number.
+type PatientRegistry struct {
+	mu       sync.Mutex
+	path     string
+	patients map[string]*Patient
+}
+
+func NewPatientRegistry(path string) (*PatientRegistry, error) {
+	r := &PatientRegistry{
+		path:     path,
+		patients: make(map[string]*Patient),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &r.patients); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NormalizeRegistryNum makes "rm-001 " and "RM-001" the same registry number. The quote the form
+// puts before numbers starting with 0, so the sheet keeps the zero, is dropped.
+func NormalizeRegistryNum(registryNum string) string {
+	return strings.ToUpper(strings.TrimPrefix(strings.Join(strings.Fields(registryNum), ""), "'"))
+}
+
+// Get returns a copy of the patient with the registry number.
+func (r *PatientRegistry) Get(registryNum string) (*Patient, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	patient, exists := r.patients[NormalizeRegistryNum(registryNum)]
+	if !exists {
+		return nil, false
+	}
+	return patient.copy(), true
+}
+
+// Allergies returns the known allergies of the patient, none for an unknown registry number.
+func (r *PatientRegistry) Allergies(registryNum string) []Allergy {
+	patient, ok := r.Get(registryNum)
+	if !ok {
+		return nil
+	}
+	return patient.Allergies
+}
+
+// AddAllergy records an allergy of the patient, adding the patient when it is new.
+// Adding an allergy that is already known changes nothing.
+func (r *PatientRegistry) AddAllergy(registryNum, allergy, addedBy string) (*Patient, error) {
+	key := NormalizeRegistryNum(registryNum)
+	allergy = strings.TrimSpace(allergy)
+	if key == "" || key == "-" {
+		return nil, &exception.BadRequestError{Message: "Registry number is required"}
+	}
+	if allergy == "" {
+		return nil, &exception.BadRequestError{Message: "Allergy is required"}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	patient, exists := r.patients[key]
+	if !exists {
+		patient = &Patient{RegistryNum: key}
+	}
+	for _, known := range patient.Allergies {
+		if strings.EqualFold(known.Name, allergy) {
+			return patient.copy(), nil
+		}
+	}
+
+	updated := patient.copy()
+	updated.Allergies = append(updated.Allergies, Allergy{Name: allergy, AddedBy: addedBy, AddedAt: time.Now()})
+	updated.UpdatedAt = time.Now()
+	return r.put(key, updated, patient, exists)
+}
+
+// RemoveAllergy forgets an allergy of the patient, e.g. one that was added by mistake.
+func (r *PatientRegistry) RemoveAllergy(registryNum, allergy string) (*Patient, error) {
+	key := NormalizeRegistryNum(registryNum)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	patient, exists := r.patients[key]
+	if !exists {
+		return nil, &exception.NotFoundError{Message: "Patient not found"}
+	}
+
+	updated := patient.copy()
+	updated.Allergies = nil
+	for _, known := range patient.Allergies {
+		if !strings.EqualFold(known.Name, strings.TrimSpace(allergy)) {
+			updated.Allergies = append(updated.Allergies, known)
+		}
+	}
+	if len(updated.Allergies) == len(patient.Allergies) {
+		return nil, &exception.NotFoundError{Message: "Allergy not found"}
+	}
+	updated.UpdatedAt = time.Now()
+	return r.put(key, updated, patient, exists)
+}
+
+// Remember fills in the patient's name, birth date and phone number from a prescription, adding the
+// patient when it is new. Values the registry already has are kept: a form that disagrees is flagged
+// at confirmation instead of changing the record. The payment method is always the latest one.
+func (r *PatientRegistry) Remember(details *PatientDetails) error {
+	key := NormalizeRegistryNum(details.RegistryNum)
+	if key == "" || key == "-" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	patient, exists := r.patients[key]
+	if !exists {
+		patient = &Patient{RegistryNum: key}
+	}
+
+	updated := patient.copy()
+	// "-" only says there was no phone number last time
+	if updated.PhoneNumber == "-" {
+		updated.PhoneNumber = ""
+	}
+	fillEmpty(&updated.Name, details.PatientName)
+	fillEmpty(&updated.BirthDate, details.PatientBirthDate)
+	fillEmpty(&updated.PhoneNumber, details.PatientPhoneNumber)
+	replaceNonEmpty(&updated.PaymentMethod, details.PaymentMethod)
+	if exists && updated.Name == patient.Name && updated.BirthDate == patient.BirthDate &&
+		updated.PhoneNumber == patient.PhoneNumber && updated.PaymentMethod == patient.PaymentMethod {
+		return nil
+	}
+	updated.UpdatedAt = time.Now()
+	_, err := r.put(key, updated, patient, exists)
+	return err
+}
+
+// RememberPrescription remembers the patient of a new prescription.
+// It is meant to be used as a prescription store change hook.
+func (r *PatientRegistry) RememberPrescription(prescription *Prescription) {
+	if prescription.Status != StatusReceived {
+		return
+	}
+	if err := r.Remember(&prescription.Details); err != nil {
+		log.Printf("Unable to remember patient of prescription %s: %v", prescription.ID, err)
+	}
+}
+
+// patientColumns are the header names each column of a patient import may have
+var patientColumns = map[string][]string{
+	"registry_num": {"registry_num", "registry number", "registry no", "no regis", "no_regis", "no rm", "nomor rekam medis"},
+	"name":         {"name", "patient name", "nama", "nama pasien"},
+	"birth_date":   {"birth_date", "birth date", "date of birth", "tanggal lahir", "tanggal lahir pasien"},
+	"phone_number": {"phone_number", "phone number", "phone", "nomor telpon", "nomor telpon pasien", "no hp", "telepon"},
+	"payment":      {"payment_method", "payment method", "payment", "pembiayaan"},
+}
+
+// Import reads patients from a CSV or XLSX file whose first row names the columns: registry number
+// (no regis), name (nama), birth date (tanggal lahir), phone number (nomor telpon) and, optionally,
+// payment method (pembiayaan). The file is
+// the master data, so its non-empty values replace the registry's. Allergies are kept.
+// It returns the number of patients imported.
+func (r *PatientRegistry) Import(path string) (int, error) {
+	rows, columns, err := readTable(path, patientColumns, "registry_num")
+	if err != nil {
+		return 0, fmt.Errorf("unable to import patients: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous := make(map[string]*Patient, len(r.patients))
+	for key, patient := range r.patients {
+		previous[key] = patient
+	}
+
+	imported := 0
+	for _, row := range rows {
+		key := NormalizeRegistryNum(cellAt(row, columns, "registry_num"))
+		if key == "" {
+			continue
+		}
+
+		patient, exists := r.patients[key]
+		if !exists {
+			patient = &Patient{RegistryNum: key}
+		}
+		updated := patient.copy()
+		replaceNonEmpty(&updated.Name, cellAt(row, columns, "name"))
+		replaceNonEmpty(&updated.BirthDate, cellAt(row, columns, "birth_date"))
+		replaceNonEmpty(&updated.PaymentMethod, cellAt(row, columns, "payment"))
+		if phone := strings.TrimSpace(cellAt(row, columns, "phone_number")); phone != "" {
+			updated.PhoneNumber = NormalizePhone(phone)
+		}
+		updated.UpdatedAt = time.Now()
+		r.patients[key] = updated
+		imported++
+	}
+
+	if err := r.save(); err != nil {
+		r.patients = previous
+		return 0, err
+	}
+	return imported, nil
+}
+
+// put stores the patient and saves, the previous patient is restored when saving fails.
+// The caller holds the lock.
+func (r *PatientRegistry) put(key string, patient, previous *Patient, existed bool) (*Patient, error) {
+	r.patients[key] = patient
+	if err := r.save(); err != nil {
+		if existed {
+			r.patients[key] = previous
+		} else {
+			delete(r.patients, key)
+		}
+		return nil, err
+	}
+	return patient.copy(), nil
+}
+
+func (r *PatientRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	return writeJSONFile(r.path, r.patients)
+}
+
+func (p *Patient) copy() *Patient {
+	c := *p
+	c.Allergies = append([]Allergy{}, p.Allergies...)
+	return &c
+}
+
+// fields returns what is known about the patient as form fields, in the order of the form
+func (p *Patient) fields() []PatientField {
+	return []PatientField{
+		{Field: FormPatientName, Value: p.Name},
+		{Field: FormPatientBirthDate, Value: p.BirthDate},
+		{Field: FormPatientPhoneNumber, Value: p.PhoneNumber},
+		{Field: FormPaymentMethod, Value: p.PaymentMethod},
+	}
+}
+
+func fillEmpty(field *string, value string) {
+	if *field == "" {
+		*field = strings.Join(strings.Fields(value), " ")
+	}
+}
+
+func replaceNonEmpty(field *string, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		*field = value
+	}
+}
+
+// CheckAllergies returns the medication lines that match an allergy. A line matches when the allergy
+// is its name or, through the formulary, its generic name, a brand, an active ingredient or a drug class.
+// The formulary may be nil.
+func CheckAllergies(items []MedicationItem, allergies []Allergy, formulary *Formulary) []AllergyWarning {
+	var warnings []AllergyWarning
+	for _, item := range items {
+		names := []string{item.Name}
+		if formulary != nil {
+			if entry, ok := formulary.Lookup(item.Name); ok {
+				names = append(names, entry.Generic)
+				names = append(names, entry.Brands...)
+				names = append(names, entry.Ingredients...)
+				names = append(names, entry.Classes...)
+			}
+		}
+
+		for _, allergy := range allergies {
+			if containsFold(names, allergy.Name) {
+				warnings = append(warnings, AllergyWarning{Drug: item.Name, Allergy: allergy.Name})
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestPatientRegistry(t *testing.T) *PatientRegistry {
+	t.Helper()
+
+	patients, err := NewPatientRegistry("")
+	if err != nil {
+		t.Fatalf("NewPatientRegistry: %v", err)
+	}
+	return patients
+}
+
+func mustAddAllergy(t *testing.T, patients *PatientRegistry, registryNum, allergy string) {
+	t.Helper()
+
+	if _, err := patients.AddAllergy(registryNum, allergy, "6281111111111"); err != nil {
+		t.Fatalf("AddAllergy %s %s: %v", registryNum, allergy, err)
+	}
+}
+
+// allergyNames returns the names of the patient's allergies joined by "|"
+func allergyNames(patients *PatientRegistry, registryNum string) string {
+	var names []string
+	for _, allergy := range patients.Allergies(registryNum) {
+		names = append(names, allergy.Name)
+	}
+	return strings.Join(names, "|")
+}
+
+func TestCheckAllergies(t *testing.T) {
+	formulary := loadTestFormulary(t)
+
+	tests := []struct {
+		name    string
+		allergy string
+		drug    string
+		lookup  *Formulary
+		match   bool
+	}{
+		{"same name", "Ibuprofen", "Ibuprofen", nil, true},
+		{"case and spacing", " amoxicillin ", "AMOXICILLIN", nil, true},
+		{"brand without formulary", "Amoxicillin", "Amoxsan", nil, false},
+		{"brand through formulary", "Amoxicillin", "Amoxsan", formulary, true},
+		{"generic of a brand allergy", "Panadol", "Sanmol", formulary, true},
+		{"drug class", "penicillin", "Kalmoxillin", formulary, true},
+		{"ingredient of a combination", "Clavulanic acid", "Augmentin", formulary, true},
+		{"class of a combination", "Penicillin", "Augmentin", formulary, true},
+		{"other drug", "Penicillin", "Paracetamol", formulary, false},
+		{"unknown drug", "Penicillin", "Cefixime", formulary, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := CheckAllergies([]MedicationItem{{Name: tt.drug}}, []Allergy{{Name: tt.allergy}}, tt.lookup)
+			if tt.match != (len(warnings) == 1) {
+				t.Fatalf("warnings = %+v, want match %v", warnings, tt.match)
+			}
+			if tt.match && (warnings[0].Drug != tt.drug || warnings[0].Allergy != tt.allergy) {
+				t.Fatalf("warning = %+v", warnings[0])
+			}
+		})
+	}
+}
+
+func TestCheckAllergiesWarnsOncePerLine(t *testing.T) {
+	items := []MedicationItem{{Name: "Augmentin"}, {Name: "Paracetamol"}, {Name: "Amoxsan"}}
+	allergies := []Allergy{{Name: "Penicillin"}, {Name: "Amoxicillin"}}
+
+	warnings := CheckAllergies(items, allergies, loadTestFormulary(t))
+	if len(warnings) != 2 || warnings[0].Drug != "Augmentin" || warnings[1].Drug != "Amoxsan" {
+		t.Fatalf("warnings = %+v, want one for Augmentin and one for Amoxsan", warnings)
+	}
+}
+
+func TestPatientAllergies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patients.json")
+	patients, err := NewPatientRegistry(path)
+	if err != nil {
+		t.Fatalf("NewPatientRegistry: %v", err)
+	}
+
+	mustAddAllergy(t, patients, "rm-001 ", "Penicillin")
+	mustAddAllergy(t, patients, "'RM-001", "Ibuprofen")
+	// Known already, in another case
+	mustAddAllergy(t, patients, "RM-001", "PENICILLIN")
+	if got := allergyNames(patients, "RM-001"); got != "Penicillin|Ibuprofen" {
+		t.Fatalf("allergies = %s", got)
+	}
+
+	if _, err := patients.RemoveAllergy("rm-001", "penicillin"); err != nil {
+		t.Fatalf("RemoveAllergy: %v", err)
+	}
+	if _, err := patients.RemoveAllergy("RM-001", "Penicillin"); err == nil {
+		t.Fatal("an allergy that is not known was removed")
+	}
+	if _, err := patients.RemoveAllergy("RM-404", "Penicillin"); err == nil {
+		t.Fatal("an allergy of an unknown patient was removed")
+	}
+	for _, bad := range [][2]string{{"", "Penicillin"}, {"-", "Penicillin"}, {"RM-001", " "}} {
+		if _, err := patients.AddAllergy(bad[0], bad[1], "6281111111111"); err == nil {
+			t.Errorf("AddAllergy(%q, %q) succeeded", bad[0], bad[1])
+		}
+	}
+
+	reloaded, err := NewPatientRegistry(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := allergyNames(reloaded, "RM-001"); got != "Ibuprofen" {
+		t.Fatalf("reloaded allergies = %s, want Ibuprofen", got)
+	}
+}
+
+func TestPatientAllergyChangesThatFailToSaveDoNotApply(t *testing.T) {
+	formulary := loadTestFormulary(t)
+	patients := newTestPatientRegistry(t)
+	mustAddAllergy(t, patients, "RM-001", "Penicillin")
+	patients.path = unwritablePath(t)
+
+	// A removal the doctor was told failed must keep blocking Amoxicillin
+	if _, err := patients.RemoveAllergy("RM-001", "Penicillin"); err == nil {
+		t.Fatal("RemoveAllergy succeeded although the registry could not be saved")
+	}
+	amoxicillin := []MedicationItem{{Name: "Amoxicillin", Strength: "500 mg"}}
+	if warnings := CheckAllergies(amoxicillin, patients.Allergies("RM-001"), formulary); len(warnings) != 1 {
+		t.Fatalf("warnings = %+v, want Amoxicillin still blocked", warnings)
+	}
+
+	// An allergy that failed to save can be added again, it is not reported as known already
+	if _, err := patients.AddAllergy("RM-001", "Ibuprofen", "6281111111111"); err == nil {
+		t.Fatal("AddAllergy succeeded although the registry could not be saved")
+	}
+	if _, err := patients.AddAllergy("RM-002", "Ibuprofen", "6281111111111"); err == nil {
+		t.Fatal("AddAllergy of a new patient succeeded although the registry could not be saved")
+	}
+	patients.path = ""
+	mustAddAllergy(t, patients, "RM-001", "Ibuprofen")
+	mustAddAllergy(t, patients, "RM-002", "Ibuprofen")
+	if got := allergyNames(patients, "RM-001"); got != "Penicillin|Ibuprofen" {
+		t.Fatalf("allergies = %s, want Penicillin and Ibuprofen", got)
+	}
+}
+
+func TestPatientImport(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+	mustAddAllergy(t, patients, "RM-001", "Penicillin")
+
+	// Indonesian headers, an empty cell that keeps the stored value and a row without a registry number
+	path := writeTestFile(t, "patients.csv", `No Regis,Nama Pasien,Tanggal Lahir,No HP,Pembiayaan
+rm-001,Siti Aminah,01-02-1990,0812-3456-7890,BPJS
+RM-002,Budi Santoso,,,Umum
+,Tanpa Nomor,,,
+`)
+	imported, err := patients.Import(path)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if imported != 2 {
+		t.Fatalf("imported = %d, want 2", imported)
+	}
+
+	siti, _ := patients.Get("RM-001")
+	if siti.Name != "Siti Aminah" || siti.PhoneNumber != "6281234567890" || siti.PaymentMethod != "BPJS" {
+		t.Fatalf("RM-001 = %+v", siti)
+	}
+	if got := allergyNames(patients, "RM-001"); got != "Penicillin" {
+		t.Fatalf("allergies after import = %s, want them kept", got)
+	}
+}
+
+func TestPatientImportCanBeRerunAfterASaveError(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+	mustAddAllergy(t, patients, "RM-001", "Penicillin")
+	path := writeTestFile(t, "patients.csv", "registry_num,name\nRM-001,Siti Aminah\nRM-002,Budi Santoso\n")
+
+	// The import runs at start, a failed one stops the start and the next start imports everything
+	patients.path = unwritablePath(t)
+	if _, err := patients.Import(path); err == nil {
+		t.Fatal("Import succeeded although the registry could not be saved")
+	}
+	if _, ok := patients.Get("RM-002"); ok {
+		t.Fatal("an imported patient is in the registry although the import failed")
+	}
+
+	patients.path = filepath.Join(t.TempDir(), "patients.json")
+	imported, err := patients.Import(path)
+	if err != nil {
+		t.Fatalf("second Import: %v", err)
+	}
+	if imported != 2 {
+		t.Fatalf("imported = %d, want both patients", imported)
+	}
+	siti, _ := patients.Get("RM-001")
+	if siti.Name != "Siti Aminah" || allergyNames(patients, "RM-001") != "Penicillin" {
+		t.Fatalf("RM-001 = %+v, want the imported name and the allergy kept", siti)
+	}
+}
+
+func TestPatientRememberKeepsStoredValues(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+
+	first := &PatientDetails{RegistryNum: "'012345", PatientName: "Siti  Aminah", PatientBirthDate: "01-02-1990", PatientPhoneNumber: "-", PaymentMethod: "BPJS"}
+	if err := patients.Remember(first); err != nil {
+		t.Fatalf("Remember: %v", err)
+	}
+
+	// A later form with other values only fills what the registry did not have
+	second := &PatientDetails{RegistryNum: "012345", PatientName: "Siti A.", PatientBirthDate: "02-02-1990", PatientPhoneNumber: "6281234567890", PaymentMethod: "Umum"}
+	if err := patients.Remember(second); err != nil {
+		t.Fatalf("Remember: %v", err)
+	}
+
+	patient, ok := patients.Get("012345")
+	if !ok {
+		t.Fatal("patient not found by the registry number without the quote")
+	}
+	want := Patient{RegistryNum: "012345", Name: "Siti Aminah", BirthDate: "01-02-1990", PhoneNumber: "6281234567890", PaymentMethod: "Umum"}
+	if patient.Name != want.Name || patient.BirthDate != want.BirthDate || patient.PhoneNumber != want.PhoneNumber || patient.PaymentMethod != want.PaymentMethod {
+		t.Fatalf("patient = %+v, want %+v", patient, want)
+	}
+
+	// A form without a registry number is not remembered
+	if err := patients.Remember(&PatientDetails{RegistryNum: "-", PatientName: "Budi"}); err != nil {
+		t.Fatalf("Remember without registry number: %v", err)
+	}
+	if _, ok := patients.Get("-"); ok {
+		t.Fatal("a patient without a registry number was remembered")
+	}
+}
+
+func TestPatientRememberPrescriptionOnlyOnNewPrescriptions(t *testing.T) {
+	patients := newTestPatientRegistry(t)
+
+	prescription := &Prescription{
+		PrescriptionRecord: PrescriptionRecord{ID: "RX-1", Details: PatientDetails{RegistryNum: "RM-001", PatientName: "Siti Aminah"}},
+		Status:             StatusReady,
+	}
+	patients.RememberPrescription(prescription)
+	if _, ok := patients.Get("RM-001"); ok {
+		t.Fatal("a status change remembered the patient")
+	}
+
+	prescription.Status = StatusReceived
+	patients.RememberPrescription(prescription)
+	if patient, ok := patients.Get("RM-001"); !ok || patient.Name != "Siti Aminah" {
+		t.Fatalf("patient = %+v, %v", patient, ok)
+	}
+}
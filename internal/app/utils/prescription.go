@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// PrescriptionRecord is a confirmed prescription with its queue number
+type PrescriptionRecord struct {
+	ID          string         `json:"id"`
+	QueueNumber int            `json:"queue_number"`
+	Details     PatientDetails `json:"details"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// NewPrescriptionID builds the prescription ID from the WIB date, the pharmacy and the queue number,
+// e.g. RX-20060102-6281234567890-007. Queue numbers are unique per day and pharmacy so the ID is unique as well.
+func NewPrescriptionID(createdAt time.Time, pharmacyNumber string, queueNumber int) string {
+	return fmt.Sprintf("RX-%s-%s-%03d", createdAt.In(WIB).Format("20060102"), NormalizePhone(pharmacyNumber), queueNumber)
+}
+
+func NewPrescriptionRecord(details *PatientDetails, pharmacyNumber string, queueNumber int, createdAt time.Time) *PrescriptionRecord {
+	return &PrescriptionRecord{
+		ID:          NewPrescriptionID(createdAt, pharmacyNumber, queueNumber),
+		QueueNumber: queueNumber,
+		Details:     *details,
+		CreatedAt:   createdAt,
+	}
+}
+
+// FormattedTime returns the creation time as "02 January 2006 15:04 WIB"
+func (r *PrescriptionRecord) FormattedTime() string {
+	return r.CreatedAt.In(WIB).Format("02 January 2006 15:04") + " WIB"
+}
@@ -0,0 +1,427 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"time"
+)
+
+// Prescription statuses, in the order a prescription moves through them
+const (
+	StatusReceived  = "received"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusPickedUp  = "picked_up"
+)
+
+var statusOrder = map[string]int{
+	StatusReceived:  0,
+	StatusPreparing: 1,
+	StatusReady:     2,
+	StatusPickedUp:  3,
+}
+
+var statusLabels = map[string]string{
+	StatusReceived:  "Diterima",
+	StatusPreparing: "Sedang Disiapkan",
+	StatusReady:     "Siap Diambil",
+	StatusPickedUp:  "Sudah Diambil",
+}
+
+// StatusLabel returns the Indonesian label shown to users and written to the sheet
+func StatusLabel(status string) string {
+	if label, ok := statusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+// IsValidStatus reports whether status is a known prescription status
+func IsValidStatus(status string) bool {
+	_, ok := statusOrder[status]
+	return ok
+}
+
+// StatusChange is one step in the history of a prescription
+type StatusChange struct {
+	Status    string    `json:"status"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Prescription is a confirmed prescription and what happened to it since
+type Prescription struct {
+	PrescriptionRecord
+	PharmacyNumber  string         `json:"pharmacy_number"`
+	Status          string         `json:"status"`
+	StatusUpdatedAt time.Time      `json:"status_updated_at"`
+	History         []StatusChange `json:"history"`
+	OrderOutboxID   string         `json:"order_outbox_id,omitempty"`
+	OrderMessageID  string         `json:"order_message_id,omitempty"`
+	OrderFailedAt   *time.Time     `json:"order_failed_at,omitempty"` // The order message was dead-lettered and has not been delivered since
+	ReminderSentAt  *time.Time     `json:"reminder_sent_at,omitempty"`
+}
+
+// PrescriptionStore keeps the prescriptions of the retention window with their status in a JSON file.
+type PrescriptionStore struct {
+	mu            sync.Mutex
+	path          string
+	retention     time.Duration // Picked up prescriptions created longer ago are dropped, 0 keeps them all
+	now           func() time.Time
+	prescriptions map[string]*Prescription
+	byPatientDay  map[string][]string // IDs of the prescriptions by patient phone and day (WIB), see patientDayKey
+	onChange      []func(*Prescription)
+}
+
+func NewPrescriptionStore(path string, retention time.Duration) (*PrescriptionStore, error) {
+	s := &PrescriptionStore{
+		path:          path,
+		retention:     retention,
+		now:           time.Now,
+		prescriptions: make(map[string]*Prescription),
+		byPatientDay:  make(map[string][]string),
+	}
+	if path != "" {
+		if err := readJSONFile(path, &s.prescriptions); err != nil {
+			return nil, err
+		}
+	}
+	for _, prescription := range s.prescriptions {
+		s.index(prescription)
+	}
+	if pruned := s.prune(); len(pruned) > 0 {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+		log.Printf("Dropped %d picked up prescriptions older than %s", len(pruned), s.retention)
+	}
+	return s, nil
+}
+
+// OnChange registers a hook that is called after a prescription is added or changes status.
+func (s *PrescriptionStore) OnChange(hook func(*Prescription)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onChange = append(s.onChange, hook)
+}
+
+// Add stores a new prescription with status received.
+func (s *PrescriptionStore) Add(record *PrescriptionRecord, pharmacyNumber, createdBy string) (*Prescription, error) {
+	prescription, err := s.add(record, pharmacyNumber, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(prescription)
+	return prescription, nil
+}
+
+func (s *PrescriptionStore) add(record *PrescriptionRecord, pharmacyNumber, createdBy string) (*Prescription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.prescriptions[record.ID]; exists {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("Prescription %s already exists", record.ID)}
+	}
+
+	prescription := &Prescription{
+		PrescriptionRecord: *record,
+		PharmacyNumber:     pharmacyNumber,
+		Status:             StatusReceived,
+		StatusUpdatedAt:    record.CreatedAt,
+		History: []StatusChange{
+			{Status: StatusReceived, ChangedBy: createdBy, ChangedAt: record.CreatedAt},
+		},
+	}
+	s.prescriptions[record.ID] = prescription
+	s.index(prescription)
+	pruned := s.prune()
+
+	if err := s.save(); err != nil {
+		delete(s.prescriptions, record.ID)
+		s.unindex(prescription)
+		for id, old := range pruned {
+			s.prescriptions[id] = old
+			s.index(old)
+		}
+		return nil, err
+	}
+	return prescription.copy(), nil
+}
+
+// index adds the prescription to byPatientDay. The caller holds s.mu.
+func (s *PrescriptionStore) index(prescription *Prescription) {
+	key := patientDayKey(prescription.Details.PatientPhoneNumber, prescription.CreatedAt)
+	s.byPatientDay[key] = append(s.byPatientDay[key], prescription.ID)
+}
+
+// unindex removes the prescription from byPatientDay. The caller holds s.mu.
+func (s *PrescriptionStore) unindex(prescription *Prescription) {
+	key := patientDayKey(prescription.Details.PatientPhoneNumber, prescription.CreatedAt)
+	ids := s.byPatientDay[key]
+	for i, id := range ids {
+		if id == prescription.ID {
+			ids = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(s.byPatientDay, key)
+	} else {
+		s.byPatientDay[key] = ids
+	}
+}
+
+// patientDayKey is the byPatientDay key of a patient phone number on the day (WIB) of t
+func patientDayKey(phoneNumber string, t time.Time) string {
+	return NormalizePhone(phoneNumber) + "|" + t.In(WIB).Format("2006-01-02")
+}
+
+// prune drops the picked up prescriptions created before the retention window and returns them.
+// Open prescriptions are kept however old they are. The caller holds s.mu.
+func (s *PrescriptionStore) prune() map[string]*Prescription {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	cutoff := s.now().Add(-s.retention)
+	pruned := make(map[string]*Prescription)
+	for id, prescription := range s.prescriptions {
+		if prescription.Status == StatusPickedUp && prescription.CreatedAt.Before(cutoff) {
+			pruned[id] = prescription
+			delete(s.prescriptions, id)
+			s.unindex(prescription)
+		}
+	}
+	return pruned
+}
+
+// Get returns a copy of the prescription.
+func (s *PrescriptionStore) Get(id string) (*Prescription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, exists := s.prescriptions[id]
+	if !exists {
+		return nil, false
+	}
+	return prescription.copy(), true
+}
+
+// FindByQueueNumber returns the prescription of the pharmacy with the queue number on the given day (WIB).
+func (s *PrescriptionStore) FindByQueueNumber(day time.Time, pharmacyNumber string, queueNumber int) (*Prescription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date := day.In(WIB).Format("2006-01-02")
+	pharmacy := NormalizePhone(pharmacyNumber)
+	for _, prescription := range s.prescriptions {
+		if prescription.QueueNumber == queueNumber &&
+			prescription.CreatedAt.In(WIB).Format("2006-01-02") == date &&
+			NormalizePhone(prescription.PharmacyNumber) == pharmacy {
+			return prescription.copy(), true
+		}
+	}
+	return nil, false
+}
+
+// FindByPatient returns the prescriptions of the patient phone number on the given day (WIB), ordered by creation time.
+// Patients without a phone number, written as "-", have no prescriptions to find.
+func (s *PrescriptionStore) FindByPatient(day time.Time, phoneNumber string) []Prescription {
+	prescriptions := []Prescription{}
+	if phoneNumber == "" || phoneNumber == "-" {
+		return prescriptions
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.byPatientDay[patientDayKey(phoneNumber, day)] {
+		prescriptions = append(prescriptions, *s.prescriptions[id].copy())
+	}
+	sortPrescriptions(prescriptions)
+	return prescriptions
+}
+
+// FindByOrderMessageID returns the prescription whose order message has the WhatsApp message ID.
+func (s *PrescriptionStore) FindByOrderMessageID(messageID string) (*Prescription, bool) {
+	if messageID == "" {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, prescription := range s.prescriptions {
+		if prescription.OrderMessageID == messageID {
+			return prescription.copy(), true
+		}
+	}
+	return nil, false
+}
+
+// SetOrderOutboxID remembers which outbox message carries the order to the pharmacy.
+func (s *PrescriptionStore) SetOrderOutboxID(id, outboxID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, exists := s.prescriptions[id]
+	if !exists {
+		return &exception.NotFoundError{Message: "Prescription not found"}
+	}
+	prescription.OrderOutboxID = outboxID
+	return s.save()
+}
+
+// LinkOrderMessage stores the WhatsApp message ID once the order message was delivered,
+// so the pharmacist can quote it. It is meant to be used as the outbox delivery hook.
+func (s *PrescriptionStore) LinkOrderMessage(outboxID, messageID string) {
+	if outboxID == "" || messageID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, prescription := range s.prescriptions {
+		if prescription.OrderOutboxID == outboxID {
+			prescription.OrderMessageID = messageID
+			// A replayed dead letter reached the pharmacy after all
+			prescription.OrderFailedAt = nil
+			if err := s.save(); err != nil {
+				log.Printf("Unable to save order message of prescription %s: %v", prescription.ID, err)
+			}
+			return
+		}
+	}
+}
+
+// MarkOrderFailed records that the order message with the outbox ID was given up on and returns its prescription.
+// It returns false when the message is not the order of any prescription.
+func (s *PrescriptionStore) MarkOrderFailed(outboxID string) (*Prescription, bool) {
+	if outboxID == "" {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, prescription := range s.prescriptions {
+		if prescription.OrderOutboxID == outboxID {
+			now := time.Now()
+			prescription.OrderFailedAt = &now
+			if err := s.save(); err != nil {
+				log.Printf("Unable to save failed order of prescription %s: %v", prescription.ID, err)
+			}
+			return prescription.copy(), true
+		}
+	}
+	return nil, false
+}
+
+// MarkReminderSent records that the patient was reminded to pick up the prescription.
+func (s *PrescriptionStore) MarkReminderSent(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, exists := s.prescriptions[id]
+	if !exists {
+		return &exception.NotFoundError{Message: "Prescription not found"}
+	}
+
+	now := time.Now()
+	prescription.ReminderSentAt = &now
+	return s.save()
+}
+
+// UpdateStatus moves the prescription forward to status. Going back to an earlier status is not allowed.
+func (s *PrescriptionStore) UpdateStatus(id, status, changedBy string) (*Prescription, error) {
+	prescription, err := s.updateStatus(id, status, changedBy)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(prescription)
+	return prescription, nil
+}
+
+func (s *PrescriptionStore) updateStatus(id, status, changedBy string) (*Prescription, error) {
+	if !IsValidStatus(status) {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("Unknown status: %s", status)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, exists := s.prescriptions[id]
+	if !exists {
+		return nil, &exception.NotFoundError{Message: "Prescription not found"}
+	}
+	if statusOrder[status] <= statusOrder[prescription.Status] {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("Prescription is already %s", StatusLabel(prescription.Status))}
+	}
+
+	previous := *prescription
+	now := time.Now()
+	prescription.Status = status
+	prescription.StatusUpdatedAt = now
+	prescription.History = append(prescription.History, StatusChange{Status: status, ChangedBy: changedBy, ChangedAt: now})
+
+	if err := s.save(); err != nil {
+		*prescription = previous
+		return nil, err
+	}
+	return prescription.copy(), nil
+}
+
+// List returns copies of the prescriptions that match, ordered by creation time.
+func (s *PrescriptionStore) List(match func(*Prescription) bool) []Prescription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescriptions := []Prescription{}
+	for _, prescription := range s.prescriptions {
+		if match == nil || match(prescription) {
+			prescriptions = append(prescriptions, *prescription.copy())
+		}
+	}
+	sortPrescriptions(prescriptions)
+	return prescriptions
+}
+
+// notify runs the change hooks outside of the lock so they can read the store
+func (s *PrescriptionStore) notify(prescription *Prescription) {
+	s.mu.Lock()
+	hooks := append([]func(*Prescription){}, s.onChange...)
+	s.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(prescription.copy())
+	}
+}
+
+func (s *PrescriptionStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return writeJSONFile(s.path, s.prescriptions)
+}
+
+func (p *Prescription) copy() *Prescription {
+	c := *p
+	c.History = append([]StatusChange{}, p.History...)
+	return &c
+}
+
+// sortPrescriptions orders by creation time, then queue number
+func sortPrescriptions(prescriptions []Prescription) {
+	sort.Slice(prescriptions, func(i, j int) bool {
+		a, b := prescriptions[i], prescriptions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.QueueNumber < b.QueueNumber
+	})
+}
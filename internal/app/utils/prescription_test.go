@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrescriptionIDsOfPharmaciesDoNotCollide(t *testing.T) {
+	store, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	createdAt := time.Date(2025, 1, 2, 9, 30, 0, 0, WIB)
+
+	// Both pharmacies hand out queue number 1 on the same day
+	for _, pharmacy := range []string{"6281111111111", "6282222222222"} {
+		record := NewPrescriptionRecord(&PatientDetails{PatientName: pharmacy}, pharmacy, 1, createdAt)
+		if _, err := store.Add(record, pharmacy, "6283333333333"); err != nil {
+			t.Fatalf("Add for %s: %v", pharmacy, err)
+		}
+	}
+
+	for _, pharmacy := range []string{"6281111111111", "082222222222"} {
+		prescription, ok := store.FindByQueueNumber(createdAt, pharmacy, 1)
+		if !ok {
+			t.Fatalf("queue number 1 of %s not found", pharmacy)
+		}
+		if prescription.Details.PatientName != NormalizePhone(pharmacy) {
+			t.Fatalf("queue number 1 of %s is the prescription of %s", pharmacy, prescription.Details.PatientName)
+		}
+	}
+}
+
+func TestPrescriptionStoreDropsPickedUpPrescriptionsAfterTheRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prescriptions.json")
+	store, err := NewPrescriptionStore(path, 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	// Opening the store prunes with the real clock
+	now := time.Now()
+	old := now.AddDate(0, 0, -31)
+
+	add := func(queueNumber int, createdAt time.Time, pickedUp bool) string {
+		t.Helper()
+		record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", queueNumber, createdAt)
+		if _, err := store.Add(record, "6289999999999", "6281111111111"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if pickedUp {
+			if _, err := store.UpdateStatus(record.ID, StatusPickedUp, "6289999999999"); err != nil {
+				t.Fatalf("UpdateStatus: %v", err)
+			}
+		}
+		return record.ID
+	}
+	oldPickedUp := add(1, old, true)
+	oldOpen := add(2, old, false)
+	recentPickedUp := add(3, now.AddDate(0, 0, -29), true)
+
+	// Reopening with a retention drops only what was picked up before the window, in the file too
+	store, err = NewPrescriptionStore(path, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore with retention: %v", err)
+	}
+	reopened, err := NewPrescriptionStore(path, 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore again: %v", err)
+	}
+	for _, s := range []*PrescriptionStore{store, reopened} {
+		if _, ok := s.Get(oldPickedUp); ok {
+			t.Errorf("old picked up prescription %s is still stored", oldPickedUp)
+		}
+		for _, id := range []string{oldOpen, recentPickedUp} {
+			if _, ok := s.Get(id); !ok {
+				t.Errorf("prescription %s was dropped", id)
+			}
+		}
+	}
+
+	// Adding prunes as well, once the recent one falls out of the window
+	now = now.AddDate(0, 0, 2)
+	store.now = func() time.Time { return now }
+	add(4, now, false)
+	if _, ok := store.Get(recentPickedUp); ok {
+		t.Fatalf("prescription %s is still stored two days later", recentPickedUp)
+	}
+	if got := len(store.List(nil)); got != 2 {
+		t.Fatalf("%d prescriptions stored, want the open old one and the new one", got)
+	}
+}
+
+func TestFindByPatient(t *testing.T) {
+	store, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	day := time.Date(2025, 1, 2, 9, 30, 0, 0, WIB)
+
+	add := func(phoneNumber string, queueNumber int, createdAt time.Time) {
+		t.Helper()
+		record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah", PatientPhoneNumber: phoneNumber}, "6289999999999", queueNumber, createdAt)
+		if _, err := store.Add(record, "6289999999999", "6281111111111"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	add("6281234567890", 2, day.Add(time.Hour))
+	add("6281234567890", 1, day)
+	add("6281234567890", 1, day.AddDate(0, 0, -1))
+	add("6285555555555", 3, day)
+	add("-", 4, day)
+
+	// Any way of writing the number finds both of that day, in queue order
+	for _, phoneNumber := range []string{"6281234567890", "0812-3456-7890", "6281234567890@s.whatsapp.net"} {
+		found := store.FindByPatient(day, phoneNumber)
+		if len(found) != 2 || found[0].QueueNumber != 1 || found[1].QueueNumber != 2 {
+			t.Errorf("FindByPatient(%q) = %+v, want queue numbers 1 and 2", phoneNumber, found)
+		}
+	}
+	if found := store.FindByPatient(day.AddDate(0, 0, -1), "081234567890"); len(found) != 1 {
+		t.Errorf("the day before has %d prescriptions, want 1", len(found))
+	}
+	if found := store.FindByPatient(day, "-"); len(found) != 0 {
+		t.Errorf("patients without a phone number were found: %+v", found)
+	}
+}
+
+func TestFindByPatientAfterAFailedAdd(t *testing.T) {
+	store, err := NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	store.path = unwritablePath(t)
+
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah", PatientPhoneNumber: "6281234567890"}, "6289999999999", 1, NowWIB())
+	if _, err := store.Add(record, "6289999999999", "6281111111111"); err == nil {
+		t.Fatal("Add succeeded although the store could not be saved")
+	}
+	// The patient must not become a sender the bot talks to
+	if found := store.FindByPatient(NowWIB(), "6281234567890"); len(found) != 0 {
+		t.Fatalf("FindByPatient = %+v after a failed add, want nothing", found)
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// WIB is the timezone the pharmacy works in (UTC+7). A fixed zone is used so the
+// bot does not depend on tzdata being installed in the container.
+var WIB = time.FixedZone("WIB", 7*60*60)
+
+// NowWIB returns the current time in WIB.
+func NowWIB() time.Time {
+	return time.Now().In(WIB)
+}
+
+// QueueCounter hands out daily queue numbers per pharmacy.
+type QueueCounter interface {
+	Next(pharmacy string) (int, error)
+}
+
+// --- FileQueueCounter persists the last issued number per date and pharmacy ---
+type FileQueueCounter struct {
+	mu       sync.Mutex
+	path     string
+	counters map[string]int
+	now      func() time.Time
+}
+
+// NewFileQueueCounter loads the counters stored at path. An empty path keeps them in memory only.
+func NewFileQueueCounter(path string) (*FileQueueCounter, error) {
+	q := &FileQueueCounter{
+		path:     path,
+		counters: make(map[string]int),
+		now:      NowWIB,
+	}
+	if path != "" {
+		if err := readJSONFile(path, &q.counters); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Next returns the next queue number of today (WIB) for the given pharmacy.
+// The number is persisted before it is returned so it is never handed out twice.
+func (q *FileQueueCounter) Next(pharmacy string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	today := q.now().In(WIB).Format("2006-01-02")
+	key := today + "|" + pharmacy
+
+	// Drop the counters of previous days, the queue starts at 1 every day
+	for k := range q.counters {
+		if !strings.HasPrefix(k, today+"|") {
+			delete(q.counters, k)
+		}
+	}
+
+	q.counters[key]++
+	number := q.counters[key]
+
+	if q.path != "" {
+		if err := writeJSONFile(q.path, q.counters); err != nil {
+			q.counters[key]--
+			return 0, err
+		}
+	}
+	return number, nil
+}
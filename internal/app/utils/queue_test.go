@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileQueueCounterParallelNext(t *testing.T) {
+	q, err := NewFileQueueCounter(filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatalf("NewFileQueueCounter: %v", err)
+	}
+
+	const callers = 50
+	numbers := make(chan int, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			number, err := q.Next("6281111111111")
+			if err != nil {
+				t.Errorf("Next: %v", err)
+				return
+			}
+			numbers <- number
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+
+	seen := make(map[int]bool)
+	for number := range numbers {
+		if seen[number] {
+			t.Fatalf("queue number %d handed out twice", number)
+		}
+		seen[number] = true
+	}
+	for number := 1; number <= callers; number++ {
+		if !seen[number] {
+			t.Fatalf("queue number %d was skipped", number)
+		}
+	}
+}
+
+func TestFileQueueCounterSurvivesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	now := time.Date(2025, 1, 2, 9, 0, 0, 0, WIB)
+
+	q, err := NewFileQueueCounter(path)
+	if err != nil {
+		t.Fatalf("NewFileQueueCounter: %v", err)
+	}
+	q.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := q.Next("pharmacy-a"); err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+	}
+	if _, err := q.Next("pharmacy-b"); err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+
+	reloaded, err := NewFileQueueCounter(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	reloaded.now = func() time.Time { return now }
+
+	if number, _ := reloaded.Next("pharmacy-a"); number != 4 {
+		t.Fatalf("pharmacy-a after reload = %d, want 4", number)
+	}
+	if number, _ := reloaded.Next("pharmacy-b"); number != 2 {
+		t.Fatalf("pharmacy-b after reload = %d, want 2", number)
+	}
+}
+
+func TestFileQueueCounterRollsOverAtWIBMidnight(t *testing.T) {
+	q, err := NewFileQueueCounter("")
+	if err != nil {
+		t.Fatalf("NewFileQueueCounter: %v", err)
+	}
+
+	// 16:59 UTC is 23:59 WIB, one minute later it is the next day in Jakarta but not in UTC
+	now := time.Date(2025, 1, 2, 16, 59, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	for want := 1; want <= 2; want++ {
+		if number, _ := q.Next("pharmacy"); number != want {
+			t.Fatalf("before midnight = %d, want %d", number, want)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	if number, _ := q.Next("pharmacy"); number != 1 {
+		t.Fatalf("after WIB midnight = %d, want 1", number)
+	}
+	if len(q.counters) != 1 {
+		t.Fatalf("counters of the previous day were kept: %v", q.counters)
+	}
+}
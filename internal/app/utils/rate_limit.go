@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows each key at most limit events per sliding window.
+// Keys without events inside the window are forgotten, so every number that ever
+// sent a message does not stay in memory.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	events    map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (r *RateLimiter) Allow(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	cutoff := now.Add(-r.window)
+
+	// Forget the other keys that went quiet, at most once per window
+	if now.Sub(r.lastSweep) >= r.window {
+		r.lastSweep = now
+		for other := range r.events {
+			r.prune(other, cutoff)
+		}
+	}
+
+	if r.prune(key, cutoff) >= r.limit {
+		return false
+	}
+
+	r.events[key] = append(r.events[key], now)
+	return true
+}
+
+// prune drops the events of key before cutoff and returns how many are left.
+// A key without events left is deleted.
+func (r *RateLimiter) prune(key string, cutoff time.Time) int {
+	recent := r.events[key][:0]
+	for _, at := range r.events[key] {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+
+	if len(recent) == 0 {
+		delete(r.events, key)
+		return 0
+	}
+	r.events[key] = recent
+	return len(recent)
+}
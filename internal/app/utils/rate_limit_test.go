@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	if !limiter.Allow("6281111111111") || !limiter.Allow("6281111111111") {
+		t.Fatal("the first two events were refused")
+	}
+	if limiter.Allow("6281111111111") {
+		t.Fatal("the third event inside the window was allowed")
+	}
+	// Every key has its own limit
+	if !limiter.Allow("6282222222222") {
+		t.Fatal("another key was refused")
+	}
+
+	// The window slides: the first events expire one by one, refused events do not count
+	now = now.Add(61 * time.Second)
+	if !limiter.Allow("6281111111111") {
+		t.Fatal("an event after the window was refused")
+	}
+	now = now.Add(30 * time.Second)
+	if !limiter.Allow("6281111111111") {
+		t.Fatal("the second event of the new window was refused")
+	}
+	if limiter.Allow("6281111111111") {
+		t.Fatal("the third event of the new window was allowed")
+	}
+}
+
+func TestRateLimiterForgetsQuietKeys(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(5, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for _, key := range []string{"6281111111111", "6282222222222", "6283333333333"} {
+		limiter.Allow(key)
+	}
+
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("6284444444444")
+
+	if len(limiter.events) != 1 {
+		t.Fatalf("keys kept = %d, want only the key inside the window", len(limiter.events))
+	}
+	if _, ok := limiter.events["6284444444444"]; !ok {
+		t.Fatal("the active key was forgotten")
+	}
+}
@@ -4,17 +4,38 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
 
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 )
 
+// Column J of the Prescriptions sheet holds the prescription ID and column K its status.
+// The Medications sheet has a row per medication line, its column A is the prescription ID.
+const (
+	prescriptionSheetTitle   = "Prescriptions"
+	prescriptionIDColumn     = "J"
+	prescriptionIDRange      = prescriptionSheetTitle + "!J:J"
+	prescriptionStatusColumn = "K"
+	medicationSheetTitle     = "Medications"
+	medicationIDRange        = medicationSheetTitle + "!A:A"
+)
+
 type SheetService struct {
 	client        *sheets.Service
 	spreadsheetID string
+
+	mu               sync.Mutex
+	hasMedicationTab bool           // Set once the Medications sheet is known to exist
+	hasHeader        bool           // Set once row 1 of the Prescriptions sheet was checked
+	rows             map[string]int // Row of each prescription ID as column J was last read
 }
 
+// prescriptionHeader is the header row of the Prescriptions sheet, the ledger columns and the status
+var prescriptionHeader = append(append([]interface{}{}, ledgerHeader...), "Status")
+
 func NewSheetService(credentialsFile string, spreadsheetID string) (*SheetService, error) {
 	ctx := context.Background()
 
@@ -30,18 +51,18 @@ func NewSheetService(credentialsFile string, spreadsheetID string) (*SheetServic
 	}, nil
 }
 
-func (s *SheetService) AddPrescriptionRow(details *PatientDetails, Queue int) error {
-	writeRange := "Prescriptions"
-
-	var row []interface{}
+func (s *SheetService) AddPrescriptionRow(record *PrescriptionRecord) error {
+	writeRange := prescriptionSheetTitle
 
-	wibTime := time.Now().UTC().Add(7 * time.Hour)
+	if err := s.ensurePrescriptionHeader(); err != nil {
+		return err
+	}
 
-	// Format: "02 January 2006 15:04 WIB"
-	formattedTime := wibTime.Format("02 January 2006 15:04") + " WIB"
+	var row []interface{}
 
+	details := record.Details
 	row = append(row,
-		Queue,
+		record.QueueNumber,
 		details.DoctorName,
 		details.PatientName,
 		details.PatientBirthDate,
@@ -49,7 +70,9 @@ func (s *SheetService) AddPrescriptionRow(details *PatientDetails, Queue int) er
 		details.Medication,
 		details.PatientPhoneNumber,
 		details.PaymentMethod,
-		formattedTime,
+		record.FormattedTime(), // Format: "02 January 2006 15:04 WIB"
+		record.ID,
+		StatusLabel(StatusReceived),
 	)
 
 	// 3. Create the data structure the API needs
@@ -58,12 +81,213 @@ func (s *SheetService) AddPrescriptionRow(details *PatientDetails, Queue int) er
 	}
 
 	// 4. Make the API call to append the data
-	_, err := s.client.Spreadsheets.Values.Append(s.spreadsheetID, writeRange, valueRange).ValueInputOption("USER_ENTERED").Do()
+	resp, err := s.client.Spreadsheets.Values.Append(s.spreadsheetID, writeRange, valueRange).ValueInputOption("USER_ENTERED").Do()
 	if err != nil {
 		log.Printf("Unable to write data to sheet: %v", err)
 		return err
 	}
+	if resp.Updates != nil {
+		if rowNumber := rangeStartRow(resp.Updates.UpdatedRange); rowNumber > 0 {
+			s.mu.Lock()
+			if s.rows == nil {
+				s.rows = make(map[string]int)
+			}
+			s.rows[record.ID] = rowNumber
+			s.mu.Unlock()
+		}
+	}
 
 	log.Println("Successfully added a row to the spreadsheet.")
+
+	return s.addMedicationRows(record)
+}
+
+// addMedicationRows appends the medication lines of the record to the Medications sheet,
+// unless an earlier attempt already wrote them.
+func (s *SheetService) addMedicationRows(record *PrescriptionRecord) error {
+	rows := medicationRows(record)
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := s.ensureMedicationSheet(); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Spreadsheets.Values.Get(s.spreadsheetID, medicationIDRange).Do()
+	if err != nil {
+		return fmt.Errorf("unable to read medication rows: %v", err)
+	}
+	for _, row := range resp.Values {
+		if len(row) > 0 && fmt.Sprint(row[0]) == record.ID {
+			return nil
+		}
+	}
+
+	valueRange := &sheets.ValueRange{Values: rows}
+	_, err = s.client.Spreadsheets.Values.Append(s.spreadsheetID, medicationSheetTitle, valueRange).ValueInputOption("USER_ENTERED").Do()
+	return err
+}
+
+// ensureMedicationSheet adds the Medications sheet with its header row to spreadsheets made before it existed.
+func (s *SheetService) ensureMedicationSheet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasMedicationTab {
+		return nil
+	}
+
+	spreadsheet, err := s.client.Spreadsheets.Get(s.spreadsheetID).Fields("sheets.properties.title").Do()
+	if err != nil {
+		return fmt.Errorf("unable to read spreadsheet sheets: %v", err)
+	}
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties != nil && sheet.Properties.Title == medicationSheetTitle {
+			s.hasMedicationTab = true
+			return nil
+		}
+	}
+
+	addSheet := &sheets.BatchUpdateSpreadsheetRequest{Requests: []*sheets.Request{{
+		AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: medicationSheetTitle}},
+	}}}
+	if _, err := s.client.Spreadsheets.BatchUpdate(s.spreadsheetID, addSheet).Do(); err != nil {
+		return fmt.Errorf("unable to add %s sheet: %v", medicationSheetTitle, err)
+	}
+	header := &sheets.ValueRange{Values: [][]interface{}{medicationHeader}}
+	if _, err := s.client.Spreadsheets.Values.Update(s.spreadsheetID, medicationSheetTitle+"!A1", header).ValueInputOption("RAW").Do(); err != nil {
+		return fmt.Errorf("unable to write %s header: %v", medicationSheetTitle, err)
+	}
+
+	log.Printf("Added the %s sheet to the spreadsheet", medicationSheetTitle)
+	s.hasMedicationTab = true
+	return nil
+}
+
+// ensurePrescriptionHeader writes the header cells row 1 of the Prescriptions sheet is missing,
+// the whole row for an empty sheet and the ID and status columns for sheets made before they existed.
+func (s *SheetService) ensurePrescriptionHeader() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasHeader {
+		return nil
+	}
+
+	resp, err := s.client.Spreadsheets.Values.Get(s.spreadsheetID, prescriptionSheetTitle+"!A1:K1").Do()
+	if err != nil {
+		return fmt.Errorf("unable to read %s header: %v", prescriptionSheetTitle, err)
+	}
+	var firstRow []interface{}
+	if len(resp.Values) > 0 {
+		firstRow = resp.Values[0]
+	}
+
+	if cell, values := missingPrescriptionHeader(firstRow); len(values) > 0 {
+		header := &sheets.ValueRange{Values: [][]interface{}{values}}
+		if _, err := s.client.Spreadsheets.Values.Update(s.spreadsheetID, prescriptionSheetTitle+"!"+cell, header).ValueInputOption("RAW").Do(); err != nil {
+			return fmt.Errorf("unable to write %s header: %v", prescriptionSheetTitle, err)
+		}
+		log.Printf("Added the missing %s header cells from %s", prescriptionSheetTitle, cell)
+	}
+	s.hasHeader = true
+	return nil
+}
+
+// missingPrescriptionHeader returns the first cell and the values of the header cells row 1 lacks.
+// A row 1 that starts with a queue number is a prescription, then nothing is written.
+func missingPrescriptionHeader(firstRow []interface{}) (string, []interface{}) {
+	cell := func(i int) string {
+		if i < len(firstRow) {
+			return strings.TrimSpace(fmt.Sprint(firstRow[i]))
+		}
+		return ""
+	}
+
+	if len(firstRow) == 0 {
+		return "A1", prescriptionHeader
+	}
+	if _, err := strconv.Atoi(cell(0)); err == nil {
+		log.Printf("Row 1 of the %s sheet holds a prescription, not adding a header", prescriptionSheetTitle)
+		return "", nil
+	}
+
+	idIndex := len(ledgerHeader) - 1
+	if cell(idIndex) == "" {
+		return prescriptionIDColumn + "1", prescriptionHeader[idIndex:]
+	}
+	if cell(idIndex+1) == "" {
+		return prescriptionStatusColumn + "1", prescriptionHeader[idIndex+1:]
+	}
+	return "", nil
+}
+
+// FindPrescriptionRow returns the 1-based sheet row holding the prescription, or 0 when it is not in the sheet.
+// A known row is checked by reading its ID cell, column J is only read again when the row moved or is unknown.
+func (s *SheetService) FindPrescriptionRow(prescriptionID string) (int, error) {
+	s.mu.Lock()
+	rowNumber := s.rows[prescriptionID]
+	s.mu.Unlock()
+
+	if rowNumber > 0 {
+		cell := fmt.Sprintf("%s!%s%d", prescriptionSheetTitle, prescriptionIDColumn, rowNumber)
+		resp, err := s.client.Spreadsheets.Values.Get(s.spreadsheetID, cell).Do()
+		if err != nil {
+			return 0, fmt.Errorf("unable to read prescription ID: %v", err)
+		}
+		if len(resp.Values) > 0 && len(resp.Values[0]) > 0 && fmt.Sprint(resp.Values[0][0]) == prescriptionID {
+			return rowNumber, nil
+		}
+	}
+
+	resp, err := s.client.Spreadsheets.Values.Get(s.spreadsheetID, prescriptionIDRange).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to read prescription IDs: %v", err)
+	}
+
+	rows := make(map[string]int, len(resp.Values))
+	for i, row := range resp.Values {
+		if len(row) > 0 {
+			rows[fmt.Sprint(row[0])] = i + 1
+		}
+	}
+
+	s.mu.Lock()
+	s.rows = rows
+	s.mu.Unlock()
+	return rows[prescriptionID], nil
+}
+
+// rangeStartRow returns the first row of an A1 range such as "Prescriptions!A12:K12", or 0 when it has none.
+func rangeStartRow(a1Range string) int {
+	cells := a1Range[strings.LastIndex(a1Range, "!")+1:]
+	start := strings.TrimLeft(strings.SplitN(cells, ":", 2)[0], "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz$")
+	rowNumber, err := strconv.Atoi(start)
+	if err != nil {
+		return 0
+	}
+	return rowNumber
+}
+
+// UpdatePrescriptionStatus writes the status label to the row of the prescription.
+func (s *SheetService) UpdatePrescriptionStatus(prescriptionID, status string) error {
+	rowNumber, err := s.FindPrescriptionRow(prescriptionID)
+	if err != nil {
+		return err
+	}
+	if rowNumber == 0 {
+		return fmt.Errorf("prescription %s is not in the spreadsheet yet", prescriptionID)
+	}
+
+	writeRange := fmt.Sprintf("%s!%s%d", prescriptionSheetTitle, prescriptionStatusColumn, rowNumber)
+	valueRange := &sheets.ValueRange{
+		Values: [][]interface{}{{StatusLabel(status)}},
+	}
+
+	_, err = s.client.Spreadsheets.Values.Update(s.spreadsheetID, writeRange, valueRange).ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		log.Printf("Unable to update status in sheet: %v", err)
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// PendingRow is a prescription, or a status change of one, that has not been written to Google Sheets yet
+type PendingRow struct {
+	ID            string             `json:"id"`
+	Record        PrescriptionRecord `json:"record"`
+	Status        string             `json:"status,omitempty"` // Set when the row only updates the status
+	Attempts      int                `json:"attempts"`
+	LastError     string             `json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at"`
+}
+
+// spreadsheet is the part of SheetService the journal writes through
+type spreadsheet interface {
+	FindPrescriptionRow(prescriptionID string) (int, error)
+	AddPrescriptionRow(record *PrescriptionRecord) error
+	addMedicationRows(record *PrescriptionRecord) error
+	UpdatePrescriptionStatus(prescriptionID, status string) error
+}
+
+// SheetJournal durably keeps prescriptions until they are in the spreadsheet.
+// Rows are retried forever with backoff, and the sheet is checked for the prescription
+// ID before every append so a retry never writes the same row twice.
+// The rows of one prescription are written in journal order, so an older status never overwrites a newer one.
+type SheetJournal struct {
+	mu          sync.Mutex
+	path        string
+	rows        []PendingRow
+	sheet       spreadsheet
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	wake        chan struct{}
+	now         func() time.Time
+}
+
+func NewSheetJournal(path string, sheet *SheetService, baseBackoff, maxBackoff time.Duration) (*SheetJournal, error) {
+	j := &SheetJournal{
+		path:        path,
+		sheet:       sheet,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+	if path != "" {
+		if err := readJSONFile(path, &j.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rows journaled by older versions have no ID yet
+	for i := range j.rows {
+		if j.rows[i].ID == "" {
+			j.rows[i].ID = newID()
+		}
+	}
+	return j, nil
+}
+
+// Add journals a prescription for writing to the spreadsheet.
+func (j *SheetJournal) Add(record *PrescriptionRecord) error {
+	return j.add(PendingRow{ID: newID(), Record: *record})
+}
+
+// AddStatus journals a status change for a prescription that is, or will be, in the spreadsheet.
+func (j *SheetJournal) AddStatus(record *PrescriptionRecord, status string) error {
+	return j.add(PendingRow{ID: newID(), Record: *record, Status: status})
+}
+
+// add journals the row and wakes the worker. A row that cannot be saved is dropped again,
+// the caller reports the sink as failed so it must not reach the spreadsheet either.
+func (j *SheetJournal) add(row PendingRow) error {
+	j.mu.Lock()
+	row.NextAttemptAt = j.now()
+	j.rows = append(j.rows, row)
+	err := j.save()
+	if err != nil {
+		j.rows = j.rows[:len(j.rows)-1]
+	}
+	j.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	j.Flush()
+	return nil
+}
+
+// Name and Write make the journal a PrescriptionSink
+func (j *SheetJournal) Name() string {
+	return "Google Sheets"
+}
+
+func (j *SheetJournal) Write(record *PrescriptionRecord) error {
+	return j.Add(record)
+}
+
+func (j *SheetJournal) WriteStatus(record *PrescriptionRecord, status string) error {
+	return j.AddStatus(record, status)
+}
+
+// Deferred tells the doctor a journaled row is queued, the worker still has to write it to the spreadsheet
+func (j *SheetJournal) Deferred() bool {
+	return true
+}
+
+// Unsynced returns a copy of the rows that are not in the spreadsheet yet.
+func (j *SheetJournal) Unsynced() []PendingRow {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]PendingRow{}, j.rows...)
+}
+
+// Flush asks the worker to sync now instead of waiting for the next tick.
+func (j *SheetJournal) Flush() {
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RetryNow makes every journaled row due and wakes the worker.
+func (j *SheetJournal) RetryNow() {
+	j.mu.Lock()
+	now := j.now()
+	for i := range j.rows {
+		j.rows[i].NextAttemptAt = now
+	}
+	j.mu.Unlock()
+
+	j.Flush()
+}
+
+// Run writes journaled rows to the spreadsheet until ctx is cancelled.
+func (j *SheetJournal) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		j.sync()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-j.wake:
+		}
+	}
+}
+
+// sync writes every due row in journal order. Once a row of a prescription is waiting for its
+// backoff or fails, the later rows of that prescription wait for it.
+func (j *SheetJournal) sync() {
+	now := j.now()
+	blocked := make(map[string]bool)
+
+	for _, row := range j.Unsynced() {
+		if blocked[row.Record.ID] {
+			continue
+		}
+		if row.NextAttemptAt.After(now) {
+			blocked[row.Record.ID] = true
+			continue
+		}
+
+		var err error
+		if row.Status != "" {
+			err = j.sheet.UpdatePrescriptionStatus(row.Record.ID, row.Status)
+		} else {
+			err = j.write(&row.Record)
+		}
+		if err != nil {
+			blocked[row.Record.ID] = true
+		}
+		j.complete(row.ID, err)
+	}
+}
+
+// write appends the record unless a previous attempt already did. A previous attempt may have
+// stopped before the medication rows, those are only written when they are not in the sheet yet.
+func (j *SheetJournal) write(record *PrescriptionRecord) error {
+	rowNumber, err := j.sheet.FindPrescriptionRow(record.ID)
+	if err != nil {
+		return err
+	}
+	if rowNumber > 0 {
+		log.Printf("Prescription %s is already in the spreadsheet at row %d", record.ID, rowNumber)
+		return j.sheet.addMedicationRows(record)
+	}
+	return j.sheet.AddPrescriptionRow(record)
+}
+
+func (j *SheetJournal) complete(id string, writeErr error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for i := range j.rows {
+		if j.rows[i].ID != id {
+			continue
+		}
+
+		if writeErr == nil {
+			j.rows = append(j.rows[:i], j.rows[i+1:]...)
+		} else {
+			row := &j.rows[i]
+			row.Attempts++
+			row.LastError = writeErr.Error()
+			row.NextAttemptAt = j.now().Add(backoffDelay(j.baseBackoff, j.maxBackoff, row.Attempts))
+			log.Printf("Unable to sync prescription %s to the spreadsheet (attempt %d): %v", row.Record.ID, row.Attempts, writeErr)
+		}
+		break
+	}
+
+	if err := j.save(); err != nil {
+		log.Printf("Unable to save sheet journal: %v", err)
+	}
+}
+
+func (j *SheetJournal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	return writeJSONFile(j.path, j.rows)
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSheetJournalOnlySyncsRowsItStored(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	sheet := &fakeSheet{statuses: map[string]string{}, medications: map[string]int{}}
+	j := &SheetJournal{sheet: sheet, baseBackoff: time.Minute, maxBackoff: time.Hour, wake: make(chan struct{}, 1), now: func() time.Time { return now }}
+	j.path = unwritablePath(t)
+
+	// The doctor is told the sheet write failed, a row that reached the sheet anyway would be a surprise
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 1, now)
+	if err := j.Add(record); err == nil {
+		t.Fatal("Add succeeded although the journal could not be saved")
+	}
+	if err := j.AddStatus(record, StatusReady); err == nil {
+		t.Fatal("AddStatus succeeded although the journal could not be saved")
+	}
+	j.sync()
+
+	if len(sheet.rows) != 0 || len(sheet.statuses) != 0 {
+		t.Fatalf("sheet rows %v and statuses %v, want nothing written", sheet.rows, sheet.statuses)
+	}
+}
+
+// fakeSheet keeps the status and the medication rows of every prescription. It fails the first
+// status and medication writes while failStatus and failMedications are above zero.
+type fakeSheet struct {
+	rows            []string
+	statuses        map[string]string
+	medications     map[string]int
+	failStatus      int
+	failMedications int
+}
+
+func (s *fakeSheet) FindPrescriptionRow(prescriptionID string) (int, error) {
+	for i, id := range s.rows {
+		if id == prescriptionID {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *fakeSheet) AddPrescriptionRow(record *PrescriptionRecord) error {
+	s.rows = append(s.rows, record.ID)
+	s.statuses[record.ID] = StatusReceived
+	return s.addMedicationRows(record)
+}
+
+func (s *fakeSheet) addMedicationRows(record *PrescriptionRecord) error {
+	if s.failMedications > 0 {
+		s.failMedications--
+		return errors.New("quota exceeded")
+	}
+	if _, written := s.medications[record.ID]; !written {
+		s.medications[record.ID] = len(record.Details.Medications)
+	}
+	return nil
+}
+
+func (s *fakeSheet) UpdatePrescriptionStatus(prescriptionID, status string) error {
+	if s.failStatus > 0 {
+		s.failStatus--
+		return errors.New("quota exceeded")
+	}
+	s.statuses[prescriptionID] = status
+	return nil
+}
+
+func TestSheetJournalKeepsStatusOrderWhenAWriteFails(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	sheet := &fakeSheet{statuses: map[string]string{}, medications: map[string]int{}}
+	j := &SheetJournal{sheet: sheet, baseBackoff: time.Minute, maxBackoff: time.Hour, wake: make(chan struct{}, 1), now: func() time.Time { return now }}
+
+	record := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 1, now)
+	other := NewPrescriptionRecord(&PatientDetails{PatientName: "Budi Santoso"}, "6289999999999", 2, now)
+	if err := j.Add(record); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	j.sync()
+
+	sheet.failStatus = 1
+	for _, add := range []func() error{
+		func() error { return j.AddStatus(record, StatusPreparing) },
+		func() error { return j.AddStatus(record, StatusReady) },
+		func() error { return j.Add(other) },
+	} {
+		if err := add(); err != nil {
+			t.Fatalf("journal row: %v", err)
+		}
+	}
+	j.sync()
+
+	if got := sheet.statuses[record.ID]; got != StatusReceived {
+		t.Fatalf("status = %q after the preparing write failed, the ready row must wait for it", got)
+	}
+	if _, ok := sheet.statuses[other.ID]; !ok {
+		t.Fatal("a failed status row held back the rows of another prescription")
+	}
+	if rows := j.Unsynced(); len(rows) != 2 || rows[0].Status != StatusPreparing || rows[1].Status != StatusReady {
+		t.Fatalf("unsynced rows = %+v, want preparing then ready", rows)
+	}
+
+	// Before the backoff of the failed row ends nothing of the prescription is written
+	now = now.Add(30 * time.Second)
+	j.sync()
+	if got := sheet.statuses[record.ID]; got != StatusReceived {
+		t.Fatalf("status = %q while the preparing row is backing off", got)
+	}
+
+	now = now.Add(time.Minute)
+	j.sync()
+	if got := sheet.statuses[record.ID]; got != StatusReady {
+		t.Fatalf("status = %q, want the newest status %q", got, StatusReady)
+	}
+	if rows := j.Unsynced(); len(rows) != 0 {
+		t.Fatalf("rows left in the journal: %+v", rows)
+	}
+}
+
+func TestSheetJournalRetriesMedicationRows(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	sheet := &fakeSheet{statuses: map[string]string{}, medications: map[string]int{}, failMedications: 1}
+	j := &SheetJournal{sheet: sheet, baseBackoff: time.Minute, maxBackoff: time.Hour, wake: make(chan struct{}, 1), now: func() time.Time { return now }}
+
+	details := &PatientDetails{PatientName: "Siti Aminah", Medications: []MedicationItem{{Name: "Paracetamol"}, {Name: "CTM"}}}
+	record := NewPrescriptionRecord(details, "6289999999999", 1, now)
+	if err := j.Add(record); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	j.sync()
+
+	if rows := j.Unsynced(); len(rows) != 1 || rows[0].Attempts != 1 {
+		t.Fatalf("unsynced rows = %+v, want the prescription kept for its medication rows", rows)
+	}
+
+	now = now.Add(time.Minute)
+	j.sync()
+	if len(sheet.rows) != 1 {
+		t.Fatalf("prescription rows = %v, the retry appended the prescription again", sheet.rows)
+	}
+	if got := sheet.medications[record.ID]; got != 2 {
+		t.Fatalf("medication rows = %d, want 2", got)
+	}
+	if rows := j.Unsynced(); len(rows) != 0 {
+		t.Fatalf("rows left in the journal: %+v", rows)
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingPrescriptionHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		firstRow   []interface{}
+		wantCell   string
+		wantValues []interface{}
+	}{
+		{"empty sheet", nil, "A1", prescriptionHeader},
+		{"header without ID and status", ledgerHeader[:9], "J1", []interface{}{"ID Resep", "Status"}},
+		{"header without status", ledgerHeader, "K1", []interface{}{"Status"}},
+		{"blank status cell", append(append([]interface{}{}, ledgerHeader...), " "), "K1", []interface{}{"Status"}},
+		{"complete header", prescriptionHeader, "", nil},
+		{"prescription in row 1", []interface{}{"1", "Budi", "Siti Aminah"}, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell, values := missingPrescriptionHeader(tt.firstRow)
+			if cell != tt.wantCell || !reflect.DeepEqual(values, tt.wantValues) {
+				t.Fatalf("missingPrescriptionHeader = %q %v, want %q %v", cell, values, tt.wantCell, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestRangeStartRow(t *testing.T) {
+	tests := []struct {
+		a1Range string
+		want    int
+	}{
+		{"Prescriptions!A12:K12", 12},
+		{"'Prescriptions'!A3:K3", 3},
+		{"Prescriptions!$A$7", 7},
+		{"Prescriptions!A:K", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := rangeStartRow(tt.a1Range); got != tt.want {
+			t.Errorf("rangeStartRow(%q) = %d, want %d", tt.a1Range, got, tt.want)
+		}
+	}
+}
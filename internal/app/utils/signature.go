@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// WebhookSignatureHeader is the header GOWA uses to sign webhook payloads.
+const WebhookSignatureHeader = "X-Hub-Signature-256"
+
+// SignWebhookPayload returns the signature GOWA would send for body, formatted as "sha256=<hex>".
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the HMAC-SHA256 signature of the raw webhook body.
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimSpace(signature)
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	hexSignature := strings.TrimPrefix(signature, "sha256=")
+	received, err := hex.DecodeString(hexSignature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"log"
+)
+
+// PrescriptionSink is a backend that keeps a record of confirmed prescriptions
+type PrescriptionSink interface {
+	Name() string
+	Write(record *PrescriptionRecord) error
+}
+
+// PrescriptionStatusSink is implemented by sinks that also track the status of a prescription
+type PrescriptionStatusSink interface {
+	WriteStatus(record *PrescriptionRecord, status string) error
+}
+
+// DeferredSink is implemented by sinks that only journal a prescription for a background worker,
+// a successful Write means the record is queued and not yet in the backend
+type DeferredSink interface {
+	Deferred() bool
+}
+
+// SinkResult is the outcome of writing a prescription to one sink
+type SinkResult struct {
+	Sink    string `json:"sink"`
+	Success bool   `json:"success"`
+	Queued  bool   `json:"queued,omitempty"` // Success of a DeferredSink, the record still has to reach the backend
+	Error   string `json:"error,omitempty"`
+}
+
+// MultiSink fans a prescription out to every configured sink.
+type MultiSink struct {
+	sinks []PrescriptionSink
+}
+
+func NewMultiSink(sinks ...PrescriptionSink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Write writes the record to every sink, a failing sink does not stop the others.
+func (m *MultiSink) Write(record *PrescriptionRecord) []SinkResult {
+	results := make([]SinkResult, 0, len(m.sinks))
+	for _, sink := range m.sinks {
+		result := SinkResult{Sink: sink.Name(), Success: true}
+		if err := sink.Write(record); err != nil {
+			log.Printf("Unable to write prescription %s to %s: %v", record.ID, sink.Name(), err)
+			result.Success = false
+			result.Error = err.Error()
+		}
+		result.Queued = result.Success && isDeferred(sink)
+		results = append(results, result)
+	}
+	return results
+}
+
+// WriteStatus passes a status change to every sink that tracks statuses.
+func (m *MultiSink) WriteStatus(record *PrescriptionRecord, status string) []SinkResult {
+	var results []SinkResult
+	for _, sink := range m.sinks {
+		statusSink, ok := sink.(PrescriptionStatusSink)
+		if !ok {
+			continue
+		}
+
+		result := SinkResult{Sink: sink.Name(), Success: true}
+		if err := statusSink.WriteStatus(record, status); err != nil {
+			log.Printf("Unable to write status of prescription %s to %s: %v", record.ID, sink.Name(), err)
+			result.Success = false
+			result.Error = err.Error()
+		}
+		result.Queued = result.Success && isDeferred(sink)
+		results = append(results, result)
+	}
+	return results
+}
+
+// isDeferred reports whether a successful write to sink only queued the record
+func isDeferred(sink PrescriptionSink) bool {
+	deferred, ok := sink.(DeferredSink)
+	return ok && deferred.Deferred()
+}
+
+// Names returns the names of the configured sinks
+func (m *MultiSink) Names() []string {
+	names := make([]string, 0, len(m.sinks))
+	for _, sink := range m.sinks {
+		names = append(names, sink.Name())
+	}
+	return names
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+// stubSink returns err from every write and is deferred when deferred is set
+type stubSink struct {
+	name     string
+	deferred bool
+	err      error
+}
+
+func (s *stubSink) Name() string {
+	return s.name
+}
+
+func (s *stubSink) Write(record *PrescriptionRecord) error {
+	return s.err
+}
+
+func (s *stubSink) Deferred() bool {
+	return s.deferred
+}
+
+func TestMultiSinkReportsDeferredSinksAsQueued(t *testing.T) {
+	sinks := NewMultiSink(
+		&stubSink{name: "Excel"},
+		&stubSink{name: "Google Sheets", deferred: true},
+		&stubSink{name: "Journal", deferred: true, err: errors.New("disk full")},
+	)
+
+	want := []SinkResult{
+		{Sink: "Excel", Success: true},
+		{Sink: "Google Sheets", Success: true, Queued: true},
+		{Sink: "Journal", Error: "disk full"},
+	}
+	results := sinks.Write(&PrescriptionRecord{ID: "RX-1"})
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v, want %+v", results, want)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+}
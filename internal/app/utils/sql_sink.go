@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+const createPrescriptionsTable = `CREATE TABLE IF NOT EXISTS prescriptions (
+	id TEXT PRIMARY KEY,
+	queue_number INTEGER NOT NULL,
+	doctor_name TEXT NOT NULL,
+	patient_name TEXT NOT NULL,
+	patient_birth_date TEXT NOT NULL,
+	registry_num TEXT NOT NULL,
+	medication TEXT NOT NULL,
+	patient_phone_number TEXT NOT NULL,
+	payment_method TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`
+
+const createMedicationsTable = `CREATE TABLE IF NOT EXISTS prescription_medications (
+	prescription_id TEXT NOT NULL REFERENCES prescriptions (id),
+	line INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	strength TEXT NOT NULL,
+	form TEXT NOT NULL,
+	quantity INTEGER,
+	signa TEXT NOT NULL,
+	raw TEXT NOT NULL,
+	PRIMARY KEY (prescription_id, line)
+)`
+
+// SQLSink stores prescriptions in a SQLite database. The queries use SQLite placeholders
+// and its ON CONFLICT clause, so it is the only database supported.
+type SQLSink struct {
+	db *sql.DB
+}
+
+func NewSQLSink(dsn string) (*SQLSink, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %v", err)
+	}
+	if _, err := db.Exec(createPrescriptionsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create prescriptions table: %v", err)
+	}
+	if _, err := db.Exec(createMedicationsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create prescription_medications table: %v", err)
+	}
+	return &SQLSink{db: db}, nil
+}
+
+func (s *SQLSink) Name() string {
+	return "Database"
+}
+
+// Write inserts the record with its medication lines, writing the same prescription twice is a no-op.
+func (s *SQLSink) Write(record *PrescriptionRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("unable to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	details := record.Details
+	_, err = tx.Exec(`INSERT INTO prescriptions (
+		id, queue_number, doctor_name, patient_name, patient_birth_date, registry_num,
+		medication, patient_phone_number, payment_method, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		record.ID,
+		record.QueueNumber,
+		details.DoctorName,
+		details.PatientName,
+		details.PatientBirthDate,
+		strings.TrimPrefix(details.RegistryNum, "'"),
+		details.Medication,
+		details.PatientPhoneNumber,
+		details.PaymentMethod,
+		record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to insert prescription %s: %v", record.ID, err)
+	}
+
+	for i, item := range details.Medications {
+		var quantity any
+		if item.QuantityText != "" {
+			quantity = item.Quantity
+		}
+		_, err := tx.Exec(`INSERT INTO prescription_medications (
+			prescription_id, line, name, strength, form, quantity, signa, raw
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (prescription_id, line) DO NOTHING`,
+			record.ID, i+1, item.Name, item.Strength, item.Form, quantity, item.Signa, item.Raw,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to insert medication %d of prescription %s: %v", i+1, record.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to insert prescription %s: %v", record.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLSink) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLSinkWritesPrescriptionsAndMedications(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "prescriptions.db")
+	sink, err := NewSQLSink(dsn)
+	if err != nil {
+		t.Fatalf("NewSQLSink: %v", err)
+	}
+	defer sink.Close()
+
+	record := testSinkRecord(7)
+	// Writing the same prescription again, e.g. on a retry, adds nothing
+	for i := 0; i < 2; i++ {
+		if err := sink.Write(record); err != nil {
+			t.Fatalf("Write %d: %v", i+1, err)
+		}
+	}
+
+	// Read back through a second connection, as another program would
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	var count, queueNumber int
+	var registryNum, medication, payment string
+	err = db.QueryRow(`SELECT COUNT(*), MAX(queue_number), MAX(registry_num), MAX(medication), MAX(payment_method) FROM prescriptions`).
+		Scan(&count, &queueNumber, &registryNum, &medication, &payment)
+	if err != nil {
+		t.Fatalf("query prescriptions: %v", err)
+	}
+	if count != 1 || queueNumber != 7 || registryNum != "012345" || medication != record.Details.Medication || payment != "BPJS" {
+		t.Fatalf("prescriptions: %d rows, queue %d, registry %q, medication %q, payment %q", count, queueNumber, registryNum, medication, payment)
+	}
+
+	rows, err := db.Query(`SELECT line, name, strength, quantity, signa FROM prescription_medications WHERE prescription_id = ? ORDER BY line`, record.ID)
+	if err != nil {
+		t.Fatalf("query medications: %v", err)
+	}
+	defer rows.Close()
+
+	type line struct {
+		line     int
+		name     string
+		strength string
+		quantity sql.NullInt64
+		signa    string
+	}
+	want := []line{
+		{1, "Amoxicillin", "500 mg", sql.NullInt64{Int64: 15, Valid: true}, "3dd1"},
+		// No quantity is NULL, not 0
+		{2, "Vitamin C", "", sql.NullInt64{}, ""},
+	}
+	var got []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.line, &l.name, &l.strength, &l.quantity, &l.signa); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got = append(got, l)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("medications = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("medication %d = %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+}
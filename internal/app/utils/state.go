@@ -1,46 +1,151 @@
 package utils
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
 // --- Define the conversation states as constants for safety ---
 const (
-	StateAwaitingStart        = "AWAITING_START"
-	StateAwaitingMenuChoice   = "AWAITING_MENU_CHOICE"
+	StateAwaitingStart          = "AWAITING_START"
+	StateAwaitingMenuChoice     = "AWAITING_MENU_CHOICE"
 	StateAwaitingFormSubmission = "AWAITING_FORM_SUBMISSION"
-	StateAwaitingConfirmation = "AWAITING_CONFIRMATION"
+	StateAwaitingConfirmation   = "AWAITING_CONFIRMATION"
+)
+
+// Supported StateStore drivers
+const (
+	StateStoreMemory = "memory"
+	StateStoreFile   = "file"
 )
 
 // --- UserState holds the conversation context for a single user ---
 type UserState struct {
-	State          string
-	PendingMessage string // Used to temporarily store the form message for confirmation
+	State          string `json:"state"`
+	PendingMessage string `json:"pending_message"` // Used to temporarily store the form message for confirmation
+	// Order is the confirmed prescription once it has a queue number and was recorded, so a retry
+	// after a failed send to the pharmacy does not take another queue number or write the rows twice
+	Order       *PrescriptionRecord `json:"order,omitempty"`
+	SinkResults []SinkResult        `json:"sink_results,omitempty"`
 }
 
-// --- In-memory store for user states. Replace with a database in production. ---
-var (
-	userStates = make(map[string]*UserState)
-	mu         sync.Mutex // Mutex to prevent race conditions when accessing the map
-)
+// StateStore keeps the conversation state of every user.
+// Get always returns a copy, callers must Save it back after changing it.
+type StateStore interface {
+	Get(phoneNumber string) (*UserState, error)
+	Save(phoneNumber string, state *UserState) error
+	Reset(phoneNumber string) error
+}
+
+// NewStateStore creates the StateStore selected by driver.
+func NewStateStore(driver, path string) (StateStore, error) {
+	switch driver {
+	case StateStoreMemory, "":
+		return NewMemoryStateStore(), nil
+	case StateStoreFile:
+		return NewFileStateStore(path)
+	default:
+		return nil, fmt.Errorf("unknown state store driver: %s", driver)
+	}
+}
+
+// --- MemoryStateStore keeps user states in memory, they are lost on restart ---
+type MemoryStateStore struct {
+	mu     sync.Mutex // Mutex to prevent race conditions when accessing the map
+	states map[string]UserState
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[string]UserState)}
+}
+
+// Get retrieves the state for a user, new users start at StateAwaitingStart.
+func (s *MemoryStateStore) Get(phoneNumber string) (*UserState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, exists := s.states[phoneNumber]; exists {
+		return &state, nil
+	}
+	return &UserState{State: StateAwaitingStart}, nil
+}
+
+func (s *MemoryStateStore) Save(phoneNumber string, state *UserState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-// getOrCreateUserState retrieves the state for a user, creating it if it doesn't exist.
-func GetOrCreateUserState(phoneNumber string) *UserState {
-	mu.Lock()
-	defer mu.Unlock()
+	s.states[phoneNumber] = *state
+	return nil
+}
+
+// Reset resets a user's state to the beginning.
+func (s *MemoryStateStore) Reset(phoneNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, phoneNumber)
+	return nil
+}
+
+// --- FileStateStore keeps user states in a JSON file so they survive restarts ---
+type FileStateStore struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]UserState
+}
+
+func NewFileStateStore(path string) (*FileStateStore, error) {
+	s := &FileStateStore{
+		path:   path,
+		states: make(map[string]UserState),
+	}
+	if err := readJSONFile(path, &s.states); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStateStore) Get(phoneNumber string) (*UserState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if state, exists := userStates[phoneNumber]; exists {
-		return state
+	if state, exists := s.states[phoneNumber]; exists {
+		return &state, nil
 	}
+	return &UserState{State: StateAwaitingStart}, nil
+}
 
-	// User doesn't exist, create a new state
-	newState := &UserState{State: StateAwaitingStart}
-	userStates[phoneNumber] = newState
-	return newState
+// Save stores the state, the previous state is kept when the file cannot be written
+func (s *FileStateStore) Save(phoneNumber string, state *UserState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.states[phoneNumber]
+	s.states[phoneNumber] = *state
+	if err := writeJSONFile(s.path, s.states); err != nil {
+		if existed {
+			s.states[phoneNumber] = previous
+		} else {
+			delete(s.states, phoneNumber)
+		}
+		return err
+	}
+	return nil
 }
 
-// resetUserState resets a user's state to the beginning.
-func ResetUserState(phoneNumber string) {
-    mu.Lock()
-	defer mu.Unlock()
-    // We can just create a new one, letting the garbage collector handle the old one.
-    userStates[phoneNumber] = &UserState{State: StateAwaitingStart}
-}
\ No newline at end of file
+// Reset forgets the state, it is kept when the file cannot be written
+func (s *FileStateStore) Reset(phoneNumber string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, exists := s.states[phoneNumber]
+	if !exists {
+		return nil
+	}
+	delete(s.states, phoneNumber)
+	if err := writeJSONFile(s.path, s.states); err != nil {
+		s.states[phoneNumber] = previous
+		return err
+	}
+	return nil
+}
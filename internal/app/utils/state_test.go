@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testStatePhone = "6281111111111"
+
+func TestFileStateStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "states.json")
+	store, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore: %v", err)
+	}
+
+	order := NewPrescriptionRecord(&PatientDetails{PatientName: "Siti Aminah"}, "6289999999999", 4, time.Now())
+	state := &UserState{State: StateAwaitingConfirmation, PendingMessage: "Nama Pasien: Siti Aminah", Order: order}
+	if err := store.Save(testStatePhone, state); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save("6282222222222", &UserState{State: StateAwaitingMenuChoice}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Reset("6282222222222"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+
+	// A doctor who was confirming goes on confirming after the restart, with the queue number already taken
+	restarted, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	got, _ := restarted.Get(testStatePhone)
+	if got.State != StateAwaitingConfirmation || got.PendingMessage != state.PendingMessage || got.Order == nil || got.Order.QueueNumber != 4 {
+		t.Fatalf("state after restart = %+v", got)
+	}
+	if reset, _ := restarted.Get("6282222222222"); reset.State != StateAwaitingStart {
+		t.Fatalf("reset state after restart = %+v, want a new conversation", reset)
+	}
+}
+
+func TestFileStateStoreKeepsTheStepWhenSaveFails(t *testing.T) {
+	store, err := NewFileStateStore(filepath.Join(t.TempDir(), "states.json"))
+	if err != nil {
+		t.Fatalf("NewFileStateStore: %v", err)
+	}
+	if err := store.Save(testStatePhone, &UserState{State: StateAwaitingFormSubmission}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	store.path = unwritablePath(t)
+
+	// The webhook fails and is retried, the retry has to find the doctor where the failed step started
+	if err := store.Save(testStatePhone, &UserState{State: StateAwaitingConfirmation, PendingMessage: "form"}); err == nil {
+		t.Fatal("Save succeeded although the file could not be written")
+	}
+	if got, _ := store.Get(testStatePhone); got.State != StateAwaitingFormSubmission || got.PendingMessage != "" {
+		t.Fatalf("state = %+v, want the step before the failed save", got)
+	}
+
+	if err := store.Reset(testStatePhone); err == nil {
+		t.Fatal("Reset succeeded although the file could not be written")
+	}
+	if got, _ := store.Get(testStatePhone); got.State != StateAwaitingFormSubmission {
+		t.Fatalf("state = %+v, want it kept after the failed reset", got)
+	}
+
+	// A new conversation that could not be saved does not exist either
+	if err := store.Save("6282222222222", &UserState{State: StateAwaitingMenuChoice}); err == nil {
+		t.Fatal("Save succeeded although the file could not be written")
+	}
+	if got, _ := store.Get("6282222222222"); got.State != StateAwaitingStart {
+		t.Fatalf("state = %+v, want a new conversation", got)
+	}
+}
+
+func TestStateStoreGetReturnsACopy(t *testing.T) {
+	file, err := NewFileStateStore(filepath.Join(t.TempDir(), "states.json"))
+	if err != nil {
+		t.Fatalf("NewFileStateStore: %v", err)
+	}
+
+	for name, store := range map[string]StateStore{"memory": NewMemoryStateStore(), "file": file} {
+		if err := store.Save(testStatePhone, &UserState{State: StateAwaitingMenuChoice}); err != nil {
+			t.Fatalf("%s: Save: %v", name, err)
+		}
+		got, _ := store.Get(testStatePhone)
+		got.State = StateAwaitingConfirmation
+		if again, _ := store.Get(testStatePhone); again.State != StateAwaitingMenuChoice {
+			t.Fatalf("%s: state changed without Save: %+v", name, again)
+		}
+	}
+
+	if _, err := NewStateStore("redis", ""); err == nil {
+		t.Fatal("an unknown driver was accepted")
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers message templates can use
+var templateFuncs = template.FuncMap{
+	"statusLabel": StatusLabel,
+	"inc":         func(i int) int { return i + 1 },
+	"join":        strings.Join,
+}
+
+// MessageTemplates is the catalog of named bot replies. Every subdirectory of the templates
+// directory is a locale (e.g. "id", "en") holding its own *.tmpl files with the same template names.
+type MessageTemplates struct {
+	defaultLocale string
+	locales       map[string]*template.Template
+}
+
+// LoadMessageTemplates parses the *.tmpl files of every locale in dir and checks that each template
+// in samples exists in every locale and renders with its sample data, so a broken wording fails at
+// startup instead of in a chat.
+func LoadMessageTemplates(dir, defaultLocale string, samples map[string]any) (*MessageTemplates, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read message templates in %s: %v", dir, err)
+	}
+
+	t := &MessageTemplates{
+		defaultLocale: defaultLocale,
+		locales:       make(map[string]*template.Template),
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		locale := entry.Name()
+		tmpl, err := template.New(locale).
+			Funcs(templateFuncs).
+			Option("missingkey=error").
+			ParseGlob(filepath.Join(dir, locale, "*.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse message templates of locale %s: %v", locale, err)
+		}
+		t.locales[locale] = tmpl
+	}
+
+	if !t.HasLocale(defaultLocale) {
+		return nil, fmt.Errorf("no message templates for the default locale %q in %s", defaultLocale, dir)
+	}
+	if err := t.Validate(samples); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Validate renders every template in samples with its sample data, in every locale, and reports all failures at once.
+func (t *MessageTemplates) Validate(samples map[string]any) error {
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, locale := range t.Locales() {
+		for _, name := range names {
+			if _, err := t.Render(locale, name, samples[name]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DefaultLocale is the locale used for numbers that never chose one.
+func (t *MessageTemplates) DefaultLocale() string {
+	return t.defaultLocale
+}
+
+// Locales returns the available locales, sorted.
+func (t *MessageTemplates) Locales() []string {
+	locales := make([]string, 0, len(t.locales))
+	for locale := range t.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// HasLocale reports whether there are templates for the locale.
+func (t *MessageTemplates) HasLocale(locale string) bool {
+	_, ok := t.locales[locale]
+	return ok
+}
+
+// Has reports whether a template with the name is defined in the default locale.
+func (t *MessageTemplates) Has(name string) bool {
+	return t.locales[t.defaultLocale].Lookup(name) != nil
+}
+
+// Render executes the named template of the locale with data. Unknown locales fall back to the default locale.
+func (t *MessageTemplates) Render(locale, name string, data any) (string, error) {
+	set, ok := t.locales[locale]
+	if !ok {
+		locale = t.defaultLocale
+		set = t.locales[locale]
+	}
+
+	tmpl := set.Lookup(name)
+	if tmpl == nil {
+		return "", fmt.Errorf("message template %q is not defined for locale %s", name, locale)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("unable to render message template %q of locale %s: %v", name, locale, err)
+	}
+	return b.String(), nil
+}
@@ -1,60 +1,50 @@
 package utils
 
 import (
-	"regexp"
 	"strings"
 )
 
-// ExtractedFormData holds the parsed data from the user's prescription message.
-type ExtractedFormData struct {
-	Doctor        string
-	Patient       string
-	BirthDate     string
-	RegistryNum   string
-	Medication    string
-	PhoneNumber   string
-	PaymentMethod string
-}
+// Message templates of the validation errors, rendered in the sender's locale
+const (
+	ValidationStartRequired = "validation.start_required"
+	ValidationMenuChoice    = "validation.menu_choice"
+	ValidationFormFormat    = "validation.form_format"
+	ValidationConfirmation  = "validation.confirmation"
+	ValidationUnknownState  = "validation.unknown_state"
+)
 
-// The CORRECT pre-compiled regex for validating the multi-line format.
-// We use the (?s) flag to allow '.' to match newlines.
-var formRegex = regexp.MustCompile(`(?is)Nama Dokter:\s*(.*?)\s*Nama Pasien:\s*(.*?)\s*Tanggal Lahir Pasien:\s*(.*?)\s*No Regis:\s*(.*?)\s*Resep Obat:\s*(.*?)\s*Nomor Telpon Pasien:\s*(.*)\s*Pembiayaan:\s*(.*?)`)
+// OverrideKeyword confirms a prescription with a severe interaction, "Y" is not enough for those
+const OverrideKeyword = "OVERRIDE"
 
 // validateMessageForState checks if a message is valid for the given state.
-// It returns: (isValid bool, extractedData interface{}, errorMessage string)
-func ValidateMessageForState(state string, message string) (bool, interface{}, string) {
+// It returns: (isValid bool, extractedData interface{}, errorTemplate string)
+// where errorTemplate names the message template that explains the problem.
+// A form that does not parse is invalid and its extractedData is the exception.ValidationErrors.
+// Defaults fill in what a form leaves out.
+func ValidateMessageForState(state string, message string, defaults FormDefaults) (bool, interface{}, string) {
 	switch state {
 	case StateAwaitingStart:
 		if strings.ToLower(message) == "/start" {
 			return true, nil, ""
 		}
-		return false, nil, "Untuk memulai sesi baru, kirim pesan `/start`."
+		return false, nil, ValidationStartRequired
 
 	case StateAwaitingMenuChoice:
 		if message == "1" || message == "2" || message == "3" {
 			return true, message, ""
 		}
-		return false, nil, "Inputan salah. Mohon reply dengan `1`, `2`, atau `3`."
+		return false, nil, ValidationMenuChoice
 
 	case StateAwaitingFormSubmission:
 		if strings.ToLower(message) == "cancel" {
 			return true, "cancel", ""
 		}
-		// This part will now work correctly with the new regex
-		matches := formRegex.FindStringSubmatch(message)
-		if len(matches) == 8 {
-			data := ExtractedFormData{
-				Doctor:        strings.TrimSpace(matches[1]),
-				Patient:       strings.TrimSpace(matches[2]),
-				BirthDate:     strings.TrimSpace(matches[3]),
-				RegistryNum:   strings.TrimSpace(matches[4]),
-				Medication:    strings.TrimSpace(matches[5]),
-				PhoneNumber:   strings.TrimSpace(matches[6]),
-				PaymentMethod: strings.TrimSpace(matches[7]),
-			}
-			return true, data, ""
+		// The same parser runs again at confirmation, so a form that passes here cannot fail there
+		details, err := ParsePatientForm(message, defaults)
+		if err != nil {
+			return false, err, ValidationFormFormat
 		}
-		return false, nil, "Format yang dikirimkan salah. Mohon ikuti syarat format atau kirim pesan `cancel` untuk kembali ke main menu."
+		return true, details, ""
 
 	case StateAwaitingConfirmation:
 		cleanMsg := strings.ToUpper(message)
@@ -64,9 +54,12 @@ func ValidateMessageForState(state string, message string) (bool, interface{}, s
 		if cleanMsg == "N" || cleanMsg == "NO" {
 			return true, "N", ""
 		}
-		return false, nil, "Respon tidak sesuai. Mohon reply dengan 'Y' untuk komfirmasi atau 'N' untuk edit."
+		if cleanMsg == OverrideKeyword {
+			return true, OverrideKeyword, ""
+		}
+		return false, nil, ValidationConfirmation
 	}
 
 	// Fallback for any unknown state
-	return false, nil, "Terjadi error yang tidak diinginkan. Mohon kirim pesan `/start` untuk memulai kembali."
+	return false, nil, ValidationUnknownState
 }
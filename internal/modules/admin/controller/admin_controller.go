@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/model"
+	"telegram-doctor-recipe-helper-bot/internal/modules/admin/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminController struct {
+	useCase usecase.AdminUseCase
+}
+
+func NewAdminController(useCase usecase.AdminUseCase) *AdminController {
+	return &AdminController{
+		useCase: useCase,
+	}
+}
+
+// List outbound messages that are still waiting for delivery
+func (ctrl *AdminController) ListPendingMessages(c *fiber.Ctx) error {
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Pending messages",
+		Data:    ctrl.useCase.ListPendingMessages(),
+	})
+}
+
+// List outbound messages that failed permanently
+func (ctrl *AdminController) ListDeadLetters(c *fiber.Ctx) error {
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Dead letters",
+		Data:    ctrl.useCase.ListDeadLetters(),
+	})
+}
+
+// Put a dead letter back into the outbox
+func (ctrl *AdminController) ReplayDeadLetter(c *fiber.Ctx) error {
+	msg, err := ctrl.useCase.ReplayDeadLetter(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Dead letter queued for delivery",
+		Data:    msg,
+	})
+}
+
+// List prescriptions that are not in the spreadsheet yet
+func (ctrl *AdminController) ListUnsyncedRows(c *fiber.Ctx) error {
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Unsynced rows",
+		Data:    ctrl.useCase.ListUnsyncedRows(),
+	})
+}
+
+// Retry writing unsynced prescriptions to the spreadsheet now
+func (ctrl *AdminController) SyncSheet(c *fiber.Ctx) error {
+	ctrl.useCase.SyncSheet()
+
+	return c.Status(fiber.StatusAccepted).JSON(model.Response{
+		Code:    202,
+		Message: "Sheet sync started",
+	})
+}
+
+// List prescriptions with filters and pagination
+func (ctrl *AdminController) ListPrescriptions(c *fiber.Ctx) error {
+	var filter usecase.PrescriptionFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return &exception.BadRequestError{Message: "Invalid query: " + err.Error()}
+	}
+
+	page, err := ctrl.useCase.ListPrescriptions(&filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Prescriptions",
+		Data:    page,
+	})
+}
+
+// Inspect one prescription with its status history
+func (ctrl *AdminController) GetPrescription(c *fiber.Ctx) error {
+	prescription, err := ctrl.useCase.GetPrescription(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Prescription",
+		Data:    prescription,
+	})
+}
+
+// List the stock of every tracked drug
+func (ctrl *AdminController) ListStock(c *fiber.Ctx) error {
+	items, err := ctrl.useCase.ListStock()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Stock",
+		Data:    items,
+	})
+}
+
+// Add stock of a formulary drug
+func (ctrl *AdminController) Restock(c *fiber.Ctx) error {
+	var req usecase.RestockRequest
+	if err := c.BodyParser(&req); err != nil {
+		return &exception.BadRequestError{Message: "Invalid request body: " + err.Error()}
+	}
+
+	item, err := ctrl.useCase.Restock(&req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Stock updated",
+		Data:    item,
+	})
+}
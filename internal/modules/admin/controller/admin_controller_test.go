@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/middleware"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"telegram-doctor-recipe-helper-bot/internal/modules/admin/usecase"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testAPIKey = "test-key"
+
+// newPrescriptionsApp serves the prescription endpoints behind the admin key, like the admin router
+func newPrescriptionsApp(t *testing.T, apiKey string) (*fiber.App, *utils.PrescriptionStore) {
+	t.Helper()
+
+	store, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	ctrl := NewAdminController(usecase.NewAdminUseCase(nil, nil, store, nil))
+
+	app := fiber.New(fiber.Config{ErrorHandler: exception.Handler})
+	prescriptions := app.Group("/v1/admin", middleware.AdminAuth(apiKey)).Group("/prescriptions")
+	prescriptions.Get("/", ctrl.ListPrescriptions)
+	prescriptions.Get("/:id", ctrl.GetPrescription)
+	return app, store
+}
+
+// getJSON sends a GET with the headers and decodes the response into data
+func getJSON(t *testing.T, app *fiber.App, target string, headers map[string]string, data any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	if data != nil {
+		if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+			t.Fatalf("decode %s: %v", target, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestPrescriptionsRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		headers    map[string]string
+		authorized bool
+	}{
+		{"X-API-Key", testAPIKey, map[string]string{"X-API-Key": testAPIKey}, true},
+		{"bearer token", testAPIKey, map[string]string{"Authorization": "Bearer " + testAPIKey}, true},
+		{"no key", testAPIKey, nil, false},
+		{"wrong key", testAPIKey, map[string]string{"X-API-Key": "guess"}, false},
+		{"key without Bearer", testAPIKey, map[string]string{"Authorization": "Basic " + testAPIKey}, false},
+		{"admin API disabled", "", map[string]string{"X-API-Key": ""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newPrescriptionsApp(t, tt.apiKey)
+			for _, target := range []string{"/v1/admin/prescriptions", "/v1/admin/prescriptions/RX-1"} {
+				// The unknown RX-1 is a 404 once the key is accepted
+				status := getJSON(t, app, target, tt.headers, nil)
+				if (status != fiber.StatusUnauthorized) != tt.authorized {
+					t.Fatalf("GET %s = %d, want authorized %v", target, status, tt.authorized)
+				}
+			}
+		})
+	}
+}
+
+func TestListPrescriptionsQuery(t *testing.T) {
+	app, store := newPrescriptionsApp(t, testAPIKey)
+	auth := map[string]string{"X-API-Key": testAPIKey}
+
+	day := time.Date(2025, 3, 10, 9, 0, 0, 0, utils.WIB)
+	for queueNumber, payment := range []string{"BPJS", "Umum", "BPJS"} {
+		details := &utils.PatientDetails{PatientName: "Siti Aminah", DoctorName: "Budi", PaymentMethod: payment}
+		if _, err := store.Add(utils.NewPrescriptionRecord(details, "6289999999999", queueNumber+1, day), "6289999999999", "6281111111111"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	var body struct {
+		Data usecase.PrescriptionPage `json:"data"`
+	}
+	status := getJSON(t, app, "/v1/admin/prescriptions?date=2025-03-10&payment_method=bpjs&status=received&page=1&limit=1", auth, &body)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if body.Data.Total != 2 || body.Data.Limit != 1 || len(body.Data.Items) != 1 || body.Data.Items[0].QueueNumber != 3 {
+		t.Fatalf("page = %+v, want the newest of the 2 BPJS prescriptions", body.Data)
+	}
+
+	for _, query := range []string{"?status=lost", "?date=10-03-2025", "?page=one"} {
+		if status := getJSON(t, app, "/v1/admin/prescriptions"+query, auth, nil); status != fiber.StatusBadRequest {
+			t.Errorf("GET %s = %d, want %d", query, status, fiber.StatusBadRequest)
+		}
+	}
+	if status := getJSON(t, app, "/v1/admin/prescriptions/RX-404", auth, nil); status != fiber.StatusNotFound {
+		t.Fatalf("unknown prescription = %d, want %d", status, fiber.StatusNotFound)
+	}
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/app/middleware"
+	"telegram-doctor-recipe-helper-bot/internal/modules/admin/controller"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Route(app *fiber.App, ctrl *controller.AdminController, apiKey string) {
+	admin := app.Group("/v1/admin", middleware.AdminAuth(apiKey))
+
+	outbox := admin.Group("/outbox")
+	outbox.Get("/pending", ctrl.ListPendingMessages)
+	outbox.Get("/dead-letters", ctrl.ListDeadLetters)
+	outbox.Post("/dead-letters/:id/replay", ctrl.ReplayDeadLetter)
+
+	sheets := admin.Group("/sheets")
+	sheets.Get("/unsynced", ctrl.ListUnsyncedRows)
+	sheets.Post("/sync", ctrl.SyncSheet)
+
+	prescriptions := admin.Group("/prescriptions")
+	prescriptions.Get("/", ctrl.ListPrescriptions)
+	prescriptions.Get("/:id", ctrl.GetPrescription)
+
+	inventory := admin.Group("/inventory")
+	inventory.Get("/", ctrl.ListStock)
+	inventory.Post("/restock", ctrl.Restock)
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+type AdminUseCase interface {
+	ListDeadLetters() []utils.OutboxMessage
+	ListPendingMessages() []utils.OutboxMessage
+	ReplayDeadLetter(id string) (*utils.OutboxMessage, error)
+	ListUnsyncedRows() []utils.PendingRow
+	SyncSheet()
+	ListPrescriptions(filter *PrescriptionFilter) (*PrescriptionPage, error)
+	GetPrescription(id string) (*utils.Prescription, error)
+	ListStock() ([]utils.StockItem, error)
+	Restock(req *RestockRequest) (*utils.StockItem, error)
+}
+
+type adminUseCase struct {
+	outbox        *utils.Outbox
+	sheetJournal  *utils.SheetJournal
+	prescriptions *utils.PrescriptionStore
+	inventory     *utils.Inventory // nil when no formulary is configured
+}
+
+func NewAdminUseCase(outbox *utils.Outbox, sheetJournal *utils.SheetJournal, prescriptions *utils.PrescriptionStore, inventory *utils.Inventory) AdminUseCase {
+	return &adminUseCase{
+		outbox:        outbox,
+		sheetJournal:  sheetJournal,
+		prescriptions: prescriptions,
+		inventory:     inventory,
+	}
+}
+
+// ListDeadLetters returns the messages the outbox gave up on
+func (uc *adminUseCase) ListDeadLetters() []utils.OutboxMessage {
+	return uc.outbox.DeadLetters()
+}
+
+// ListPendingMessages returns the messages still waiting for delivery
+func (uc *adminUseCase) ListPendingMessages() []utils.OutboxMessage {
+	return uc.outbox.Pending()
+}
+
+// ReplayDeadLetter puts a dead letter back into the outbox
+func (uc *adminUseCase) ReplayDeadLetter(id string) (*utils.OutboxMessage, error) {
+	return uc.outbox.Replay(id)
+}
+
+// ListUnsyncedRows returns the prescriptions that are not in the spreadsheet yet
+func (uc *adminUseCase) ListUnsyncedRows() []utils.PendingRow {
+	if uc.sheetJournal == nil {
+		return []utils.PendingRow{}
+	}
+	return uc.sheetJournal.Unsynced()
+}
+
+// SyncSheet asks the sheet journal to retry now
+func (uc *adminUseCase) SyncSheet() {
+	if uc.sheetJournal != nil {
+		uc.sheetJournal.RetryNow()
+	}
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// RestockRequest adds stock of a formulary drug, by generic or brand name
+type RestockRequest struct {
+	Name     string `json:"name"`
+	Strength string `json:"strength"`
+	Quantity int    `json:"quantity"`
+}
+
+// ListStock returns the stock of every tracked drug
+func (uc *adminUseCase) ListStock() ([]utils.StockItem, error) {
+	if uc.inventory == nil {
+		return nil, errInventoryDisabled
+	}
+	return uc.inventory.List(), nil
+}
+
+// Restock adds the quantity to the stock of the drug
+func (uc *adminUseCase) Restock(req *RestockRequest) (*utils.StockItem, error) {
+	if uc.inventory == nil {
+		return nil, errInventoryDisabled
+	}
+	if req.Name == "" {
+		return nil, &exception.BadRequestError{Message: "Name is required"}
+	}
+	return uc.inventory.Restock(req.Name, req.Strength, req.Quantity)
+}
+
+var errInventoryDisabled = &exception.BadRequestError{Message: "Stock is not tracked, set FORMULARY_PATH to enable it"}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// PrescriptionFilter holds the query parameters of the prescription list
+type PrescriptionFilter struct {
+	Date          string `query:"date"`      // YYYY-MM-DD (WIB)
+	DateFrom      string `query:"date_from"` // YYYY-MM-DD (WIB), inclusive
+	DateTo        string `query:"date_to"`   // YYYY-MM-DD (WIB), inclusive
+	Doctor        string `query:"doctor"`
+	Status        string `query:"status"`
+	RegistryNum   string `query:"registry_num"`
+	PaymentMethod string `query:"payment_method"`
+	Search        string `query:"q"`
+	Page          int    `query:"page"`
+	Limit         int    `query:"limit"`
+}
+
+// PrescriptionPage is one page of the prescription list
+type PrescriptionPage struct {
+	Items []utils.Prescription `json:"items"`
+	Page  int                  `json:"page"`
+	Limit int                  `json:"limit"`
+	Total int                  `json:"total"`
+}
+
+// ListPrescriptions returns the prescriptions matching the filter, newest first
+func (uc *adminUseCase) ListPrescriptions(filter *PrescriptionFilter) (*PrescriptionPage, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	page, limit := filter.Page, filter.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	prescriptions := uc.prescriptions.List(match)
+
+	// Newest first
+	for i, j := 0, len(prescriptions)-1; i < j; i, j = i+1, j-1 {
+		prescriptions[i], prescriptions[j] = prescriptions[j], prescriptions[i]
+	}
+
+	result := &PrescriptionPage{
+		Items: []utils.Prescription{},
+		Page:  page,
+		Limit: limit,
+		Total: len(prescriptions),
+	}
+
+	start := (page - 1) * limit
+	if start < len(prescriptions) {
+		end := min(start+limit, len(prescriptions))
+		result.Items = prescriptions[start:end]
+	}
+	return result, nil
+}
+
+// GetPrescription returns one prescription with its status history
+func (uc *adminUseCase) GetPrescription(id string) (*utils.Prescription, error) {
+	prescription, ok := uc.prescriptions.Get(id)
+	if !ok {
+		return nil, &exception.NotFoundError{Message: "Prescription not found"}
+	}
+	return prescription, nil
+}
+
+// matcher validates the filter and turns it into a predicate for the store
+func (f *PrescriptionFilter) matcher() (func(*utils.Prescription) bool, error) {
+	if f.Status != "" && !utils.IsValidStatus(f.Status) {
+		return nil, &exception.BadRequestError{Message: fmt.Sprintf("Unknown status: %s", f.Status)}
+	}
+
+	for name, value := range map[string]string{"date": f.Date, "date_from": f.DateFrom, "date_to": f.DateTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseInLocation("2006-01-02", value, utils.WIB); err != nil {
+			return nil, &exception.BadRequestError{Message: fmt.Sprintf("%s must be formatted as YYYY-MM-DD", name)}
+		}
+	}
+
+	doctor := strings.ToLower(f.Doctor)
+	payment := strings.ToLower(f.PaymentMethod)
+	registryNum := strings.TrimPrefix(f.RegistryNum, "'")
+	search := strings.ToLower(f.Search)
+
+	return func(p *utils.Prescription) bool {
+		// Dates compare as strings because they share the YYYY-MM-DD layout
+		day := p.CreatedAt.In(utils.WIB).Format("2006-01-02")
+		switch {
+		case f.Date != "" && day != f.Date,
+			f.DateFrom != "" && day < f.DateFrom,
+			f.DateTo != "" && day > f.DateTo,
+			f.Status != "" && p.Status != f.Status,
+			doctor != "" && !strings.Contains(strings.ToLower(p.Details.DoctorName), doctor),
+			payment != "" && strings.ToLower(p.Details.PaymentMethod) != payment,
+			registryNum != "" && strings.TrimPrefix(p.Details.RegistryNum, "'") != registryNum:
+			return false
+		}
+
+		if search != "" {
+			haystack := strings.ToLower(strings.Join([]string{p.ID, p.Details.PatientName, p.Details.Medication}, " "))
+			return strings.Contains(haystack, search)
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"testing"
+	"time"
+)
+
+const testPharmacy = "6289999999999"
+
+// testPrescription is one row of newTestPrescriptions
+type testPrescription struct {
+	day         string // YYYY-MM-DD
+	queueNumber int
+	doctor      string
+	patient     string
+	registryNum string
+	payment     string
+	medication  string
+	status      string
+}
+
+// newTestPrescriptions stores the prescriptions, each created at 09:00 WIB of its day plus its queue number in minutes
+func newTestPrescriptions(t *testing.T, rows []testPrescription) *utils.PrescriptionStore {
+	t.Helper()
+
+	store, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	for _, row := range rows {
+		day, err := time.ParseInLocation("2006-01-02", row.day, utils.WIB)
+		if err != nil {
+			t.Fatalf("day %s: %v", row.day, err)
+		}
+		createdAt := day.Add(9*time.Hour + time.Duration(row.queueNumber)*time.Minute)
+		details := &utils.PatientDetails{
+			DoctorName:    row.doctor,
+			PatientName:   row.patient,
+			RegistryNum:   row.registryNum,
+			PaymentMethod: row.payment,
+			Medication:    row.medication,
+		}
+		prescription, err := store.Add(utils.NewPrescriptionRecord(details, testPharmacy, row.queueNumber, createdAt), testPharmacy, "6281111111111")
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if row.status != utils.StatusReceived {
+			if _, err := store.UpdateStatus(prescription.ID, row.status, testPharmacy); err != nil {
+				t.Fatalf("UpdateStatus: %v", err)
+			}
+		}
+	}
+	return store
+}
+
+var testPrescriptionRows = []testPrescription{
+	{"2025-03-09", 1, "Budi Santoso", "Siti Aminah", "'012345", "BPJS", "1. Amoxicillin 500 mg", utils.StatusPickedUp},
+	{"2025-03-10", 1, "Budi Santoso", "Joko Widodo", "RM-002", "Umum", "1. Paracetamol 500 mg", utils.StatusReady},
+	{"2025-03-10", 2, "Rina Kartika", "Siti Aminah", "012345", "BPJS", "1. CTM 4 mg", utils.StatusReceived},
+	{"2025-03-11", 1, "Rina Kartika", "Ani Lestari", "RM-003", "bpjs", "1. Ibuprofen 400 mg", utils.StatusPreparing},
+}
+
+// queues returns "day/queue number" of the items in page order
+func queues(page *PrescriptionPage) string {
+	var ids []string
+	for _, item := range page.Items {
+		ids = append(ids, fmt.Sprintf("%s/%d", item.CreatedAt.In(utils.WIB).Format("01-02"), item.QueueNumber))
+	}
+	return strings.Join(ids, " ")
+}
+
+func TestListPrescriptionsFilters(t *testing.T) {
+	uc := &adminUseCase{prescriptions: newTestPrescriptions(t, testPrescriptionRows)}
+
+	tests := []struct {
+		name   string
+		filter PrescriptionFilter
+		want   string // Newest first
+	}{
+		{"no filter", PrescriptionFilter{}, "03-11/1 03-10/2 03-10/1 03-09/1"},
+		{"date", PrescriptionFilter{Date: "2025-03-10"}, "03-10/2 03-10/1"},
+		{"date range", PrescriptionFilter{DateFrom: "2025-03-10", DateTo: "2025-03-11"}, "03-11/1 03-10/2 03-10/1"},
+		{"date to", PrescriptionFilter{DateTo: "2025-03-09"}, "03-09/1"},
+		{"doctor part and case", PrescriptionFilter{Doctor: "kartika"}, "03-11/1 03-10/2"},
+		{"status", PrescriptionFilter{Status: utils.StatusReady}, "03-10/1"},
+		{"registry number with and without quote", PrescriptionFilter{RegistryNum: "'012345"}, "03-10/2 03-09/1"},
+		{"payment method in any case", PrescriptionFilter{PaymentMethod: "BPJS"}, "03-11/1 03-10/2 03-09/1"},
+		{"search medication", PrescriptionFilter{Search: "paracetamol"}, "03-10/1"},
+		{"search patient", PrescriptionFilter{Search: "siti"}, "03-10/2 03-09/1"},
+		{"combined", PrescriptionFilter{Doctor: "Budi", PaymentMethod: "bpjs"}, "03-09/1"},
+		{"nothing matches", PrescriptionFilter{Date: "2025-03-12"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := uc.ListPrescriptions(&tt.filter)
+			if err != nil {
+				t.Fatalf("ListPrescriptions: %v", err)
+			}
+			if got := queues(page); got != tt.want {
+				t.Fatalf("prescriptions = %q, want %q", got, tt.want)
+			}
+			if page.Total != len(page.Items) {
+				t.Fatalf("total = %d, want %d", page.Total, len(page.Items))
+			}
+		})
+	}
+}
+
+func TestListPrescriptionsPagination(t *testing.T) {
+	uc := &adminUseCase{prescriptions: newTestPrescriptions(t, testPrescriptionRows)}
+
+	tests := []struct {
+		name        string
+		page, limit int
+		wantPage    int
+		wantLimit   int
+		want        string
+	}{
+		{"first page", 1, 3, 1, 3, "03-11/1 03-10/2 03-10/1"},
+		{"last page", 2, 3, 2, 3, "03-09/1"},
+		{"past the end", 3, 3, 3, 3, ""},
+		{"defaults", 0, 0, 1, defaultPageLimit, "03-11/1 03-10/2 03-10/1 03-09/1"},
+		{"limit capped", 1, maxPageLimit + 1, 1, maxPageLimit, "03-11/1 03-10/2 03-10/1 03-09/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := uc.ListPrescriptions(&PrescriptionFilter{Page: tt.page, Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("ListPrescriptions: %v", err)
+			}
+			if page.Page != tt.wantPage || page.Limit != tt.wantLimit || page.Total != 4 {
+				t.Fatalf("page %d, limit %d, total %d, want %d, %d, 4", page.Page, page.Limit, page.Total, tt.wantPage, tt.wantLimit)
+			}
+			if got := queues(page); got != tt.want {
+				t.Fatalf("prescriptions = %q, want %q", got, tt.want)
+			}
+			if page.Items == nil {
+				t.Fatal("items is nil, the API would answer null instead of []")
+			}
+		})
+	}
+}
+
+func TestListPrescriptionsRejectsBadFilters(t *testing.T) {
+	uc := &adminUseCase{prescriptions: newTestPrescriptions(t, nil)}
+
+	for _, filter := range []PrescriptionFilter{{Status: "lost"}, {Date: "10-03-2025"}, {DateFrom: "2025-3-1"}, {DateTo: "yesterday"}} {
+		_, err := uc.ListPrescriptions(&filter)
+		var badRequest *exception.BadRequestError
+		if !errors.As(err, &badRequest) {
+			t.Errorf("ListPrescriptions(%+v) error = %v, want a BadRequestError", filter, err)
+		}
+	}
+}
+
+func TestGetPrescription(t *testing.T) {
+	store := newTestPrescriptions(t, testPrescriptionRows[:1])
+	uc := &adminUseCase{prescriptions: store}
+
+	id := store.List(nil)[0].ID
+	prescription, err := uc.GetPrescription(id)
+	if err != nil {
+		t.Fatalf("GetPrescription: %v", err)
+	}
+	if len(prescription.History) != 2 || prescription.History[1].Status != utils.StatusPickedUp {
+		t.Fatalf("history = %+v, want received and picked up", prescription.History)
+	}
+
+	var notFound *exception.NotFoundError
+	if _, err := uc.GetPrescription("RX-404"); !errors.As(err, &notFound) {
+		t.Fatalf("error = %v, want a NotFoundError", err)
+	}
+}
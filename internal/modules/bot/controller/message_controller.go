@@ -1,20 +1,29 @@
 package controller
 
 import (
+	"context"
 	"telegram-doctor-recipe-helper-bot/internal/app/config"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
 	"telegram-doctor-recipe-helper-bot/internal/app/model"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
 	"telegram-doctor-recipe-helper-bot/internal/modules/bot/usecase"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// manualSendTimeout is how long the manual send endpoint waits for the gateway before answering "queued"
+const manualSendTimeout = 10 * time.Second
+
 type BotController struct {
 	useCase usecase.MessageUseCase
+	cfg     *config.Config
 }
 
-func NewBotController(useCase usecase.MessageUseCase) *BotController {
+func NewBotController(useCase usecase.MessageUseCase, cfg *config.Config) *BotController {
 	return &BotController{
 		useCase: useCase,
+		cfg:     cfg,
 	}
 }
 
@@ -22,6 +31,16 @@ func NewBotController(useCase usecase.MessageUseCase) *BotController {
 func (ctrl *BotController) HandleWebhook(c *fiber.Ctx) error {
 	var payload usecase.WebhookMessage
 
+	// Verify the payload was signed by GOWA before trusting any of its fields
+	if ctrl.cfg.WebhookSecret != "" {
+		signature := c.Get(utils.WebhookSignatureHeader)
+		if !utils.VerifyWebhookSignature(ctrl.cfg.WebhookSecret, c.Body(), signature) {
+			return &exception.UnauthorizedError{Message: "Invalid webhook signature"}
+		}
+	} else if !ctrl.cfg.WebhookInsecure {
+		return &exception.UnauthorizedError{Message: "Webhook secret is not configured"}
+	}
+
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(model.Response{
 			Code:    400,
@@ -29,21 +48,20 @@ func (ctrl *BotController) HandleWebhook(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if from allowed number BEFORE processing
-	if payload.SenderID != config.LoadConfig().AllowedNumber && payload.SenderID != config.LoadConfig().NewDoctor {
-		return c.JSON(model.Response{
-			Code:    200,
-			Message: "Message ignored - unauthorized sender",
-		})
-	}
-
-	// Process the incoming message through use case
-	if err := ctrl.useCase.ProcessWebhookMessage(&payload); err != nil {
+	// Process the incoming message through use case, it ignores senders the bot does not talk to
+	accepted, err := ctrl.useCase.ProcessWebhookMessage(&payload)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(model.Response{
 			Code:    500,
 			Message: err.Error(),
 		})
 	}
+	if !accepted {
+		return c.JSON(model.Response{
+			Code:    200,
+			Message: "Message ignored - unauthorized sender",
+		})
+	}
 
 	// Return success response to WhatsApp server
 	return c.JSON(model.Response{
@@ -52,7 +70,48 @@ func (ctrl *BotController) HandleWebhook(c *fiber.Ctx) error {
 	})
 }
 
+// SendMessageRequest is the body of the manual send endpoint.
+// Either Message is sent as is, or Template is rendered with Data.
+type SendMessageRequest struct {
+	Phone    string         `json:"phone"`
+	Message  string         `json:"message"`
+	Template string         `json:"template"`
+	Data     map[string]any `json:"data"`
+}
+
 // Manual send message
+func (ctrl *BotController) SendMessage(c *fiber.Ctx) error {
+	var request SendMessageRequest
+	if err := c.BodyParser(&request); err != nil {
+		return &exception.BadRequestError{Message: "Invalid request body: " + err.Error()}
+	}
+
+	if request.Template != "" {
+		message, err := ctrl.useCase.RenderMessage(request.Phone, request.Template, request.Data)
+		if err != nil {
+			return err
+		}
+		request.Message = message
+	}
+
+	// Wait a little for the delivery so the gateway's message ID can be returned
+	ctx, cancel := context.WithTimeout(c.Context(), manualSendTimeout)
+	defer cancel()
+
+	delivery, err := ctrl.useCase.SendMessage(ctx, request.Phone, request.Message)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == utils.DeliveryFailed {
+		return &exception.InternalServerError{Message: "Failed to send message: " + delivery.Error}
+	}
+
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Message " + delivery.Status,
+		Data:    delivery,
+	})
+}
 
 // Health check
 func (ctrl *BotController) HealthCheck(c *fiber.Ctx) error {
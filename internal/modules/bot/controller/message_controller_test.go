@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"telegram-doctor-recipe-helper-bot/internal/app/config"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/model"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"telegram-doctor-recipe-helper-bot/internal/modules/bot/usecase"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testWebhookSecret = "test-secret"
+
+// webhookPayload is a text message as GOWA sends it
+var webhookPayload = []byte(`{"chat_id":"6281111111111@s.whatsapp.net","from":"6281111111111@s.whatsapp.net","message":{"text":"/start","id":"3EB0C1A2B3C4D5E6F708"},"pushname":"Budi","sender_id":"6281111111111","timestamp":"2025-01-02T09:30:00Z"}`)
+
+// fakeMessageUseCase records the webhooks and messages that reach the use case
+type fakeMessageUseCase struct {
+	processed []*usecase.WebhookMessage
+	sent      []sentMessage
+	// ignore makes every sender one the bot does not talk to
+	ignore bool
+	// delivery is what SendMessage reports, a sent message with a WhatsApp ID when nil
+	delivery *utils.Delivery
+}
+
+// sentMessage is a call of fakeMessageUseCase.SendMessage
+type sentMessage struct {
+	phoneNumber string
+	message     string
+}
+
+func (f *fakeMessageUseCase) ProcessWebhookMessage(payload *usecase.WebhookMessage) (bool, error) {
+	f.processed = append(f.processed, payload)
+	return !f.ignore, nil
+}
+
+func (f *fakeMessageUseCase) SendMessage(ctx context.Context, phoneNumber, message string) (*utils.Delivery, error) {
+	if phoneNumber == "" {
+		return nil, &exception.BadRequestError{Message: "Phone number is required"}
+	}
+	f.sent = append(f.sent, sentMessage{phoneNumber: phoneNumber, message: message})
+	if f.delivery != nil {
+		return f.delivery, nil
+	}
+	return &utils.Delivery{OutboxID: "outbox-1", MessageID: "3EB0SENT", Status: utils.DeliverySent}, nil
+}
+
+func (f *fakeMessageUseCase) ReportFailedOrder(outboxID string) {}
+
+// RenderMessage renders "<name> for <phone>: <data>", only the template "patient.ready" exists
+func (f *fakeMessageUseCase) RenderMessage(phoneNumber, name string, data map[string]any) (string, error) {
+	if name != "patient.ready" {
+		return "", &exception.BadRequestError{Message: "Unknown message template: " + name}
+	}
+	return fmt.Sprintf("%s for %s: %v", name, phoneNumber, data["PatientName"]), nil
+}
+
+func newWebhookApp(cfg *config.Config) (*fiber.App, *fakeMessageUseCase) {
+	useCase := &fakeMessageUseCase{}
+	ctrl := NewBotController(useCase, cfg)
+
+	app := fiber.New(fiber.Config{ErrorHandler: exception.Handler})
+	app.Post("/webhook", ctrl.HandleWebhook)
+	return app, useCase
+}
+
+func postWebhook(t *testing.T, app *fiber.App, body []byte, signature string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(utils.WebhookSignatureHeader, signature)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func TestHandleWebhookSignature(t *testing.T) {
+	tampered := bytes.Replace(webhookPayload, []byte("/start"), []byte("/stop!"), 1)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		status    int
+	}{
+		{name: "valid signature", body: webhookPayload, signature: utils.SignWebhookPayload(testWebhookSecret, webhookPayload), status: fiber.StatusOK},
+		{name: "signed with another secret", body: webhookPayload, signature: utils.SignWebhookPayload("other-secret", webhookPayload), status: fiber.StatusUnauthorized},
+		{name: "malformed signature", body: webhookPayload, signature: "sha256=not-hex", status: fiber.StatusUnauthorized},
+		{name: "missing header", body: webhookPayload, status: fiber.StatusUnauthorized},
+		{name: "body changed after signing", body: tampered, signature: utils.SignWebhookPayload(testWebhookSecret, webhookPayload), status: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, useCase := newWebhookApp(&config.Config{WebhookSecret: testWebhookSecret})
+
+			if status := postWebhook(t, app, tt.body, tt.signature); status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+
+			wantProcessed := 0
+			if tt.status == fiber.StatusOK {
+				wantProcessed = 1
+			}
+			if len(useCase.processed) != wantProcessed {
+				t.Fatalf("processed %d webhooks, want %d", len(useCase.processed), wantProcessed)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookWithoutSecret(t *testing.T) {
+	app, useCase := newWebhookApp(&config.Config{})
+	if status := postWebhook(t, app, webhookPayload, ""); status != fiber.StatusUnauthorized {
+		t.Fatalf("status without secret = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if len(useCase.processed) != 0 {
+		t.Fatalf("processed %d webhooks without secret, want 0", len(useCase.processed))
+	}
+
+	app, useCase = newWebhookApp(&config.Config{WebhookInsecure: true})
+	if status := postWebhook(t, app, webhookPayload, ""); status != fiber.StatusOK {
+		t.Fatalf("status with WEBHOOK_INSECURE = %d, want %d", status, fiber.StatusOK)
+	}
+	if len(useCase.processed) != 1 || useCase.processed[0].Message.ID != "3EB0C1A2B3C4D5E6F708" {
+		t.Fatalf("processed %+v, want the webhook", useCase.processed)
+	}
+}
+
+func TestHandleWebhookIgnoresUnknownSender(t *testing.T) {
+	app, useCase := newWebhookApp(&config.Config{WebhookInsecure: true})
+	useCase.ignore = true
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(webhookPayload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// GOWA must not retry a message the bot chose to ignore
+	var response model.Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || response.Message != "Message ignored - unauthorized sender" {
+		t.Fatalf("status = %d, response = %+v", resp.StatusCode, response)
+	}
+	if len(useCase.processed) != 1 {
+		t.Fatalf("processed %d webhooks, want the one the use case ignored", len(useCase.processed))
+	}
+}
+
+func newSendApp() (*fiber.App, *fakeMessageUseCase) {
+	useCase := &fakeMessageUseCase{}
+	ctrl := NewBotController(useCase, &config.Config{})
+
+	app := fiber.New(fiber.Config{ErrorHandler: exception.Handler})
+	app.Post("/send", ctrl.SendMessage)
+	return app, useCase
+}
+
+// postSend posts body to the manual send endpoint and decodes the response
+func postSend(t *testing.T, app *fiber.App, body string) (int, model.Response) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/send", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("send request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response model.Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp.StatusCode, response
+}
+
+func TestSendMessage(t *testing.T) {
+	app, useCase := newSendApp()
+
+	status, response := postSend(t, app, `{"phone":"0812-3456-7890","message":"Halo"}`)
+	if status != fiber.StatusOK || response.Message != "Message sent" {
+		t.Fatalf("status = %d, response = %+v", status, response)
+	}
+	// The gateway's message ID is returned to the caller
+	if data, _ := response.Data.(map[string]any); data["message_id"] != "3EB0SENT" || data["status"] != utils.DeliverySent {
+		t.Fatalf("data = %+v, want the delivery with its message ID", response.Data)
+	}
+	if len(useCase.sent) != 1 || useCase.sent[0] != (sentMessage{"0812-3456-7890", "Halo"}) {
+		t.Fatalf("sent = %+v", useCase.sent)
+	}
+}
+
+func TestSendMessageTemplate(t *testing.T) {
+	app, useCase := newSendApp()
+
+	status, _ := postSend(t, app, `{"phone":"6281234567890","template":"patient.ready","message":"ignored","data":{"PatientName":"Siti"}}`)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if len(useCase.sent) != 1 || useCase.sent[0].message != "patient.ready for 6281234567890: Siti" {
+		t.Fatalf("sent = %+v, want the rendered template instead of the message", useCase.sent)
+	}
+
+	status, response := postSend(t, app, `{"phone":"6281234567890","template":"patient.unknown"}`)
+	if status != fiber.StatusBadRequest || response.Data != "Unknown message template: patient.unknown" {
+		t.Fatalf("unknown template: status = %d, response = %+v", status, response)
+	}
+	if len(useCase.sent) != 1 {
+		t.Fatal("a message was sent for an unknown template")
+	}
+}
+
+func TestSendMessageDeliveryStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery utils.Delivery
+		status   int
+		message  string
+	}{
+		{"queued", utils.Delivery{OutboxID: "outbox-1", Status: utils.DeliveryQueued}, fiber.StatusOK, "Message queued"},
+		{"failed", utils.Delivery{OutboxID: "outbox-1", Status: utils.DeliveryFailed, Error: "number not on WhatsApp"}, fiber.StatusInternalServerError, "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, useCase := newSendApp()
+			useCase.delivery = &tt.delivery
+
+			status, response := postSend(t, app, `{"phone":"6281234567890","message":"Halo"}`)
+			if status != tt.status || response.Message != tt.message {
+				t.Fatalf("status = %d, response = %+v, want %d %q", status, response, tt.status, tt.message)
+			}
+		})
+	}
+}
+
+func TestSendMessageBadRequest(t *testing.T) {
+	app, useCase := newSendApp()
+
+	for _, body := range []string{`{"phone":`, `{"message":"Halo"}`} {
+		if status, _ := postSend(t, app, body); status != fiber.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, status, fiber.StatusBadRequest)
+		}
+	}
+	if len(useCase.sent) != 0 {
+		t.Fatalf("sent = %+v, want nothing", useCase.sent)
+	}
+}
@@ -1,14 +1,16 @@
 package router
 
 import (
+	"telegram-doctor-recipe-helper-bot/internal/app/middleware"
 	"telegram-doctor-recipe-helper-bot/internal/modules/bot/controller"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func Route(app *fiber.App, ctrl *controller.BotController) {
+func Route(app *fiber.App, ctrl *controller.BotController, apiKey string) {
 	message := app.Group("/v1/messages")
 
 	message.Post("/webhook", ctrl.HandleWebhook)
+	message.Post("/send", middleware.AdminAuth(apiKey), ctrl.SendMessage)
 	message.Get("/health", ctrl.HealthCheck)
 }
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// handleAdminCommand runs the chat commands admins use to manage doctors
+func (uc *messageUseCase) handleAdminCommand(phoneNumber, messageText string) error {
+	fields := strings.Fields(messageText)
+	if len(fields) < 2 || strings.ToLower(fields[0]) != "/dokter" {
+		return uc.reply(phoneNumber, tmplAdminHelp, nil)
+	}
+
+	switch strings.ToLower(fields[1]) {
+	case "daftar", "list":
+		return uc.reply(phoneNumber, tmplAdminDoctorList, doctorListData{Doctors: uc.contacts.List(utils.RoleDoctor)})
+
+	case "tambah", "add":
+		if len(fields) < 4 {
+			return uc.reply(phoneNumber, tmplAdminAddUsage, nil)
+		}
+
+		doctor, err := uc.contacts.Upsert(utils.Contact{
+			PhoneNumber: fields[2],
+			Name:        strings.Join(fields[3:], " "),
+			Role:        utils.RoleDoctor,
+			Active:      true,
+		})
+		if err != nil {
+			return uc.reply(phoneNumber, tmplAdminAddFailed, errorData{Error: err.Error()})
+		}
+		return uc.reply(phoneNumber, tmplAdminDoctorAdded, contactData{Contact: *doctor})
+
+	case "hapus", "remove":
+		if len(fields) != 3 {
+			return uc.reply(phoneNumber, tmplAdminRemoveUsage, nil)
+		}
+
+		if !uc.contacts.HasRole(fields[2], utils.RoleDoctor) {
+			return uc.reply(phoneNumber, tmplAdminNotDoctor, nil)
+		}
+		doctor, err := uc.contacts.Deactivate(fields[2])
+		if err != nil {
+			return uc.reply(phoneNumber, tmplAdminRemoveFailed, errorData{Error: err.Error()})
+		}
+		// Drop whatever the doctor was in the middle of
+		uc.stateStore.Reset(doctor.PhoneNumber)
+		return uc.reply(phoneNumber, tmplAdminDoctorRemoved, contactData{Contact: *doctor})
+	}
+
+	return uc.reply(phoneNumber, tmplAdminHelp, nil)
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// isAllergyCommand reports whether the message is the `/alergi` command
+func isAllergyCommand(messageText string) bool {
+	fields := strings.Fields(messageText)
+	return len(fields) > 0 && (strings.ToLower(fields[0]) == "/alergi" || strings.ToLower(fields[0]) == "/allergy")
+}
+
+// handleAllergyCommand shows and changes the allergies of a patient:
+// `/alergi <no regis>` lists them, `/alergi <no regis> <alergi>` adds one
+// and `/alergi <no regis> hapus <alergi>` removes one.
+// It does not touch the doctor's conversation state, so it works in the middle of a form.
+func (uc *messageUseCase) handleAllergyCommand(phoneNumber, messageText string) error {
+	fields := strings.Fields(messageText)
+	if len(fields) < 2 {
+		return uc.reply(phoneNumber, tmplAllergyUsage, nil)
+	}
+	registryNum := fields[1]
+
+	if len(fields) == 2 {
+		return uc.reply(phoneNumber, tmplAllergyList, allergyData{
+			RegistryNum: utils.NormalizeRegistryNum(registryNum),
+			Allergies:   uc.patients.Allergies(registryNum),
+		})
+	}
+
+	var patient *utils.Patient
+	var err error
+	if action := strings.ToLower(fields[2]); action == "hapus" || action == "remove" {
+		// "hapus" alone names no allergy, it is not an allergy called "hapus"
+		if len(fields) == 3 {
+			return uc.reply(phoneNumber, tmplAllergyUsage, nil)
+		}
+		patient, err = uc.patients.RemoveAllergy(registryNum, strings.Join(fields[3:], " "))
+	} else {
+		patient, err = uc.patients.AddAllergy(registryNum, strings.Join(fields[2:], " "), phoneNumber)
+	}
+	if err != nil {
+		return uc.reply(phoneNumber, tmplAllergyFailed, errorData{Error: err.Error()})
+	}
+	return uc.reply(phoneNumber, tmplAllergyList, allergyData{RegistryNum: patient.RegistryNum, Allergies: patient.Allergies})
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"strconv"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// isRestockCommand reports whether the message is the `/restock` command
+func isRestockCommand(messageText string) bool {
+	fields := strings.Fields(messageText)
+	return len(fields) > 0 && strings.ToLower(fields[0]) == "/restock"
+}
+
+// handleRestockCommand adds stock from a chat message, e.g. `/restock Amoxicillin 500mg 100`
+func (uc *messageUseCase) handleRestockCommand(phoneNumber, messageText string) error {
+	if uc.inventory == nil {
+		return uc.reply(phoneNumber, tmplInventoryDisabled, nil)
+	}
+
+	fields := strings.Fields(messageText)
+	if len(fields) < 3 {
+		return uc.reply(phoneNumber, tmplInventoryRestockUsage, nil)
+	}
+	quantity, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return uc.reply(phoneNumber, tmplInventoryRestockUsage, nil)
+	}
+
+	// The drug is written like a medication line, so "Amoxicillin 500mg" gives name and strength
+	drug, err := utils.ParseMedicationLine(strings.Join(fields[1:len(fields)-1], " "))
+	if err != nil {
+		return uc.reply(phoneNumber, tmplInventoryRestockUsage, nil)
+	}
+
+	item, err := uc.inventory.Restock(drug.Name, drug.Strength, quantity)
+	if err != nil {
+		return uc.reply(phoneNumber, tmplInventoryRestockFailed, errorData{Error: err.Error()})
+	}
+	return uc.reply(phoneNumber, tmplInventoryRestocked, stockData{Item: *item, Added: quantity})
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import "strings"
+
+// isLangCommand reports whether the message is the `/lang` command
+func isLangCommand(messageText string) bool {
+	fields := strings.Fields(messageText)
+	return len(fields) > 0 && strings.ToLower(fields[0]) == "/lang"
+}
+
+// handleLangCommand shows or changes the language the bot uses with the sender
+func (uc *messageUseCase) handleLangCommand(phoneNumber, messageText string) error {
+	fields := strings.Fields(strings.ToLower(messageText))
+	data := localeData{Locale: uc.localeOf(phoneNumber), Locales: uc.templates.Locales()}
+
+	if len(fields) != 2 {
+		return uc.reply(phoneNumber, tmplLangUsage, data)
+	}
+
+	data.Locale = fields[1]
+	if !uc.templates.HasLocale(data.Locale) {
+		return uc.reply(phoneNumber, tmplLangUnknown, data)
+	}
+	if err := uc.locales.Set(phoneNumber, data.Locale); err != nil {
+		return err
+	}
+	return uc.reply(phoneNumber, tmplLangChanged, data)
+}
+
+// localeOf returns the locale the number chose, or the default locale
+func (uc *messageUseCase) localeOf(phoneNumber string) string {
+	if locale := uc.locales.Get(phoneNumber); locale != "" {
+		return locale
+	}
+	return uc.templates.DefaultLocale()
+}
@@ -1,12 +1,9 @@
 package usecase
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"sync"
+	"log"
 
 	// "regexp"
 	"strings"
@@ -15,16 +12,32 @@ import (
 
 	// "telegram-doctor-recipe-helper-bot/internal/app/model"
 	"telegram-doctor-recipe-helper-bot/internal/app/utils"
-	"time"
 )
 
 type MessageUseCase interface {
-	ProcessWebhookMessage(payload *WebhookMessage) error
-	SendMessage(phoneNumber, message string) error
+	// ProcessWebhookMessage handles the message, accepted is false when the bot does not talk to the sender at all
+	ProcessWebhookMessage(payload *WebhookMessage) (accepted bool, err error)
+	SendMessage(ctx context.Context, phoneNumber, message string) (*utils.Delivery, error)
+	RenderMessage(phoneNumber, name string, data map[string]any) (string, error)
+	ReportFailedOrder(outboxID string)
 }
 
 type messageUseCase struct {
-	sheetService *utils.SheetService
+	cfg            *config.Config
+	sinks          *utils.MultiSink
+	stateStore     utils.StateStore
+	queueCounter   utils.QueueCounter
+	seenMessages   *utils.SeenSet
+	gateway        utils.DeliveryGateway
+	contacts       *utils.ContactRegistry
+	prescriptions  *utils.PrescriptionStore
+	patientLimiter *utils.RateLimiter
+	templates      *utils.MessageTemplates
+	locales        *utils.LocaleStore
+	formulary      *utils.Formulary        // nil when no formulary is configured
+	inventory      *utils.Inventory        // nil when no formulary is configured
+	interactions   *utils.InteractionTable // nil when no interaction table is configured
+	patients       *utils.PatientRegistry
 }
 
 // --- Define the conversation states as constants for safety ---
@@ -35,9 +48,23 @@ const (
 	StateAwaitingConfirmation   = "AWAITING_CONFIRMATION"
 )
 
-func NewMessageUseCase(sheetService *utils.SheetService) MessageUseCase {
+func NewMessageUseCase(cfg *config.Config, sinks *utils.MultiSink, stateStore utils.StateStore, queueCounter utils.QueueCounter, seenMessages *utils.SeenSet, gateway utils.DeliveryGateway, contacts *utils.ContactRegistry, prescriptions *utils.PrescriptionStore, patientLimiter *utils.RateLimiter, templates *utils.MessageTemplates, locales *utils.LocaleStore, formulary *utils.Formulary, inventory *utils.Inventory, interactions *utils.InteractionTable, patients *utils.PatientRegistry) MessageUseCase {
 	return &messageUseCase{
-		sheetService: sheetService,
+		cfg:            cfg,
+		sinks:          sinks,
+		stateStore:     stateStore,
+		queueCounter:   queueCounter,
+		seenMessages:   seenMessages,
+		gateway:        gateway,
+		contacts:       contacts,
+		prescriptions:  prescriptions,
+		patientLimiter: patientLimiter,
+		templates:      templates,
+		locales:        locales,
+		formulary:      formulary,
+		inventory:      inventory,
+		interactions:   interactions,
+		patients:       patients,
 	}
 }
 
@@ -59,190 +86,337 @@ type MessageContent struct {
 	QuotedMessage string `json:"quoted_message"`
 }
 
-var Queue = 1
-var DateNow = time.Now().Format("2006-01-02")
-var queueMutex = &sync.Mutex{}
-
-// ProcessWebhookMessage handles incoming webhook messages using a state machine
-func (uc *messageUseCase) ProcessWebhookMessage(webhookData *WebhookMessage) error {
+// ProcessWebhookMessage handles incoming webhook messages using a state machine.
+// The sender's role is looked up once here and passed on to the handlers.
+func (uc *messageUseCase) ProcessWebhookMessage(webhookData *WebhookMessage) (bool, error) {
 	phoneNumber := webhookData.SenderID
 	messageText := webhookData.Message.Text
 
-	// Skip empty or unauthorized messages (assuming initial check is still elsewhere)
+	role := uc.senderRole(phoneNumber)
+	if role == "" {
+		return false, nil
+	}
+	// Skip empty messages
 	if strings.TrimSpace(messageText) == "" {
-		return nil
+		return true, nil
+	}
+
+	// GOWA retries webhooks, skip messages that were already handled. The message is marked
+	// before handling so a retry that arrives meanwhile is skipped, and unmarked when handling
+	// fails so the retry after the 500 is handled again.
+	messageID := webhookData.Message.ID
+	if messageID != "" {
+		firstTime, err := uc.seenMessages.Add(messageID)
+		if err != nil {
+			log.Printf("Unable to persist processed message %s: %v", messageID, err)
+		}
+		if !firstTime {
+			log.Printf("Skipping duplicate message %s from %s", messageID, phoneNumber)
+			return true, nil
+		}
+	}
+
+	err := uc.handleMessage(role, phoneNumber, webhookData)
+	if err != nil && messageID != "" {
+		if forgetErr := uc.seenMessages.Forget(messageID); forgetErr != nil {
+			log.Printf("Unable to unmark failed message %s: %v", messageID, forgetErr)
+		}
+	}
+	return true, err
+}
+
+// handleMessage routes a new message to the handler of the sender's role
+func (uc *messageUseCase) handleMessage(role, phoneNumber string, webhookData *WebhookMessage) error {
+	messageText := webhookData.Message.Text
+
+	// Contacts can switch language at any point, patients go through their rate limit first
+	if role != rolePatient && isLangCommand(messageText) {
+		return uc.handleLangCommand(phoneNumber, messageText)
+	}
+	if (role == utils.RoleAdmin || role == utils.RolePharmacist) && isRestockCommand(messageText) {
+		return uc.handleRestockCommand(phoneNumber, messageText)
+	}
+	if role == utils.RoleDoctor && isAllergyCommand(messageText) {
+		return uc.handleAllergyCommand(phoneNumber, messageText)
 	}
-	if phoneNumber != config.LoadConfig().AllowedNumber && phoneNumber != config.LoadConfig().NewDoctor {
-		return nil
+
+	switch role {
+	case utils.RoleAdmin:
+		return uc.handleAdminCommand(phoneNumber, messageText)
+	case utils.RoleDoctor:
+		return uc.handleDoctorMessage(phoneNumber, messageText)
+	case utils.RolePharmacist:
+		return uc.handlePharmacistMessage(phoneNumber, &webhookData.Message)
+	case rolePatient:
+		return uc.handlePatientMessage(phoneNumber, messageText)
+	}
+
+	return nil
+}
+
+// senderRole returns the role of an active contact, rolePatient for patients with a
+// prescription today, or an empty string for everybody else
+func (uc *messageUseCase) senderRole(phoneNumber string) string {
+	if contact, ok := uc.contacts.Get(phoneNumber); ok {
+		if contact.Active {
+			return contact.Role
+		}
+		return ""
+	}
+
+	if len(uc.todayPrescriptionsOf(phoneNumber)) > 0 {
+		return rolePatient
 	}
+	return ""
+}
 
+// handleDoctorMessage walks a doctor through the prescription form
+func (uc *messageUseCase) handleDoctorMessage(phoneNumber, messageText string) error {
 	// 1. Get the user's current state
-	currentUserState := utils.GetOrCreateUserState(phoneNumber)
+	currentUserState, err := uc.stateStore.Get(phoneNumber)
+	if err != nil {
+		return err
+	}
 
 	// 2. Validate the message against the current state
-	isValid, data, errorMessage := utils.ValidateMessageForState(currentUserState.State, messageText)
+	isValid, data, errorTemplate := utils.ValidateMessageForState(currentUserState.State, messageText, uc.formDefaults(phoneNumber))
 
 	// 3. Act on the validation result
 	if !isValid {
-		// If not valid, just send the specific error message and do nothing else
-		return uc.SendMessage(phoneNumber, errorMessage)
+		// If not valid, just send the specific error message and do nothing else.
+		// A form that does not parse comes with the fields that are wrong.
+		fieldErrors, _ := data.(exception.ValidationErrors)
+		return uc.reply(phoneNumber, errorTemplate, formErrorsData{Errors: fieldErrors})
 	}
 
 	// --- If VALID, process the request based on the current state ---
 	switch currentUserState.State {
 	case StateAwaitingStart:
 		// User sent /start
-		welcomeMessage := "halo, ini adalah bot penghubung antara dokter dan apoteker.\n[1] Buat Resep\n[2] Membuka Link Spreadsheet\n[3] Cancel\n\nJawab dengan angka saja!"
-		uc.SendMessage(phoneNumber, welcomeMessage)
+		uc.reply(phoneNumber, tmplDoctorWelcome, nil)
 		currentUserState.State = StateAwaitingMenuChoice // <-- State Transition
+		return uc.stateStore.Save(phoneNumber, currentUserState)
 
 	case StateAwaitingMenuChoice:
 		choice := data.(string)
 		if choice == "1" {
-			formFormat := "Mohon kirim data pasien dengan detail format berikut:\nNama Dokter: \nNama Pasien: \nTanggal Lahir Pasien: \nNo Regis: \nResep Obat: \nNomor Telpon Pasien: \nPembiayaan: "
-			uc.SendMessage(phoneNumber, formFormat)
+			uc.reply(phoneNumber, tmplDoctorForm, nil)
 			currentUserState.State = StateAwaitingFormSubmission // <-- State Transition
+			return uc.stateStore.Save(phoneNumber, currentUserState)
 		} else if choice == "2" {
-			message := fmt.Sprintf("Berikut adalah link spreadsheet: %s", config.LoadConfig().SheetLink)
-			uc.SendMessage(phoneNumber, message)
-			uc.SendMessage(phoneNumber, "Sesi selesai.")
+			uc.reply(phoneNumber, tmplDoctorSheetLink, sheetLinkData{SheetLink: uc.cfg.SheetLink})
+			uc.reply(phoneNumber, tmplDoctorSessionDone, nil)
 			// uc.CloseChat(phoneNumber) // Assuming you have a close function
-			utils.ResetUserState(phoneNumber) // <-- Reset State
+			return uc.stateStore.Reset(phoneNumber) // <-- Reset State
 		} else if choice == "3" {
-			uc.SendMessage(phoneNumber, "Sesi dibatalkan. Untuk memulai kembali, kirim `/start`.")
+			uc.reply(phoneNumber, tmplDoctorCancelled, nil)
 			// uc.CloseChat(phoneNumber) // Assuming you have a close function
-			utils.ResetUserState(phoneNumber) // <-- Reset State
+			return uc.stateStore.Reset(phoneNumber) // <-- Reset State
 		}
 
 	case StateAwaitingFormSubmission:
 		if cmd, ok := data.(string); ok && cmd == "cancel" {
-			welcomeMessage := "[1] Buat Resep\n[2] Membuka Link Spreadsheet\n[3] Cancel\n\nJawab dengan angka saja!"
-			uc.SendMessage(phoneNumber, "Permintaan dibatalkan. Kembali ke halaman utama.\n\n"+welcomeMessage)
+			uc.reply(phoneNumber, tmplDoctorBackToMenu, nil)
 			currentUserState.State = StateAwaitingMenuChoice // <-- State Transition
-			return nil
+			return uc.stateStore.Save(phoneNumber, currentUserState)
 		}
-		// Store the original message text for confirmation
+		details := data.(*utils.PatientDetails)
+
+		// Unknown drugs go back to the doctor with the closest names before anything is confirmed
+		if uc.formulary != nil {
+			if fieldErrors := uc.formulary.Check(details.Medications); len(fieldErrors) > 0 {
+				return uc.reply(phoneNumber, utils.ValidationFormFormat, formErrorsData{Errors: fieldErrors})
+			}
+		}
+
+		// Store the original message text for confirmation, with what the doctor should look at first
+		confirm := uc.confirmData(messageText, details)
 		currentUserState.PendingMessage = messageText
-		confirmationPrompt := fmt.Sprintf("Mohon konfirmasi permintaan anda:\n\n%s\n\nApakah sudah benar? (Y/N)", messageText)
-		uc.SendMessage(phoneNumber, confirmationPrompt)
+		uc.reply(phoneNumber, tmplDoctorConfirm, confirm)
 		currentUserState.State = StateAwaitingConfirmation // <-- State Transition
+		return uc.stateStore.Save(phoneNumber, currentUserState)
 
 	case StateAwaitingConfirmation:
 		decision := data.(string)
-		if decision == "Y" {
-			patientDetails, err := utils.ParsePatientDetails(currentUserState.PendingMessage)
+		if decision == "Y" || decision == utils.OverrideKeyword {
+			patientDetails, err := utils.ParsePatientForm(currentUserState.PendingMessage, uc.formDefaults(phoneNumber))
 			if err != nil {
-				uc.SendMessage(phoneNumber, "Error: Data yang dikirim terdapat kesalahan format. Mohon untuk mencoba kembali.")
-
-				formFormat := "Mohon kirim data pasien dengan detail format berikut:\nNama Dokter: \nNama Pasien: \nTanggal Lahir Pasien: \nNo Regis: \nResep Obat: \nNomor Telpon Pasien: \nPembiayaan: "
-				uc.SendMessage(phoneNumber, formFormat)
+				uc.reply(phoneNumber, tmplDoctorFormError, nil)
+				uc.reply(phoneNumber, tmplDoctorForm, nil)
 				currentUserState.State = StateAwaitingFormSubmission
+				currentUserState.PendingMessage = ""
+				if saveErr := uc.stateStore.Save(phoneNumber, currentUserState); saveErr != nil {
+					return saveErr
+				}
 				return err
 			}
 
-			// --- START NEW QUEUE LOGIC ---
-
-			// 1. Lock the mutex to prevent other requests from
-			//    reading/writing the queue at the same time.
-			queueMutex.Lock()
-
-			// 2. Check if it's a new day
-			today := time.Now().Format("2006-01-02")
-			if DateNow != today {
-				Queue = 1       // Reset queue to 1
-				DateNow = today // Update the date to today
+			// A severe interaction or a known allergy is only sent when the doctor overrides it explicitly
+			confirm := uc.confirmData(currentUserState.PendingMessage, patientDetails)
+			if confirm.OverrideRequired {
+				if decision != utils.OverrideKeyword {
+					return uc.reply(phoneNumber, tmplDoctorOverrideRequired, confirm)
+				}
+				log.Printf("Doctor %s overrode %d allergy and %d interaction warnings for patient %s", phoneNumber, len(confirm.AllergyWarnings), len(confirm.MedicationWarnings), patientDetails.RegistryNum)
 			}
 
-			// 3. Get the queue number for *this* request
-			currentQueueNumber := Queue
-
-			// 4. Increment the global Queue for the *next* request
-			Queue += 1
-
-			// 5. Unlock the mutex so other requests can continue
-			queueMutex.Unlock()
-
-			// --- END NEW QUEUE LOGIC ---
-
-			err = uc.sheetService.AddPrescriptionRow(patientDetails, currentQueueNumber)
-			if err != nil {
-				// If it fails, tell the doctor but maybe still send to pharmacy
-				uc.SendMessage(phoneNumber, "Note: Gagal untuk menyimpan ke spreadsheet, tetapi tetap akan dikirim ke apoteker.")
-				// You can decide if you want to stop here or continue
+			// A retry after a failed send reuses the queue number and the rows of the first attempt
+			pharmacyNumber := uc.cfg.PharmacyNumber
+			record, sinkResults := currentUserState.Order, currentUserState.SinkResults
+			if record == nil {
+				// Take the next queue number of today for this pharmacy
+				currentQueueNumber, err := uc.queueCounter.Next(pharmacyNumber)
+				if err != nil {
+					uc.reply(phoneNumber, tmplDoctorQueueFailed, nil)
+					return err
+				}
+
+				// Record the prescription in every configured sink, a failing sink does not stop the order
+				record = utils.NewPrescriptionRecord(patientDetails, pharmacyNumber, currentQueueNumber, utils.NowWIB())
+				sinkResults = uc.sinks.Write(record)
+
+				// Track the status of the prescription so the pharmacist can update it
+				if _, err := uc.prescriptions.Add(record, pharmacyNumber, phoneNumber); err != nil {
+					log.Printf("Unable to store prescription %s: %v", record.ID, err)
+				}
+
+				currentUserState.Order, currentUserState.SinkResults = record, sinkResults
+				if err := uc.stateStore.Save(phoneNumber, currentUserState); err != nil {
+					log.Printf("Unable to remember prescription %s of %s: %v", record.ID, phoneNumber, err)
+				}
 			}
 
 			// **SEND TO PHARMACY LOGIC HERE**
-			pharmacyNumber := config.LoadConfig().PharmacyNumber
 			// Send the pending message to the pharmacy number
-			msgToPharmacy := fmt.Sprintf("Permintaan resep obat baru:\n\n%s \n\nDengan nomor Antrian: %d\n\nObat ini untuk:\n%s\n%s\n%s\n\nDari:\nDokter %s", patientDetails.Medication, currentQueueNumber, patientDetails.PatientName, patientDetails.PatientBirthDate, patientDetails.PatientPhoneNumber, patientDetails.DoctorName)
-			err = uc.SendMessage(pharmacyNumber, msgToPharmacy)
+			order := orderData{
+				Details:            *patientDetails,
+				QueueNumber:        record.QueueNumber,
+				AllergyWarnings:    confirm.AllergyWarnings,
+				MedicationWarnings: confirm.MedicationWarnings,
+			}
+			msgToPharmacy, err := uc.templates.Render(uc.localeOf(pharmacyNumber), tmplPharmacyOrder, order)
 			if err != nil {
-				uc.SendMessage(phoneNumber, "Gagal mengirim pesan ke apoteker. Mohon coba kembali lagi nanti.")
+				uc.reply(phoneNumber, tmplDoctorPharmacyFailed, nil)
+				return err
+			}
+			// The prescription knows its order message before it can be delivered, so the delivery hook finds it
+			_, err = uc.gateway.SendLinked(context.Background(), pharmacyNumber, msgToPharmacy, func(outboxID string) {
+				if err := uc.prescriptions.SetOrderOutboxID(record.ID, outboxID); err != nil {
+					log.Printf("Unable to link order message of prescription %s: %v", record.ID, err)
+				}
+			})
+			if err != nil {
+				log.Printf("Failed to send message to %s: %v", pharmacyNumber, err)
+				uc.reply(phoneNumber, tmplDoctorPharmacyFailed, nil)
 				return err
 			}
-			msgToPatient := fmt.Sprintf("Halo %s, permintaan resepmu:\n\n%s \n\nsudah dikirim ke apoteker. Antrian kamu adalah %d. Mohon ditunggu.", patientDetails.PatientName, patientDetails.Medication, currentQueueNumber)
-
 			if patientDetails.PatientPhoneNumber != "-" {
-				uc.SendMessage(patientDetails.PatientPhoneNumber, msgToPatient)
+				uc.reply(patientDetails.PatientPhoneNumber, tmplPatientOrderSent, order)
 			}
-			uc.SendMessage(phoneNumber, "Permintaan kamu sudah dikirimkan kebagian apoteker. Sesi Selesai.")
-			utils.ResetUserState(phoneNumber) // <-- Reset State
+			uc.reply(phoneNumber, tmplDoctorOrderSent, orderSentData{SinkResults: sinkResults})
+			return uc.stateStore.Reset(phoneNumber) // <-- Reset State
 		} else if decision == "N" {
-			formFormat := "Permintaan dibatalkan. Mohon kirim ulang dengan detail form yang benar:\nNama Dokter: \nNama Pasien: \nTanggal Lahir Pasien: \nNo Regis: \nResep Obat: \nNomor Telpon Pasien: \nPembiayaan: "
-			uc.SendMessage(phoneNumber, formFormat)
+			uc.reply(phoneNumber, tmplDoctorFormRetry, nil)
 			currentUserState.State = StateAwaitingFormSubmission // <-- State Transition
+			currentUserState.PendingMessage = ""
+			currentUserState.Order, currentUserState.SinkResults = nil, nil
+			return uc.stateStore.Save(phoneNumber, currentUserState)
 		}
 	}
 
 	return nil
 }
 
-// SendMessage sends a WhatsApp message via the API
-func (uc *messageUseCase) SendMessage(phoneNumber, message string) error {
-	cfg := config.LoadConfig()
-	url := fmt.Sprintf("%s/send/message", cfg.WhatsAppAPIURL)
-	gowaUsername := cfg.GowaAdmin
-	gowaPassword := cfg.GowaPassword
-
-	// Prepare the request payload
-	payload := map[string]interface{}{
-		"phone":   phoneNumber,
-		"message": message,
+// formDefaults fills in the doctor's own name and what the patient registry knows.
+// Doctors seeded from the environment only have a placeholder name, they still have to write theirs.
+func (uc *messageUseCase) formDefaults(phoneNumber string) utils.FormDefaults {
+	defaults := utils.FormDefaults{Patients: uc.patients}
+	if contact, ok := uc.contacts.Get(phoneNumber); ok && !contact.Seeded {
+		defaults.DoctorName = contact.Name
 	}
+	return defaults
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return &exception.InternalServerError{Message: "Failed to marshal message"}
+// confirmData is the confirmation prompt of a form: patient data taken from the registry and typed data
+// that disagrees with it, known allergies of the patient, stock that runs low, duplicate ingredients and
+// interactions, and whether the doctor must send the override keyword
+func (uc *messageUseCase) confirmData(messageText string, details *utils.PatientDetails) confirmData {
+	data := confirmData{
+		Message:            messageText,
+		Prefilled:          details.Prefilled,
+		Mismatches:         details.Mismatches,
+		AllergyWarnings:    utils.CheckAllergies(details.Medications, uc.patients.Allergies(details.RegistryNum), uc.formulary),
+		MedicationWarnings: utils.CheckMedications(details.Medications, uc.formulary, uc.interactions),
+		OverrideKeyword:    utils.OverrideKeyword,
 	}
+	data.OverrideRequired = len(data.AllergyWarnings) > 0 || utils.HasSevereInteraction(data.MedicationWarnings)
+	if uc.inventory != nil {
+		data.StockWarnings = uc.inventory.Warnings(details.Medications)
+	}
+	return data
+}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("Failed to create request: %v\n", err)
-		return &exception.InternalServerError{Message: "Failed to create request"}
+// SendMessage sends an ad-hoc message to a normalized phone number and waits until the gateway delivered it
+func (uc *messageUseCase) SendMessage(ctx context.Context, phoneNumber, message string) (*utils.Delivery, error) {
+	phone := utils.NormalizePhone(phoneNumber)
+	if phone == "-" || phone == "" {
+		return nil, &exception.BadRequestError{Message: "Phone number is required"}
+	}
+	if strings.TrimSpace(message) == "" {
+		return nil, &exception.BadRequestError{Message: "Message is required"}
 	}
 
-	// Add headers
-	req.Header.Set("Content-Type", "application/json")
+	return uc.gateway.SendAndWait(ctx, phone, message)
+}
 
-	// Add Basic Auth (username: admin, password: admin)
-	req.SetBasicAuth(gowaUsername, gowaPassword)
+// RenderMessage renders a message template with data given by an API caller, in the recipient's locale
+func (uc *messageUseCase) RenderMessage(phoneNumber, name string, data map[string]any) (string, error) {
+	if !uc.templates.Has(name) {
+		return "", &exception.BadRequestError{Message: fmt.Sprintf("Unknown message template: %s", name)}
+	}
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	message, err := uc.templates.Render(uc.localeOf(phoneNumber), name, data)
 	if err != nil {
-		fmt.Printf("HTTP request failed: %v\n", err)
-		return &exception.InternalServerError{Message: "Failed to send message: " + err.Error()}
+		return "", &exception.BadRequestError{Message: err.Error()}
+	}
+	return message, nil
+}
+
+// ReportFailedOrder tells the doctor that the order message of their prescription was given up on.
+// It is meant to be used as the outbox dead letter hook, other dead letters are ignored.
+func (uc *messageUseCase) ReportFailedOrder(outboxID string) {
+	prescription, ok := uc.prescriptions.MarkOrderFailed(outboxID)
+	if !ok {
+		return
 	}
-	defer resp.Body.Close()
+	log.Printf("Order message of prescription %s could not be delivered to the pharmacy", prescription.ID)
 
-	// Read response body for debugging
-	body, _ := io.ReadAll(resp.Body)
+	// The first history entry is the doctor who confirmed the prescription
+	if len(prescription.History) == 0 || prescription.History[0].ChangedBy == "" {
+		return
+	}
+	uc.reply(prescription.History[0].ChangedBy, tmplDoctorOrderFailed, prescriptionStatusData{
+		QueueNumber: prescription.QueueNumber,
+		PatientName: prescription.Details.PatientName,
+		Status:      prescription.Status,
+	})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return &exception.InternalServerError{Message: fmt.Sprintf("WhatsApp API returned error: %d - %s", resp.StatusCode, string(body))}
+// reply renders the message template in the recipient's locale and sends it
+func (uc *messageUseCase) reply(phoneNumber, name string, data any) error {
+	message, err := uc.templates.Render(uc.localeOf(phoneNumber), name, data)
+	if err != nil {
+		log.Printf("Unable to render message for %s: %v", phoneNumber, err)
+		return err
 	}
+	return uc.send(phoneNumber, message)
+}
 
-	return nil
+// send sends a WhatsApp message through the configured gateway
+func (uc *messageUseCase) send(phoneNumber, message string) error {
+	_, err := uc.gateway.Send(context.Background(), phoneNumber, message)
+	if err != nil {
+		log.Printf("Failed to send message to %s: %v", phoneNumber, err)
+	}
+	return err
 }
@@ -0,0 +1,868 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/config"
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"testing"
+	"time"
+)
+
+const (
+	testDoctor   = "6281111111111"
+	testPharmacy = "6289999999999"
+	testPatient  = "6281234567890"
+)
+
+// testForm is a complete prescription form for testPatient
+const testForm = `Nama Dokter: Budi
+Nama Pasien: Siti Aminah
+Tanggal Lahir Pasien: 01-02-1990
+No Regis: RM-001
+Resep Obat: Paracetamol 500mg tab No. X 3dd1
+Nomor Telpon Pasien: 081234567890
+Pembiayaan: BPJS`
+
+// testFormularyCSV stocks the drugs of testForm and a few more
+const testFormularyCSV = `generic,brand,strength,class
+Paracetamol,Sanmol,500 mg,Analgesik
+CTM,,4 mg,Antihistamin
+Amoxicillin,Amoxsan,500 mg,Penicillin
+Ibuprofen,Proris,400 mg,NSAID
+Warfarin,,2 mg,Antikoagulan
+`
+
+// writeTestFile writes content to name in a temporary directory and returns its path
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// useTestFormulary makes the bot check medications against testFormularyCSV
+func (b *testBot) useTestFormulary(t *testing.T) {
+	t.Helper()
+
+	formulary, err := utils.LoadFormulary(writeTestFile(t, "formulary.csv", testFormularyCSV))
+	if err != nil {
+		t.Fatalf("LoadFormulary: %v", err)
+	}
+	b.formulary = formulary
+}
+
+// recordingSink keeps the prescriptions written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	records []utils.PrescriptionRecord
+}
+
+func (s *recordingSink) Name() string {
+	return "Recording"
+}
+
+func (s *recordingSink) Write(record *utils.PrescriptionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, *record)
+	return nil
+}
+
+func (s *recordingSink) Records() []utils.PrescriptionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]utils.PrescriptionRecord{}, s.records...)
+}
+
+// testBot is the message use case with every store in memory and messages recorded instead of sent
+type testBot struct {
+	*messageUseCase
+	gateway *recordingGateway
+	sink    *recordingSink
+	nextID  int
+}
+
+func newTestBot(t *testing.T) *testBot {
+	t.Helper()
+
+	cfg := &config.Config{PharmacyNumber: testPharmacy}
+	seenMessages, err := utils.NewSeenSet("", time.Hour, 100)
+	if err != nil {
+		t.Fatalf("NewSeenSet: %v", err)
+	}
+	queueCounter, err := utils.NewFileQueueCounter("")
+	if err != nil {
+		t.Fatalf("NewFileQueueCounter: %v", err)
+	}
+	contacts, err := utils.NewContactRegistry("")
+	if err != nil {
+		t.Fatalf("NewContactRegistry: %v", err)
+	}
+	if err := contacts.Seed(testDoctor, "Budi", utils.RoleDoctor); err != nil {
+		t.Fatalf("Seed doctor: %v", err)
+	}
+	if err := contacts.Seed(testPharmacy, "Apotek", utils.RolePharmacist); err != nil {
+		t.Fatalf("Seed pharmacist: %v", err)
+	}
+	prescriptions, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	templates, err := utils.LoadMessageTemplates("../../../../templates", "id", MessageTemplateSamples())
+	if err != nil {
+		t.Fatalf("LoadMessageTemplates: %v", err)
+	}
+	locales, err := utils.NewLocaleStore("")
+	if err != nil {
+		t.Fatalf("NewLocaleStore: %v", err)
+	}
+	patients, err := utils.NewPatientRegistry("")
+	if err != nil {
+		t.Fatalf("NewPatientRegistry: %v", err)
+	}
+
+	gateway := &recordingGateway{}
+	sink := &recordingSink{}
+	uc := NewMessageUseCase(cfg, utils.NewMultiSink(sink), utils.NewMemoryStateStore(), queueCounter, seenMessages, gateway, contacts, prescriptions, utils.NewRateLimiter(5, time.Minute), templates, locales, nil, nil, nil, patients)
+	return &testBot{messageUseCase: uc.(*messageUseCase), gateway: gateway, sink: sink}
+}
+
+// receive processes a webhook with a fresh message ID and returns the error of the use case
+func (b *testBot) receive(t *testing.T, from, text string) error {
+	t.Helper()
+
+	b.nextID++
+	_, err := b.ProcessWebhookMessage(&WebhookMessage{
+		SenderID: from,
+		Message:  MessageContent{ID: fmt.Sprintf("msg-%d", b.nextID), Text: text},
+	})
+	return err
+}
+
+// mustReceive is receive for steps that have to succeed
+func (b *testBot) mustReceive(t *testing.T, from, text string) {
+	t.Helper()
+
+	if err := b.receive(t, from, text); err != nil {
+		t.Fatalf("processing %q from %s: %v", text, from, err)
+	}
+}
+
+// lastMessage returns the last message sent to phoneNumber
+func (b *testBot) lastMessage(t *testing.T, phoneNumber string) string {
+	t.Helper()
+
+	messages := b.gateway.Messages(phoneNumber)
+	if len(messages) == 0 {
+		t.Fatalf("no message was sent to %s", phoneNumber)
+	}
+	return messages[len(messages)-1].Message
+}
+
+func (b *testBot) state(t *testing.T, phoneNumber string) string {
+	t.Helper()
+
+	state, err := b.stateStore.Get(phoneNumber)
+	if err != nil {
+		t.Fatalf("state of %s: %v", phoneNumber, err)
+	}
+	return state.State
+}
+
+func assertContains(t *testing.T, message string, parts ...string) {
+	t.Helper()
+
+	for _, part := range parts {
+		if !strings.Contains(message, part) {
+			t.Fatalf("message does not contain %q:\n%s", part, message)
+		}
+	}
+}
+
+func TestDoctorFlowSendsOrder(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	assertContains(t, bot.lastMessage(t, testDoctor), "[1] Buat Resep")
+	if state := bot.state(t, testDoctor); state != StateAwaitingMenuChoice {
+		t.Fatalf("state after /start = %s, want %s", state, StateAwaitingMenuChoice)
+	}
+
+	bot.mustReceive(t, testDoctor, "1")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Nama Dokter:", "Resep Obat:")
+
+	bot.mustReceive(t, testDoctor, testForm)
+	assertContains(t, bot.lastMessage(t, testDoctor), "Mohon konfirmasi", "Siti Aminah", "(Y/N)")
+	if state := bot.state(t, testDoctor); state != StateAwaitingConfirmation {
+		t.Fatalf("state after the form = %s, want %s", state, StateAwaitingConfirmation)
+	}
+	if len(bot.gateway.Messages(testPharmacy)) != 0 {
+		t.Fatal("the pharmacy got a message before the doctor confirmed")
+	}
+
+	bot.mustReceive(t, testDoctor, "Y")
+
+	pharmacy := bot.gateway.Messages(testPharmacy)
+	if len(pharmacy) != 1 {
+		t.Fatalf("pharmacy got %d messages, want 1", len(pharmacy))
+	}
+	assertContains(t, pharmacy[0].Message, "Siti Aminah", "Paracetamol 500 mg tablet, No. X (10)", "Antrian: 1", "Dokter Budi")
+
+	assertContains(t, bot.lastMessage(t, testPatient), "Siti Aminah", "Antrian kamu adalah 1")
+	assertContains(t, bot.lastMessage(t, testDoctor), "sudah dikirimkan kebagian apoteker", "Recording")
+
+	if state := bot.state(t, testDoctor); state != StateAwaitingStart {
+		t.Fatalf("state after Y = %s, want %s", state, StateAwaitingStart)
+	}
+	if records := bot.sink.Records(); len(records) != 1 || records[0].QueueNumber != 1 {
+		t.Fatalf("sink records = %+v, want queue number 1", records)
+	}
+	stored := bot.prescriptions.List(nil)
+	if len(stored) != 1 || stored[0].OrderOutboxID != pharmacy[0].ID {
+		t.Fatalf("stored prescriptions = %+v, want one linked to order message %s", stored, pharmacy[0].ID)
+	}
+}
+
+func TestDoctorFlowInvalidFormKeepsState(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, "Nama Dokter: Budi\nTanggal Lahir Pasien: 31-02-1990")
+
+	assertContains(t, bot.lastMessage(t, testDoctor), "Format yang dikirimkan salah", "Nama Pasien: wajib diisi", "`31-02-1990` tidak valid")
+	if state := bot.state(t, testDoctor); state != StateAwaitingFormSubmission {
+		t.Fatalf("state after an invalid form = %s, want %s", state, StateAwaitingFormSubmission)
+	}
+}
+
+func TestDoctorFlowEditAfterConfirmation(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, testForm)
+	bot.mustReceive(t, testDoctor, "N")
+
+	assertContains(t, bot.lastMessage(t, testDoctor), "Mohon kirim ulang")
+	if state := bot.state(t, testDoctor); state != StateAwaitingFormSubmission {
+		t.Fatalf("state after N = %s, want %s", state, StateAwaitingFormSubmission)
+	}
+	if len(bot.gateway.Messages(testPharmacy)) != 0 || len(bot.gateway.Messages(testPatient)) != 0 {
+		t.Fatal("a message was sent to the pharmacy or the patient after N")
+	}
+}
+
+func TestReplayedWebhookIsHandledOnce(t *testing.T) {
+	bot := newTestBot(t)
+	payload := &WebhookMessage{SenderID: testDoctor, Message: MessageContent{ID: "3EB0REPLAY", Text: "/start"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := bot.ProcessWebhookMessage(payload); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	if messages := bot.gateway.Messages(testDoctor); len(messages) != 1 {
+		t.Fatalf("doctor got %d replies to a replayed webhook, want 1", len(messages))
+	}
+	if state := bot.state(t, testDoctor); state != StateAwaitingMenuChoice {
+		t.Fatalf("state = %s, want %s", state, StateAwaitingMenuChoice)
+	}
+}
+
+func TestFailedWebhookIsHandledOnRetry(t *testing.T) {
+	bot := newTestBot(t)
+	payload := &WebhookMessage{SenderID: testDoctor, Message: MessageContent{ID: "3EB0RETRY", Text: "halo"}}
+
+	bot.gateway.setErr(errors.New("engine offline"))
+	if _, err := bot.ProcessWebhookMessage(payload); err == nil {
+		t.Fatal("processing succeeded while the gateway is down")
+	}
+
+	bot.gateway.setErr(nil)
+	if _, err := bot.ProcessWebhookMessage(payload); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	assertContains(t, bot.lastMessage(t, testDoctor), "/start")
+
+	// Once handled, the same payload is a duplicate again
+	if _, err := bot.ProcessWebhookMessage(payload); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if messages := bot.gateway.Messages(testDoctor); len(messages) != 1 {
+		t.Fatalf("doctor got %d replies, want 1", len(messages))
+	}
+}
+
+func TestDoctorFlowRetryAfterFailedSendKeepsQueueNumber(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, testForm)
+
+	// The pharmacy cannot be reached, and neither can the doctor to hear about it
+	bot.gateway.setErr(errors.New("engine offline"))
+	if err := bot.receive(t, testDoctor, "Y"); err == nil {
+		t.Fatal("Y succeeded while the gateway is down")
+	}
+	if state := bot.state(t, testDoctor); state != StateAwaitingConfirmation {
+		t.Fatalf("state after a failed send = %s, want %s", state, StateAwaitingConfirmation)
+	}
+
+	bot.gateway.setErr(nil)
+	bot.mustReceive(t, testDoctor, "Y")
+
+	pharmacy := bot.gateway.Messages(testPharmacy)
+	if len(pharmacy) != 1 {
+		t.Fatalf("pharmacy got %d messages, want 1", len(pharmacy))
+	}
+	assertContains(t, pharmacy[0].Message, "Antrian: 1")
+	if records := bot.sink.Records(); len(records) != 1 || records[0].QueueNumber != 1 {
+		t.Fatalf("sink records = %+v, want a single row with queue number 1", records)
+	}
+	if stored := bot.prescriptions.List(nil); len(stored) != 1 {
+		t.Fatalf("stored %d prescriptions, want 1", len(stored))
+	}
+	if state := bot.state(t, testDoctor); state != StateAwaitingStart {
+		t.Fatalf("state after the retry = %s, want %s", state, StateAwaitingStart)
+	}
+
+	// The next order takes the next number again
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, testForm)
+	bot.mustReceive(t, testDoctor, "Y")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Antrian: 2")
+}
+
+// receiveReply processes a message that quotes the message with repliedID
+func (b *testBot) receiveReply(t *testing.T, from, text, repliedID, quoted string) {
+	t.Helper()
+
+	b.nextID++
+	_, err := b.ProcessWebhookMessage(&WebhookMessage{
+		SenderID: from,
+		Message:  MessageContent{ID: fmt.Sprintf("msg-%d", b.nextID), Text: text, RepliedID: repliedID, QuotedMessage: quoted},
+	})
+	if err != nil {
+		t.Fatalf("processing reply %q from %s: %v", text, from, err)
+	}
+}
+
+// sendTestOrder runs the doctor flow once and returns the order message the pharmacy got
+func (b *testBot) sendTestOrder(t *testing.T) sentMessage {
+	t.Helper()
+
+	b.mustReceive(t, testDoctor, "/start")
+	b.mustReceive(t, testDoctor, "1")
+	b.mustReceive(t, testDoctor, testForm)
+	b.mustReceive(t, testDoctor, "Y")
+
+	messages := b.gateway.Messages(testPharmacy)
+	return messages[len(messages)-1]
+}
+
+func TestPharmacistReplyUsesLinkedOrderMessage(t *testing.T) {
+	bot := newTestBot(t)
+	order := bot.sendTestOrder(t)
+	bot.prescriptions.LinkOrderMessage(order.ID, "3EB0ORDER")
+
+	bot.receiveReply(t, testPharmacy, "siap", "3EB0ORDER", order.Message)
+
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Status antrian 1", "Siap Diambil")
+	if stored := bot.prescriptions.List(nil); stored[0].Status != utils.StatusReady {
+		t.Fatalf("status = %s, want %s", stored[0].Status, utils.StatusReady)
+	}
+}
+
+func TestPharmacistReplyIgnoresQueueNumberInQuotedText(t *testing.T) {
+	bot := newTestBot(t)
+	bot.sendTestOrder(t)
+
+	// An order of another day quotes the same queue number but was never linked
+	bot.receiveReply(t, testPharmacy, "siap", "3EB0YESTERDAY", "Permintaan resep obat baru:\n\nDengan nomor Antrian: 1")
+
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Resep tidak ditemukan")
+	if stored := bot.prescriptions.List(nil); stored[0].Status != utils.StatusReceived {
+		t.Fatalf("status = %s, the prescription of today's queue number 1 was changed", stored[0].Status)
+	}
+}
+
+func TestDoctorNameIsOnlyPrefilledOnceAnAdminSetIt(t *testing.T) {
+	bot := newTestBot(t)
+	form := strings.Replace(testForm, "Nama Dokter: Budi\n", "", 1)
+
+	// The seeded doctor only has a placeholder name
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, form)
+	assertContains(t, bot.lastMessage(t, testDoctor), "Nama Dokter: wajib diisi")
+
+	if _, err := bot.contacts.Upsert(utils.Contact{PhoneNumber: testDoctor, Name: "Budi Santoso", Role: utils.RoleDoctor, Active: true}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	bot.mustReceive(t, testDoctor, form)
+	bot.mustReceive(t, testDoctor, "Y")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Dokter Budi Santoso")
+}
+
+func TestPatientOnlySeesOwnPrescription(t *testing.T) {
+	bot := newTestBot(t)
+	const otherPatient = "6285555555555"
+
+	// Another patient is first in the queue
+	other := strings.NewReplacer("Siti Aminah", "Budi Santoso", "RM-001", "RM-002", "081234567890", "085555555555").Replace(testForm)
+	for _, form := range []string{other, testForm} {
+		bot.mustReceive(t, testDoctor, "/start")
+		bot.mustReceive(t, testDoctor, "1")
+		bot.mustReceive(t, testDoctor, form)
+		bot.mustReceive(t, testDoctor, "Y")
+	}
+
+	bot.mustReceive(t, testPatient, "antrian")
+	reply := bot.lastMessage(t, testPatient)
+	assertContains(t, reply, "Antrian 2", "ada 1 antrian di depan kamu")
+	for _, leaked := range []string{"Budi Santoso", "RM-002", "Antrian 1"} {
+		if strings.Contains(reply, leaked) {
+			t.Fatalf("the patient was shown %q of another prescription:\n%s", leaked, reply)
+		}
+	}
+
+	bot.mustReceive(t, otherPatient, "status")
+	assertContains(t, bot.lastMessage(t, otherPatient), "Antrian 1", "ada 0 antrian di depan kamu")
+
+	// Numbers without a prescription today are ignored
+	bot.gateway.Reset()
+	bot.mustReceive(t, "6287777777777", "antrian")
+	if messages := bot.gateway.Messages("6287777777777"); len(messages) != 0 {
+		t.Fatalf("a stranger got %d replies", len(messages))
+	}
+}
+
+func TestPatientQueriesAreRateLimited(t *testing.T) {
+	bot := newTestBot(t)
+	bot.sendTestOrder(t)
+	bot.gateway.Reset()
+
+	// newTestBot allows 5 answers a minute
+	for i := 0; i < 7; i++ {
+		bot.mustReceive(t, testPatient, "antrian")
+	}
+	if messages := bot.gateway.Messages(testPatient); len(messages) != 5 {
+		t.Fatalf("patient got %d answers, want 5", len(messages))
+	}
+}
+
+func TestLangCommand(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/lang")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Bahasa kamu sekarang: id", "en, id")
+
+	bot.mustReceive(t, testDoctor, "/lang fr")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Bahasa `fr` tidak tersedia")
+
+	bot.mustReceive(t, testDoctor, "/LANG EN")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Language changed to English")
+
+	// The conversation continues in English and accepts the English form
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Doctor Name:", "Medication:")
+	english := strings.NewReplacer("Nama Dokter", "Doctor Name", "Nama Pasien", "Patient Name", "Tanggal Lahir Pasien", "Patient Birth Date",
+		"No Regis", "Registry No", "Resep Obat", "Medication", "Nomor Telpon Pasien", "Patient Phone Number", "Pembiayaan", "Payment").Replace(testForm)
+	bot.mustReceive(t, testDoctor, english)
+	assertContains(t, bot.lastMessage(t, testDoctor), "Please confirm")
+
+	// The pharmacy and the patient keep their own language
+	bot.mustReceive(t, testDoctor, "Y")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Permintaan resep obat baru")
+	assertContains(t, bot.lastMessage(t, testPatient), "Antrian kamu adalah 1")
+}
+
+func TestPharmacistReplyInEnglish(t *testing.T) {
+	bot := newTestBot(t)
+	bot.mustReceive(t, testPharmacy, "/lang en")
+	order := bot.sendTestOrder(t)
+	assertContains(t, order.Message, "Queue number: 1")
+	bot.prescriptions.LinkOrderMessage(order.ID, "3EB0ORDER")
+
+	// The reply is found by the quoted message, not by the words of the order
+	bot.receiveReply(t, testPharmacy, "siap", "3EB0ORDER", order.Message)
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Queue 1 (Siti Aminah) is now Ready for pickup")
+
+	bot.mustReceive(t, testPharmacy, "diambil 1")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Queue 1 (Siti Aminah) is now Picked up")
+}
+
+func TestDoctorFlowRejectsUnknownDrugBeforeConfirmation(t *testing.T) {
+	bot := newTestBot(t)
+	bot.useTestFormulary(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	// "Ambroxol" is not in the formulary, and must not hide behind the Paracetamol before it
+	bot.mustReceive(t, testDoctor, strings.Replace(testForm, "Paracetamol 500mg tab No. X 3dd1", "Paracetamol 500mg, CTM 4mg, Ambroxol", 1))
+
+	assertContains(t, bot.lastMessage(t, testDoctor), "Format yang dikirimkan salah", "obat `Ambroxol` tidak ada di formularium")
+	if state := bot.state(t, testDoctor); state != StateAwaitingFormSubmission {
+		t.Fatalf("state = %s, want %s", state, StateAwaitingFormSubmission)
+	}
+
+	// Extra words after a known name are not ignored either
+	bot.mustReceive(t, testDoctor, strings.Replace(testForm, "Paracetamol 500mg tab", "Paracetamol Ambroxol 500mg tab", 1))
+	assertContains(t, bot.lastMessage(t, testDoctor), "obat `Paracetamol Ambroxol` tidak ada di formularium, mungkin maksud anda: Paracetamol")
+	if state := bot.state(t, testDoctor); state != StateAwaitingFormSubmission {
+		t.Fatalf("state = %s, want %s", state, StateAwaitingFormSubmission)
+	}
+	if len(bot.gateway.Messages(testPharmacy)) != 0 {
+		t.Fatal("the pharmacy got an order with an unknown drug")
+	}
+}
+
+// testInteractionsCSV has one severe and one moderate pair of testFormularyCSV
+const testInteractionsCSV = `drug_a,drug_b,severity,description
+Ibuprofen,Warfarin,severe,Risiko perdarahan
+Ibuprofen,Paracetamol,moderate,
+`
+
+// useTestInteractions makes the bot check testFormularyCSV drugs against testInteractionsCSV
+func (b *testBot) useTestInteractions(t *testing.T) {
+	t.Helper()
+
+	b.useTestFormulary(t)
+	interactions, err := utils.LoadInteractions(writeTestFile(t, "interactions.csv", testInteractionsCSV))
+	if err != nil {
+		t.Fatalf("LoadInteractions: %v", err)
+	}
+	b.interactions = interactions
+}
+
+// submitTestForm runs the doctor flow up to the confirmation prompt with these medications
+func (b *testBot) submitTestForm(t *testing.T, medication string) string {
+	t.Helper()
+
+	b.mustReceive(t, testDoctor, "/start")
+	b.mustReceive(t, testDoctor, "1")
+	b.mustReceive(t, testDoctor, strings.Replace(testForm, "Paracetamol 500mg tab No. X 3dd1", medication, 1))
+	if state := b.state(t, testDoctor); state != StateAwaitingConfirmation {
+		t.Fatalf("state after the form = %s, want %s:\n%s", state, StateAwaitingConfirmation, b.lastMessage(t, testDoctor))
+	}
+	return b.lastMessage(t, testDoctor)
+}
+
+func TestSevereInteractionNeedsOverride(t *testing.T) {
+	bot := newTestBot(t)
+	bot.useTestInteractions(t)
+
+	prompt := bot.submitTestForm(t, "Ibuprofen 400mg no X 3dd1\nWarfarin 2mg no X 1dd1")
+	assertContains(t, prompt, "Interaksi BERAT: Ibuprofen dan Warfarin (Risiko perdarahan)", "Kirim `OVERRIDE`")
+
+	// Y is refused and the prescription stays waiting for the doctor
+	bot.mustReceive(t, testDoctor, "Y")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Resep belum dikirim", "Interaksi BERAT")
+	if len(bot.gateway.Messages(testPharmacy)) != 0 {
+		t.Fatal("the pharmacy got an order with a severe interaction after Y")
+	}
+	if state := bot.state(t, testDoctor); state != StateAwaitingConfirmation {
+		t.Fatalf("state after Y = %s, want %s", state, StateAwaitingConfirmation)
+	}
+
+	bot.mustReceive(t, testDoctor, "override")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Interaksi BERAT: Ibuprofen dan Warfarin", "Dokter sudah mengonfirmasi resep ini")
+	assertContains(t, bot.lastMessage(t, testDoctor), "sudah dikirimkan kebagian apoteker")
+}
+
+func TestMildWarningsAcceptY(t *testing.T) {
+	tests := map[string]struct {
+		medication string
+		warning    string
+	}{
+		"moderate interaction": {"Ibuprofen 400mg no X 3dd1\nParacetamol 500mg no X 3dd1", "Interaksi sedang: Ibuprofen dan Paracetamol"},
+		"duplicate":            {"Sanmol 500mg no X 3dd1\nParacetamol 500mg no X 3dd1", "Duplikasi: Sanmol dan Paracetamol sama-sama mengandung Paracetamol"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			bot := newTestBot(t)
+			bot.useTestInteractions(t)
+
+			prompt := bot.submitTestForm(t, tt.medication)
+			assertContains(t, prompt, tt.warning, "(Y/N)")
+			if strings.Contains(prompt, "OVERRIDE") {
+				t.Fatalf("the prompt asks for an override:\n%s", prompt)
+			}
+
+			bot.mustReceive(t, testDoctor, "Y")
+			assertContains(t, bot.lastMessage(t, testPharmacy), tt.warning)
+		})
+	}
+}
+
+func TestAllergyCommand(t *testing.T) {
+	bot := newTestBot(t)
+
+	bot.mustReceive(t, testDoctor, "/alergi RM-001 Penicillin")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Alergi pasien RM-001:", "- Penicillin")
+
+	// "hapus" without an allergy shows the usage instead of recording an allergy called "hapus"
+	for _, command := range []string{"/alergi RM-001 hapus", "/alergi RM-001 remove"} {
+		bot.mustReceive(t, testDoctor, command)
+		assertContains(t, bot.lastMessage(t, testDoctor), "Perintah alergi pasien")
+	}
+	if allergies := bot.patients.Allergies("RM-001"); len(allergies) != 1 || allergies[0].Name != "Penicillin" {
+		t.Fatalf("allergies = %+v, want only Penicillin", allergies)
+	}
+
+	bot.mustReceive(t, testDoctor, "/alergi RM-001 hapus penicillin")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Pasien RM-001 tidak memiliki alergi yang tercatat")
+	bot.mustReceive(t, testDoctor, "/alergi RM-001 hapus Penicillin")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Gagal mengubah alergi")
+}
+
+func TestAllergyNeedsOverride(t *testing.T) {
+	bot := newTestBot(t)
+	bot.useTestFormulary(t)
+	bot.mustReceive(t, testDoctor, "/alergi RM-001 Penicillin")
+
+	// Amoxsan is a brand of Amoxicillin, a penicillin
+	prompt := bot.submitTestForm(t, "Amoxsan 500mg no X 3dd1")
+	assertContains(t, prompt, "Amoxsan: pasien alergi Penicillin", "Kirim `OVERRIDE`")
+
+	bot.mustReceive(t, testDoctor, "Y")
+	assertContains(t, bot.lastMessage(t, testDoctor), "Resep belum dikirim", "Amoxsan: pasien alergi Penicillin")
+	if len(bot.gateway.Messages(testPharmacy)) != 0 {
+		t.Fatal("the pharmacy got an order the patient is allergic to after Y")
+	}
+
+	bot.mustReceive(t, testDoctor, "OVERRIDE")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Amoxsan: pasien alergi Penicillin", "Dokter sudah mengonfirmasi resep ini")
+}
+
+func TestReadyStatusNotifiesPatient(t *testing.T) {
+	bot := newTestBot(t)
+	bot.sendTestOrder(t)
+
+	bot.mustReceive(t, testPharmacy, "proses 1")
+	if messages := bot.gateway.Messages(testPatient); len(messages) != 1 {
+		t.Fatalf("patient got %d messages, want only the order confirmation before ready", len(messages))
+	}
+
+	bot.mustReceive(t, testPharmacy, "siap 1")
+	assertContains(t, bot.lastMessage(t, testPatient), "Halo Siti Aminah, obat kamu dengan nomor antrian 1 sudah siap")
+}
+
+func TestReadyStatusSkipsPatientWithoutPhoneNumber(t *testing.T) {
+	bot := newTestBot(t)
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, strings.Replace(testForm, "081234567890", "-", 1))
+	bot.mustReceive(t, testDoctor, "Y")
+
+	bot.mustReceive(t, testPharmacy, "siap 1")
+	assertContains(t, bot.lastMessage(t, testPharmacy), "Status antrian 1", "Siap Diambil")
+	if messages := bot.gateway.Messages("-"); len(messages) != 0 {
+		t.Fatalf("%d messages were sent to -", len(messages))
+	}
+}
+
+func TestSendMessageNormalizesPhoneNumber(t *testing.T) {
+	bot := newTestBot(t)
+
+	delivery, err := bot.SendMessage(context.Background(), "0812-3456-7890", "Halo")
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if delivery.Status != utils.DeliverySent || delivery.MessageID == "" {
+		t.Fatalf("delivery = %+v, want sent with the gateway's message ID", delivery)
+	}
+	if messages := bot.gateway.Messages(testPatient); len(messages) != 1 || messages[0].Message != "Halo" {
+		t.Fatalf("messages to %s = %+v", testPatient, messages)
+	}
+
+	for _, bad := range [][2]string{{"", "Halo"}, {"-", "Halo"}, {testPatient, "  "}} {
+		var badRequest *exception.BadRequestError
+		if _, err := bot.SendMessage(context.Background(), bad[0], bad[1]); !errors.As(err, &badRequest) {
+			t.Errorf("SendMessage(%q, %q) error = %v, want a BadRequestError", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestRenderMessageInRecipientLocale(t *testing.T) {
+	bot := newTestBot(t)
+	data := map[string]any{"PatientName": "Siti", "QueueNumber": 3}
+
+	message, err := bot.RenderMessage(testPatient, tmplPatientReady, data)
+	if err != nil {
+		t.Fatalf("RenderMessage: %v", err)
+	}
+	assertContains(t, message, "Halo Siti, obat kamu dengan nomor antrian 3 sudah siap")
+
+	if err := bot.locales.Set(testPatient, "en"); err != nil {
+		t.Fatalf("Set locale: %v", err)
+	}
+	message, err = bot.RenderMessage(testPatient, tmplPatientReady, data)
+	if err != nil {
+		t.Fatalf("RenderMessage: %v", err)
+	}
+	assertContains(t, message, "Hello Siti, your medication with queue number 3 is ready")
+
+	var badRequest *exception.BadRequestError
+	if _, err := bot.RenderMessage(testPatient, "patient.unknown", data); !errors.As(err, &badRequest) {
+		t.Fatalf("unknown template error = %v, want a BadRequestError", err)
+	}
+}
+
+const testAdmin = "6287777777777"
+
+func (b *testBot) seedTestAdmin(t *testing.T) {
+	t.Helper()
+
+	if err := b.contacts.Seed(testAdmin, "Admin", utils.RoleAdmin); err != nil {
+		t.Fatalf("Seed admin: %v", err)
+	}
+}
+
+func TestAdminAddsDoctor(t *testing.T) {
+	bot := newTestBot(t)
+	bot.seedTestAdmin(t)
+	const newDoctor = "6282222222222"
+
+	bot.mustReceive(t, testAdmin, "/dokter tambah 082222222222 Rina Wati")
+	assertContains(t, bot.lastMessage(t, testAdmin), "Dokter Rina Wati (6282222222222) sudah ditambahkan")
+
+	// The new doctor is let in right away, with the name the admin gave
+	bot.mustReceive(t, newDoctor, "/start")
+	assertContains(t, bot.lastMessage(t, newDoctor), "[1] Buat Resep")
+	if doctor, _ := bot.contacts.Get(newDoctor); doctor.Role != utils.RoleDoctor || doctor.Name != "Rina Wati" {
+		t.Fatalf("contact = %+v, want doctor Rina Wati", doctor)
+	}
+
+	bot.mustReceive(t, testAdmin, "/dokter daftar")
+	assertContains(t, bot.lastMessage(t, testAdmin), "Rina Wati (6282222222222) - aktif")
+}
+
+func TestAdminRemovesDoctor(t *testing.T) {
+	bot := newTestBot(t)
+	bot.seedTestAdmin(t)
+
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+
+	bot.mustReceive(t, testAdmin, "/dokter hapus 081111111111")
+	assertContains(t, bot.lastMessage(t, testAdmin), "(6281111111111) sudah dihapus")
+
+	// The half written prescription is dropped and the bot no longer answers the number
+	if state := bot.state(t, testDoctor); state != StateAwaitingStart {
+		t.Fatalf("state after removal = %s, want %s", state, StateAwaitingStart)
+	}
+	bot.gateway.Reset()
+	bot.mustReceive(t, testDoctor, "/start")
+	if messages := bot.gateway.Messages(testDoctor); len(messages) != 0 {
+		t.Fatalf("removed doctor got %d messages, want none", len(messages))
+	}
+
+	bot.mustReceive(t, testAdmin, "/dokter daftar")
+	assertContains(t, bot.lastMessage(t, testAdmin), "(6281111111111) - nonaktif")
+}
+
+func TestAdminCommandErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{"add without name", "/dokter tambah 082222222222", "Gunakan `/dokter tambah <nomor> <nama>`"},
+		{"add with bad number", "/dokter tambah abc Rina", "Gagal menambahkan dokter"},
+		{"remove without number", "/dokter hapus", "Gunakan `/dokter hapus <nomor>`"},
+		{"remove the pharmacist", "/dokter hapus " + testPharmacy, "bukan dokter yang aktif"},
+		{"remove an unknown number", "/dokter hapus 083333333333", "bukan dokter yang aktif"},
+		{"unknown subcommand", "/dokter ubah", "Perintah admin:"},
+		{"anything else", "halo", "Perintah admin:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := newTestBot(t)
+			bot.seedTestAdmin(t)
+
+			bot.mustReceive(t, testAdmin, tt.command)
+			assertContains(t, bot.lastMessage(t, testAdmin), tt.want)
+
+			// Nothing changed about the pharmacist
+			if !bot.contacts.HasRole(testPharmacy, utils.RolePharmacist) || !bot.contacts.IsActive(testPharmacy) {
+				t.Fatalf("pharmacist was changed by %q", tt.command)
+			}
+		})
+	}
+}
+
+func TestFailedOrderIsReportedToTheDoctor(t *testing.T) {
+	bot := newTestBot(t)
+	order := bot.sendTestOrder(t)
+	bot.gateway.Reset()
+
+	// A reply to the patient is dead-lettered too, the doctor only hears about the order
+	bot.ReportFailedOrder("outbox-something-else")
+	if messages := bot.gateway.Messages(testDoctor); len(messages) != 0 {
+		t.Fatalf("doctor got %v for a message that is not an order", messages)
+	}
+
+	bot.ReportFailedOrder(order.ID)
+	assertContains(t, bot.lastMessage(t, testDoctor), "antrean 1", "Siti Aminah", "gagal terkirim ke apoteker")
+
+	stored := bot.prescriptions.List(nil)
+	if len(stored) != 1 || stored[0].OrderFailedAt == nil {
+		t.Fatalf("prescriptions = %+v, want the order marked as failed", stored)
+	}
+}
+
+func TestWebhookIsAcceptedOnlyFromSendersWithARole(t *testing.T) {
+	bot := newTestBot(t)
+	accepted := func(from string) bool {
+		t.Helper()
+		bot.nextID++
+		ok, err := bot.ProcessWebhookMessage(&WebhookMessage{
+			SenderID: from,
+			Message:  MessageContent{ID: fmt.Sprintf("msg-%d", bot.nextID), Text: "antrian"},
+		})
+		if err != nil {
+			t.Fatalf("processing a message from %s: %v", from, err)
+		}
+		return ok
+	}
+
+	if accepted(testPatient) {
+		t.Fatal("the patient was accepted before they had a prescription")
+	}
+	bot.sendTestOrder(t)
+	for _, sender := range []string{testDoctor, testPharmacy, testPatient, "081234567890@s.whatsapp.net"} {
+		if !accepted(sender) {
+			t.Errorf("%s was ignored", sender)
+		}
+	}
+	if accepted("6287777777777") {
+		t.Fatal("a stranger was accepted")
+	}
+
+	if _, err := bot.contacts.Deactivate(testDoctor); err != nil {
+		t.Fatalf("Deactivate: %v", err)
+	}
+	if accepted(testDoctor) {
+		t.Fatal("a removed doctor was accepted")
+	}
+}
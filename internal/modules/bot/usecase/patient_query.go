@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// rolePatient is given to senders that are not contacts but have a prescription today
+const rolePatient = "patient"
+
+// todayPrescriptionsOf returns today's prescriptions whose patient phone is the sender
+func (uc *messageUseCase) todayPrescriptionsOf(phoneNumber string) []utils.Prescription {
+	return uc.prescriptions.FindByPatient(utils.NowWIB(), phoneNumber)
+}
+
+// handlePatientMessage answers a patient's question about their own queue, and nothing else
+func (uc *messageUseCase) handlePatientMessage(phoneNumber, messageText string) error {
+	if !uc.patientLimiter.Allow(utils.NormalizePhone(phoneNumber)) {
+		return nil
+	}
+
+	if isLangCommand(messageText) {
+		return uc.handleLangCommand(phoneNumber, messageText)
+	}
+
+	command := strings.ToLower(strings.TrimSpace(messageText))
+	if command != "antrian" && command != "status" {
+		return uc.reply(phoneNumber, tmplPatientHelp, nil)
+	}
+
+	prescriptions := uc.todayPrescriptionsOf(phoneNumber)
+	if len(prescriptions) == 0 {
+		return uc.reply(phoneNumber, tmplPatientNoPrescription, nil)
+	}
+
+	positions := make([]queuePosition, 0, len(prescriptions))
+	for _, prescription := range prescriptions {
+		positions = append(positions, uc.queuePositionOf(&prescription))
+	}
+	return uc.reply(phoneNumber, tmplPatientQueueStatus, queueStatusData{Positions: positions})
+}
+
+// queuePositionOf works out where the prescription is in today's queue.
+// Only counts of other prescriptions are used, never their details.
+func (uc *messageUseCase) queuePositionOf(prescription *utils.Prescription) queuePosition {
+	position := queuePosition{QueueNumber: prescription.QueueNumber, Status: prescription.Status}
+	if prescription.Status == utils.StatusReady || prescription.Status == utils.StatusPickedUp {
+		return position
+	}
+
+	day := prescription.CreatedAt.In(utils.WIB).Format("2006-01-02")
+	ahead := uc.prescriptions.List(func(p *utils.Prescription) bool {
+		return p.CreatedAt.In(utils.WIB).Format("2006-01-02") == day &&
+			p.PharmacyNumber == prescription.PharmacyNumber &&
+			p.QueueNumber < prescription.QueueNumber &&
+			(p.Status == utils.StatusReceived || p.Status == utils.StatusPreparing)
+	})
+
+	position.Ahead = len(ahead)
+	return position
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// StatePharmacistAwaitingQueueNumber is set when the pharmacist sent a command without saying which prescription it is for
+const StatePharmacistAwaitingQueueNumber = "PHARMACIST_AWAITING_QUEUE_NUMBER"
+
+// pharmacistCommands maps the words a pharmacist can send to the status they set
+var pharmacistCommands = map[string]string{
+	"proses":  utils.StatusPreparing,
+	"siapkan": utils.StatusPreparing,
+	"siap":    utils.StatusReady,
+	"ambil":   utils.StatusPickedUp,
+	"diambil": utils.StatusPickedUp,
+	"selesai": utils.StatusPickedUp,
+}
+
+// handlePharmacistMessage lets the pharmacist move prescriptions through their statuses
+func (uc *messageUseCase) handlePharmacistMessage(phoneNumber string, message *MessageContent) error {
+	currentUserState, err := uc.stateStore.Get(phoneNumber)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(strings.ToLower(message.Text))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// The pharmacist was asked which queue number the command is for
+	if currentUserState.State == StatePharmacistAwaitingQueueNumber {
+		if fields[0] == "batal" || fields[0] == "cancel" {
+			uc.reply(phoneNumber, tmplPharmacistCancelled, nil)
+			return uc.stateStore.Reset(phoneNumber)
+		}
+		if queueNumber, err := strconv.Atoi(fields[0]); err == nil {
+			status := currentUserState.PendingMessage
+			if err := uc.stateStore.Reset(phoneNumber); err != nil {
+				return err
+			}
+			return uc.applyPharmacistStatus(phoneNumber, uc.findTodayPrescription(queueNumber), status)
+		}
+		// Anything else is treated as a new command
+	}
+
+	if fields[0] == "daftar" || fields[0] == "list" {
+		return uc.reply(phoneNumber, tmplPharmacistOpenList, openPrescriptionsData{Prescriptions: uc.openPrescriptions()})
+	}
+
+	status, ok := pharmacistCommands[fields[0]]
+	if !ok {
+		return uc.reply(phoneNumber, tmplPharmacistHelp, nil)
+	}
+
+	// Find the prescription: an explicit queue number, the quoted order message, or ask for it
+	var prescription *utils.Prescription
+	switch {
+	case len(fields) > 1:
+		queueNumber, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return uc.reply(phoneNumber, tmplPharmacistQueueNotNumber, nil)
+		}
+		prescription = uc.findTodayPrescription(queueNumber)
+	case message.RepliedID != "":
+		prescription = uc.findQuotedPrescription(message)
+	default:
+		currentUserState.State = StatePharmacistAwaitingQueueNumber
+		currentUserState.PendingMessage = status
+		uc.reply(phoneNumber, tmplPharmacistAskQueue, nil)
+		return uc.stateStore.Save(phoneNumber, currentUserState)
+	}
+
+	// A new command replaces the one that was waiting for a queue number
+	if currentUserState.State == StatePharmacistAwaitingQueueNumber {
+		if err := uc.stateStore.Reset(phoneNumber); err != nil {
+			return err
+		}
+	}
+	return uc.applyPharmacistStatus(phoneNumber, prescription, status)
+}
+
+// applyPharmacistStatus updates the prescription and writes the status to the sinks
+func (uc *messageUseCase) applyPharmacistStatus(phoneNumber string, prescription *utils.Prescription, status string) error {
+	if prescription == nil {
+		return uc.reply(phoneNumber, tmplPharmacistNotFound, nil)
+	}
+
+	updated, err := uc.prescriptions.UpdateStatus(prescription.ID, status, phoneNumber)
+	if err != nil {
+		return uc.reply(phoneNumber, tmplPharmacistStatusRejected, prescriptionStatusData{
+			QueueNumber: prescription.QueueNumber,
+			PatientName: prescription.Details.PatientName,
+			Status:      prescription.Status,
+		})
+	}
+
+	for _, result := range uc.sinks.WriteStatus(&updated.PrescriptionRecord, status) {
+		if !result.Success {
+			log.Printf("Status of prescription %s was not written to %s: %s", updated.ID, result.Sink, result.Error)
+		}
+	}
+
+	if status == utils.StatusReady {
+		uc.notifyPatientReady(updated)
+	}
+
+	return uc.reply(phoneNumber, tmplPharmacistStatusUpdated, prescriptionStatusData{
+		QueueNumber: updated.QueueNumber,
+		PatientName: updated.Details.PatientName,
+		Status:      status,
+	})
+}
+
+// notifyPatientReady tells the patient their medication can be picked up
+func (uc *messageUseCase) notifyPatientReady(prescription *utils.Prescription) {
+	patientPhone := prescription.Details.PatientPhoneNumber
+	if patientPhone == "" || patientPhone == "-" {
+		return
+	}
+
+	uc.reply(patientPhone, tmplPatientReady, prescriptionStatusData{
+		QueueNumber: prescription.QueueNumber,
+		PatientName: prescription.Details.PatientName,
+		Status:      prescription.Status,
+	})
+}
+
+// findTodayPrescription looks up today's prescription with the queue number
+func (uc *messageUseCase) findTodayPrescription(queueNumber int) *utils.Prescription {
+	prescription, ok := uc.prescriptions.FindByQueueNumber(utils.NowWIB(), uc.cfg.PharmacyNumber, queueNumber)
+	if !ok {
+		return nil
+	}
+	return prescription
+}
+
+// findQuotedPrescription finds the prescription of the order message the pharmacist replied to.
+// Only the linked message ID is trusted: queue numbers restart every day, so the number in the
+// quoted text could belong to another patient's prescription.
+func (uc *messageUseCase) findQuotedPrescription(message *MessageContent) *utils.Prescription {
+	prescription, ok := uc.prescriptions.FindByOrderMessageID(message.RepliedID)
+	if !ok {
+		return nil
+	}
+	return prescription
+}
+
+// openPrescriptions returns today's prescriptions that were not picked up yet
+func (uc *messageUseCase) openPrescriptions() []utils.Prescription {
+	today := utils.NowWIB().Format("2006-01-02")
+	return uc.prescriptions.List(func(p *utils.Prescription) bool {
+		return p.CreatedAt.In(utils.WIB).Format("2006-01-02") == today && p.Status != utils.StatusPickedUp
+	})
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+)
+
+// sentMessage is a message captured by recordingGateway
+type sentMessage struct {
+	ID          string
+	PhoneNumber string
+	Message     string
+}
+
+// recordingGateway keeps every message in memory instead of sending it, so the conversation
+// flow can be tested without a WhatsApp engine
+type recordingGateway struct {
+	mu       sync.Mutex
+	messages []sentMessage
+	// err, when set, is returned by Send and nothing is recorded
+	err error
+}
+
+func (g *recordingGateway) Send(ctx context.Context, phoneNumber, message string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return "", g.err
+	}
+
+	id := fmt.Sprintf("recorded-%d", len(g.messages)+1)
+	g.messages = append(g.messages, sentMessage{ID: id, PhoneNumber: phoneNumber, Message: message})
+	return id, nil
+}
+
+// SendAndWait records the message and reports it as sent right away.
+func (g *recordingGateway) SendAndWait(ctx context.Context, phoneNumber, message string) (*utils.Delivery, error) {
+	id, err := g.Send(ctx, phoneNumber, message)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.Delivery{OutboxID: id, MessageID: id, Status: utils.DeliverySent}, nil
+}
+
+// SendLinked records the message after link got its ID, like the outbox does.
+func (g *recordingGateway) SendLinked(ctx context.Context, phoneNumber, message string, link func(id string)) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return "", g.err
+	}
+
+	id := fmt.Sprintf("recorded-%d", len(g.messages)+1)
+	link(id)
+	g.messages = append(g.messages, sentMessage{ID: id, PhoneNumber: phoneNumber, Message: message})
+	return id, nil
+}
+
+// Messages returns a copy of the recorded messages sent to phoneNumber.
+func (g *recordingGateway) Messages(phoneNumber string) []sentMessage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var messages []sentMessage
+	for _, m := range g.messages {
+		if m.PhoneNumber == phoneNumber {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// Reset clears the recorded messages.
+func (g *recordingGateway) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.messages = nil
+}
+
+// setErr makes every following Send fail with err, nil sends again.
+func (g *recordingGateway) setErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.err = err
+}
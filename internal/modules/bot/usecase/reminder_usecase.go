@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"time"
+)
+
+type ReminderUseCase interface {
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// reminderUseCase reminds patients whose medication is ready but not picked up yet
+type reminderUseCase struct {
+	prescriptions *utils.PrescriptionStore
+	gateway       utils.MessageGateway
+	templates     *utils.MessageTemplates
+	locales       *utils.LocaleStore
+	after         time.Duration
+	now           func() time.Time
+}
+
+func NewReminderUseCase(prescriptions *utils.PrescriptionStore, gateway utils.MessageGateway, templates *utils.MessageTemplates, locales *utils.LocaleStore, after time.Duration) ReminderUseCase {
+	return &reminderUseCase{
+		prescriptions: prescriptions,
+		gateway:       gateway,
+		templates:     templates,
+		locales:       locales,
+		after:         after,
+		now:           time.Now,
+	}
+}
+
+// Run checks for overdue pickups until ctx is cancelled
+func (uc *reminderUseCase) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.sendReminders(ctx)
+		}
+	}
+}
+
+// sendReminders sends one reminder per prescription that has been ready for longer than the configured time
+func (uc *reminderUseCase) sendReminders(ctx context.Context) {
+	deadline := uc.now().Add(-uc.after)
+	overdue := uc.prescriptions.List(func(p *utils.Prescription) bool {
+		return p.Status == utils.StatusReady &&
+			p.ReminderSentAt == nil &&
+			p.StatusUpdatedAt.Before(deadline) &&
+			p.Details.PatientPhoneNumber != "" &&
+			p.Details.PatientPhoneNumber != "-"
+	})
+
+	for _, prescription := range overdue {
+		locale := uc.locales.Get(prescription.Details.PatientPhoneNumber)
+		msgToPatient, err := uc.templates.Render(locale, tmplPatientReminder, reminderData{
+			PatientName: prescription.Details.PatientName,
+			QueueNumber: prescription.QueueNumber,
+			ReadySince:  prescription.StatusUpdatedAt.In(utils.WIB).Format("15:04"),
+		})
+		if err != nil {
+			log.Printf("Unable to remind patient of prescription %s: %v", prescription.ID, err)
+			continue
+		}
+		if _, err := uc.gateway.Send(ctx, prescription.Details.PatientPhoneNumber, msgToPatient); err != nil {
+			log.Printf("Unable to remind patient of prescription %s: %v", prescription.ID, err)
+			continue
+		}
+		if err := uc.prescriptions.MarkReminderSent(prescription.ID); err != nil {
+			log.Printf("Unable to mark reminder of prescription %s: %v", prescription.ID, err)
+		}
+	}
+}
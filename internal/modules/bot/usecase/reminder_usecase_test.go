@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestReminders reminds the patients of the bot's prescriptions 30 minutes after they are ready,
+// at the time *now points to
+func newTestReminders(bot *testBot, now *time.Time) *reminderUseCase {
+	uc := NewReminderUseCase(bot.prescriptions, bot.gateway, bot.templates, bot.locales, 30*time.Minute).(*reminderUseCase)
+	uc.now = func() time.Time { return *now }
+	return uc
+}
+
+func TestReminderIsSentOnceAfterTheConfiguredTime(t *testing.T) {
+	bot := newTestBot(t)
+	bot.sendTestOrder(t)
+	bot.mustReceive(t, testPharmacy, "siap 1")
+	bot.gateway.Reset()
+
+	now := time.Now()
+	reminders := newTestReminders(bot, &now)
+
+	now = now.Add(29 * time.Minute)
+	reminders.sendReminders(context.Background())
+	if messages := bot.gateway.Messages(testPatient); len(messages) != 0 {
+		t.Fatalf("patient got %d reminders before the configured time", len(messages))
+	}
+
+	now = now.Add(2 * time.Minute)
+	reminders.sendReminders(context.Background())
+	now = now.Add(time.Hour)
+	reminders.sendReminders(context.Background())
+
+	messages := bot.gateway.Messages(testPatient)
+	if len(messages) != 1 {
+		t.Fatalf("patient got %d reminders, want 1", len(messages))
+	}
+	assertContains(t, messages[0].Message, "Siti Aminah", "nomor antrian 1 sudah siap sejak", "belum diambil")
+}
+
+func TestReminderIsNotSentOncePickedUp(t *testing.T) {
+	bot := newTestBot(t)
+	bot.sendTestOrder(t)
+	bot.mustReceive(t, testPharmacy, "siap 1")
+	bot.mustReceive(t, testPharmacy, "diambil 1")
+	bot.gateway.Reset()
+
+	now := time.Now().Add(time.Hour)
+	newTestReminders(bot, &now).sendReminders(context.Background())
+	if messages := bot.gateway.Messages(testPatient); len(messages) != 0 {
+		t.Fatalf("patient got %d reminders for a picked up prescription", len(messages))
+	}
+}
+
+func TestReminderSkipsPatientWithoutPhoneNumber(t *testing.T) {
+	bot := newTestBot(t)
+	bot.mustReceive(t, testDoctor, "/start")
+	bot.mustReceive(t, testDoctor, "1")
+	bot.mustReceive(t, testDoctor, strings.Replace(testForm, "081234567890", "-", 1))
+	bot.mustReceive(t, testDoctor, "Y")
+	bot.mustReceive(t, testPharmacy, "siap 1")
+	bot.gateway.Reset()
+
+	now := time.Now().Add(time.Hour)
+	newTestReminders(bot, &now).sendReminders(context.Background())
+	if messages := bot.gateway.Messages("-"); len(messages) != 0 {
+		t.Fatalf("%d reminders were sent to -", len(messages))
+	}
+}
@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/app/exception"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"time"
+)
+
+// Names of the message templates in the templates directory
+const (
+	tmplDoctorWelcome          = "doctor.welcome"
+	tmplDoctorForm             = "doctor.form"
+	tmplDoctorFormError        = "doctor.form_error"
+	tmplDoctorFormRetry        = "doctor.form_retry"
+	tmplDoctorSheetLink        = "doctor.sheet_link"
+	tmplDoctorSessionDone      = "doctor.session_done"
+	tmplDoctorCancelled        = "doctor.cancelled"
+	tmplDoctorBackToMenu       = "doctor.back_to_menu"
+	tmplDoctorConfirm          = "doctor.confirm"
+	tmplDoctorOverrideRequired = "doctor.override_required"
+	tmplDoctorQueueFailed      = "doctor.queue_failed"
+	tmplDoctorPharmacyFailed   = "doctor.pharmacy_failed"
+	tmplDoctorOrderSent        = "doctor.order_sent"
+	tmplDoctorOrderFailed      = "doctor.order_failed"
+
+	tmplPharmacyOrder            = "pharmacy.order"
+	tmplPharmacistHelp           = "pharmacist.help"
+	tmplPharmacistCancelled      = "pharmacist.cancelled"
+	tmplPharmacistQueueNotNumber = "pharmacist.queue_not_number"
+	tmplPharmacistAskQueue       = "pharmacist.ask_queue"
+	tmplPharmacistNotFound       = "pharmacist.not_found"
+	tmplPharmacistStatusRejected = "pharmacist.status_rejected"
+	tmplPharmacistStatusUpdated  = "pharmacist.status_updated"
+	tmplPharmacistOpenList       = "pharmacist.open_list"
+	tmplPatientOrderSent         = "patient.order_sent"
+	tmplPatientReady             = "patient.ready"
+	tmplPatientReminder          = "patient.reminder"
+	tmplPatientHelp              = "patient.help"
+	tmplPatientNoPrescription    = "patient.no_prescription"
+	tmplPatientQueueStatus       = "patient.queue_status"
+	tmplAdminHelp                = "admin.help"
+	tmplAdminDoctorList          = "admin.doctor_list"
+	tmplAdminAddUsage            = "admin.add_usage"
+	tmplAdminAddFailed           = "admin.add_failed"
+	tmplAdminDoctorAdded         = "admin.doctor_added"
+	tmplAdminRemoveUsage         = "admin.remove_usage"
+	tmplAdminNotDoctor           = "admin.not_doctor"
+	tmplAdminRemoveFailed        = "admin.remove_failed"
+	tmplAdminDoctorRemoved       = "admin.doctor_removed"
+	tmplLangUsage                = "lang.usage"
+	tmplLangUnknown              = "lang.unknown"
+	tmplLangChanged              = "lang.changed"
+	tmplInventoryDisabled        = "inventory.disabled"
+	tmplInventoryRestockUsage    = "inventory.restock_usage"
+	tmplInventoryRestockFailed   = "inventory.restock_failed"
+	tmplInventoryRestocked       = "inventory.restocked"
+	tmplAllergyUsage             = "allergy.usage"
+	tmplAllergyList              = "allergy.list"
+	tmplAllergyFailed            = "allergy.failed"
+)
+
+// Data passed to the message templates
+type (
+	sheetLinkData struct {
+		SheetLink string
+	}
+
+	confirmData struct {
+		Message            string
+		Prefilled          []utils.PatientField    // Patient data the form left out, taken from the registry
+		Mismatches         []utils.PatientMismatch // Typed patient data that disagrees with the registry
+		AllergyWarnings    []utils.AllergyWarning
+		StockWarnings      []utils.StockWarning
+		MedicationWarnings []utils.MedicationWarning
+		OverrideRequired   bool // An allergy or a severe interaction is only sent with OverrideKeyword
+		OverrideKeyword    string
+	}
+
+	orderData struct {
+		Details            utils.PatientDetails
+		QueueNumber        int
+		AllergyWarnings    []utils.AllergyWarning
+		MedicationWarnings []utils.MedicationWarning
+	}
+
+	allergyData struct {
+		RegistryNum string
+		Allergies   []utils.Allergy
+	}
+
+	orderSentData struct {
+		SinkResults []utils.SinkResult
+	}
+
+	prescriptionStatusData struct {
+		QueueNumber int
+		PatientName string
+		Status      string
+	}
+
+	openPrescriptionsData struct {
+		Prescriptions []utils.Prescription
+	}
+
+	reminderData struct {
+		PatientName string
+		QueueNumber int
+		ReadySince  string
+	}
+
+	queuePosition struct {
+		QueueNumber int
+		Status      string
+		Ahead       int // Open prescriptions before this one
+	}
+
+	queueStatusData struct {
+		Positions []queuePosition
+	}
+
+	doctorListData struct {
+		Doctors []utils.Contact
+	}
+
+	contactData struct {
+		Contact utils.Contact
+	}
+
+	errorData struct {
+		Error string
+	}
+
+	formErrorsData struct {
+		Errors exception.ValidationErrors
+	}
+
+	stockData struct {
+		Item  utils.StockItem
+		Added int
+	}
+
+	localeData struct {
+		Locale  string
+		Locales []string
+	}
+)
+
+// MessageTemplateSamples returns sample data for every template the bot sends.
+// It is used to check the template files when they are loaded.
+func MessageTemplateSamples() map[string]any {
+	details := utils.PatientDetails{
+		DoctorName:         "Budi",
+		PatientName:        "Siti Aminah",
+		PatientBirthDate:   "01-02-1990",
+		RegistryNum:        "RM-001",
+		Medication:         "Paracetamol 500mg 3x1",
+		PatientPhoneNumber: "6281234567890",
+		PaymentMethod:      "BPJS",
+	}
+	doctor := utils.Contact{PhoneNumber: "6281111111111", Name: "Budi", Role: utils.RoleDoctor, Active: true}
+	prescription := utils.Prescription{
+		PrescriptionRecord: *utils.NewPrescriptionRecord(&details, "6289999999999", 7, time.Date(2025, 1, 2, 9, 30, 0, 0, utils.WIB)),
+		Status:             utils.StatusPreparing,
+	}
+
+	confirm := confirmData{
+		Message: "Nama Dokter: Budi",
+		Prefilled: []utils.PatientField{
+			{Field: utils.FormPatientBirthDate, Value: "01-02-1990"},
+			{Field: utils.FormPatientPhoneNumber, Value: "6281234567890"},
+		},
+		Mismatches:      []utils.PatientMismatch{{Field: utils.FormPatientName, Typed: "Siti Amina", Stored: "Siti Aminah"}},
+		AllergyWarnings: []utils.AllergyWarning{{Drug: "Amoxicillin", Allergy: "Penicillin"}},
+		StockWarnings: []utils.StockWarning{
+			{Name: "Amoxicillin 500 mg", Available: 12, Needed: 15, Out: true},
+			{Name: "Paracetamol 500 mg", Available: 20, Needed: 10},
+		},
+		MedicationWarnings: []utils.MedicationWarning{
+			{Kind: utils.WarningInteraction, Drugs: []string{"Simvastatin", "Clarithromycin"}, Severity: utils.SeveritySevere, Description: "risiko rhabdomyolysis"},
+			{Kind: utils.WarningInteraction, Drugs: []string{"Amoxicillin", "Allopurinol"}, Severity: utils.SeverityMinor},
+			{Kind: utils.WarningDuplicate, Drugs: []string{"Sanmol", "Paracetamol"}, Ingredient: "Paracetamol"},
+		},
+		OverrideRequired: true,
+		OverrideKeyword:  utils.OverrideKeyword,
+	}
+
+	return map[string]any{
+		tmplDoctorWelcome:          nil,
+		tmplDoctorForm:             nil,
+		tmplDoctorFormError:        nil,
+		tmplDoctorFormRetry:        nil,
+		tmplDoctorSheetLink:        sheetLinkData{SheetLink: "https://docs.google.com/spreadsheets/d/example"},
+		tmplDoctorSessionDone:      nil,
+		tmplDoctorCancelled:        nil,
+		tmplDoctorBackToMenu:       nil,
+		tmplDoctorConfirm:          confirm,
+		tmplDoctorOverrideRequired: confirm,
+		tmplDoctorQueueFailed:      nil,
+		tmplDoctorPharmacyFailed:   nil,
+		tmplDoctorOrderSent: orderSentData{SinkResults: []utils.SinkResult{
+			{Sink: "Excel", Success: true},
+			{Sink: "Google Sheets", Success: true, Queued: true},
+			{Sink: "CSV", Error: "disk full"},
+		}},
+		tmplDoctorOrderFailed: prescriptionStatusData{QueueNumber: 7, PatientName: details.PatientName, Status: utils.StatusReceived},
+
+		tmplPharmacyOrder: orderData{
+			Details:            details,
+			QueueNumber:        7,
+			AllergyWarnings:    confirm.AllergyWarnings,
+			MedicationWarnings: confirm.MedicationWarnings,
+		},
+		tmplPharmacistHelp:           nil,
+		tmplPharmacistCancelled:      nil,
+		tmplPharmacistQueueNotNumber: nil,
+		tmplPharmacistAskQueue:       nil,
+		tmplPharmacistNotFound:       nil,
+		tmplPharmacistStatusRejected: prescriptionStatusData{QueueNumber: 7, PatientName: details.PatientName, Status: utils.StatusReady},
+		tmplPharmacistStatusUpdated:  prescriptionStatusData{QueueNumber: 7, PatientName: details.PatientName, Status: utils.StatusReady},
+		tmplPharmacistOpenList:       openPrescriptionsData{Prescriptions: []utils.Prescription{prescription}},
+
+		tmplPatientOrderSent:      orderData{Details: details, QueueNumber: 7},
+		tmplPatientReady:          prescriptionStatusData{QueueNumber: 7, PatientName: details.PatientName, Status: utils.StatusReady},
+		tmplPatientReminder:       reminderData{PatientName: details.PatientName, QueueNumber: 7, ReadySince: "10:15"},
+		tmplPatientHelp:           nil,
+		tmplPatientNoPrescription: nil,
+		tmplPatientQueueStatus: queueStatusData{Positions: []queuePosition{
+			{QueueNumber: 7, Status: utils.StatusPreparing, Ahead: 2},
+			{QueueNumber: 9, Status: utils.StatusReady},
+		}},
+
+		tmplAdminHelp:          nil,
+		tmplAdminDoctorList:    doctorListData{Doctors: []utils.Contact{doctor}},
+		tmplAdminAddUsage:      nil,
+		tmplAdminAddFailed:     errorData{Error: "invalid phone number"},
+		tmplAdminDoctorAdded:   contactData{Contact: doctor},
+		tmplAdminRemoveUsage:   nil,
+		tmplAdminNotDoctor:     nil,
+		tmplAdminRemoveFailed:  errorData{Error: "contact not found"},
+		tmplAdminDoctorRemoved: contactData{Contact: doctor},
+
+		tmplLangUsage:   localeData{Locale: "id", Locales: []string{"en", "id"}},
+		tmplLangUnknown: localeData{Locale: "fr", Locales: []string{"en", "id"}},
+		tmplLangChanged: localeData{Locale: "en", Locales: []string{"en", "id"}},
+
+		tmplInventoryDisabled:      nil,
+		tmplInventoryRestockUsage:  nil,
+		tmplInventoryRestockFailed: errorData{Error: "Amoxilin is not in the formulary, did you mean Amoxicillin"},
+		tmplInventoryRestocked:     stockData{Item: utils.StockItem{Generic: "Amoxicillin", Strength: "500 mg", Quantity: 112}, Added: 100},
+
+		tmplAllergyUsage:  nil,
+		tmplAllergyList:   allergyData{RegistryNum: "RM-001", Allergies: []utils.Allergy{{Name: "Penicillin"}, {Name: "Sulfa"}}},
+		tmplAllergyFailed: errorData{Error: "Allergy not found"},
+
+		utils.ValidationStartRequired: formErrorsData{},
+		utils.ValidationMenuChoice:    formErrorsData{},
+		utils.ValidationFormFormat: formErrorsData{Errors: exception.ValidationErrors{
+			{FailedField: utils.FormPatientName, Tag: utils.TagRequired},
+			{FailedField: utils.FormPatientBirthDate, Tag: utils.TagDate, Value: "31-02-1990"},
+			{FailedField: utils.FormPatientPhoneNumber, Tag: utils.TagPhone, Value: "12345"},
+			{FailedField: utils.FormMedication, Tag: utils.TagQuantity, Value: "IIII"},
+			{FailedField: utils.FormMedication, Tag: utils.TagFormulary, Value: "Amoxilin", Suggestions: []string{"Amoxicillin"}},
+			{FailedField: utils.FormMedication, Tag: utils.TagStrength, Value: "Amoxicillin 750 mg", Suggestions: []string{"250 mg", "500 mg"}},
+			{FailedField: "Catatan", Tag: utils.TagUnknown, Value: "Catatan: tolong cepat"},
+		}},
+		utils.ValidationConfirmation: formErrorsData{},
+		utils.ValidationUnknownState: formErrorsData{},
+	}
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"testing"
+)
+
+// templateNames lists every message template the bot renders, a new template belongs here too
+var templateNames = []string{
+	tmplDoctorWelcome, tmplDoctorForm, tmplDoctorFormError, tmplDoctorFormRetry, tmplDoctorSheetLink,
+	tmplDoctorSessionDone, tmplDoctorCancelled, tmplDoctorBackToMenu, tmplDoctorConfirm, tmplDoctorOverrideRequired,
+	tmplDoctorQueueFailed, tmplDoctorPharmacyFailed, tmplDoctorOrderSent, tmplDoctorOrderFailed,
+	tmplPharmacyOrder, tmplPharmacistHelp, tmplPharmacistCancelled, tmplPharmacistQueueNotNumber, tmplPharmacistAskQueue,
+	tmplPharmacistNotFound, tmplPharmacistStatusRejected, tmplPharmacistStatusUpdated, tmplPharmacistOpenList,
+	tmplPatientOrderSent, tmplPatientReady, tmplPatientReminder, tmplPatientHelp, tmplPatientNoPrescription, tmplPatientQueueStatus,
+	tmplAdminHelp, tmplAdminDoctorList, tmplAdminAddUsage, tmplAdminAddFailed, tmplAdminDoctorAdded,
+	tmplAdminRemoveUsage, tmplAdminNotDoctor, tmplAdminRemoveFailed, tmplAdminDoctorRemoved,
+	tmplLangUsage, tmplLangUnknown, tmplLangChanged,
+	tmplInventoryDisabled, tmplInventoryRestockUsage, tmplInventoryRestockFailed, tmplInventoryRestocked,
+	tmplAllergyUsage, tmplAllergyList, tmplAllergyFailed,
+	utils.ValidationStartRequired, utils.ValidationMenuChoice, utils.ValidationFormFormat,
+	utils.ValidationConfirmation, utils.ValidationUnknownState,
+}
+
+func TestEveryTemplateHasASample(t *testing.T) {
+	samples := MessageTemplateSamples()
+
+	listed := make(map[string]bool, len(templateNames))
+	for _, name := range templateNames {
+		listed[name] = true
+		if _, ok := samples[name]; !ok {
+			t.Errorf("%q has no sample in MessageTemplateSamples", name)
+		}
+	}
+	for name := range samples {
+		if !listed[name] {
+			t.Errorf("%q has a sample but is missing from templateNames", name)
+		}
+	}
+}
+
+func TestTemplatesRenderInEveryLocale(t *testing.T) {
+	samples := MessageTemplateSamples()
+	templates, err := utils.LoadMessageTemplates("../../../../templates", "id", samples)
+	if err != nil {
+		t.Fatalf("LoadMessageTemplates: %v", err)
+	}
+
+	locales := templates.Locales()
+	if len(locales) < 2 {
+		t.Fatalf("locales = %v, want at least id and en", locales)
+	}
+	for _, locale := range locales {
+		for name, data := range samples {
+			message, err := templates.Render(locale, name, data)
+			if err != nil {
+				t.Errorf("%s: %v", locale, err)
+				continue
+			}
+			if strings.TrimSpace(message) == "" {
+				t.Errorf("%s: %q renders an empty message", locale, name)
+			}
+			if strings.Contains(message, "<no value>") {
+				t.Errorf("%s: %q renders a missing value:\n%s", locale, name, message)
+			}
+		}
+	}
+}
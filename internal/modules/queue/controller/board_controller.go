@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"telegram-doctor-recipe-helper-bot/internal/app/model"
+	"telegram-doctor-recipe-helper-bot/internal/modules/queue/usecase"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+//go:embed board.html
+var boardPage string
+
+// heartbeatInterval keeps proxies from closing an idle event stream
+const heartbeatInterval = 15 * time.Second
+
+type BoardController struct {
+	useCase usecase.BoardUseCase
+}
+
+func NewBoardController(useCase usecase.BoardUseCase) *BoardController {
+	return &BoardController{
+		useCase: useCase,
+	}
+}
+
+// Display page for the waiting room screen
+func (ctrl *BoardController) Page(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(boardPage)
+}
+
+// Current board as JSON
+func (ctrl *BoardController) Snapshot(c *fiber.Ctx) error {
+	return c.JSON(model.Response{
+		Code:    200,
+		Message: "Queue board",
+		Data:    ctrl.useCase.Snapshot(),
+	})
+}
+
+// Server-Sent Events stream that pushes the board after every change
+func (ctrl *BoardController) Events(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	updates, unsubscribe := ctrl.useCase.Subscribe()
+	initial := ctrl.useCase.Snapshot()
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		if writeBoardEvent(w, initial) != nil {
+			return
+		}
+
+		for {
+			select {
+			case board, ok := <-updates:
+				if !ok || writeBoardEvent(w, board) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				// A fresh board also rolls the screen over to the new day
+				if writeBoardEvent(w, ctrl.useCase.Snapshot()) != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// writeBoardEvent writes one SSE message, an error means the screen disconnected
+func writeBoardEvent(w *bufio.Writer, board *usecase.Board) error {
+	data, err := json.Marshal(board)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"telegram-doctor-recipe-helper-bot/internal/modules/queue/usecase"
+	"testing"
+)
+
+func TestBoardEventCarriesNoPatientData(t *testing.T) {
+	store, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	details := &utils.PatientDetails{
+		DoctorName:         "Dokter Budi",
+		PatientName:        "Siti Aminah",
+		PatientBirthDate:   "01-02-1990",
+		RegistryNum:        "RM-001",
+		PatientPhoneNumber: "6281234567890",
+		PaymentMethod:      "BPJS",
+		Medication:         "1. Amoxicillin 500 mg tablet, No. XV (15), S. 3dd1",
+		Medications:        []utils.MedicationItem{{Name: "Amoxicillin", Strength: "500 mg", Form: "tablet", Signa: "3dd1", Raw: "Amoxicillin 500mg tab no. XV 3dd1"}},
+	}
+	if _, err := store.Add(utils.NewPrescriptionRecord(details, "6289999999999", 1, utils.NowWIB()), "6289999999999", "6281111111111"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeBoardEvent(bufio.NewWriter(&buf), usecase.NewBoardUseCase(store).Snapshot()); err != nil {
+		t.Fatalf("writeBoardEvent: %v", err)
+	}
+
+	event := buf.String()
+	if !strings.HasPrefix(event, "data: {") || !strings.HasSuffix(event, "}\n\n") || !strings.Contains(event, `"initials":"S. A."`) {
+		t.Fatalf("event = %q", event)
+	}
+	for _, leaked := range []string{"Amoxicillin", "500 mg", "3dd1", "medication", "Siti", "Aminah", "RM-001", "6281234567890", "01-02-1990", "BPJS", "Budi"} {
+		if strings.Contains(event, leaked) {
+			t.Errorf("the board event contains %q: %s", leaked, event)
+		}
+	}
+}
+
+func TestBoardPageUsesAbsoluteEventsPath(t *testing.T) {
+	// The page is served at /v1/queue/board and /v1/queue/board/, a relative path breaks on one of them
+	if !strings.Contains(boardPage, `new EventSource("/v1/queue/board/events")`) {
+		t.Fatal("board.html does not open the event stream at /v1/queue/board/events")
+	}
+}
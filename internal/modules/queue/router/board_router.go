@@ -0,0 +1,15 @@
+package router
+
+import (
+	"telegram-doctor-recipe-helper-bot/internal/modules/queue/controller"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func Route(app *fiber.App, ctrl *controller.BoardController) {
+	queue := app.Group("/v1/queue")
+
+	queue.Get("/board", ctrl.Page)
+	queue.Get("/board/data", ctrl.Snapshot)
+	queue.Get("/board/events", ctrl.Events)
+}
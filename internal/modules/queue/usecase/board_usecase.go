@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"strings"
+	"sync"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"time"
+	"unicode"
+)
+
+// BoardEntry is one queue number on the display board. It never carries medication or full names.
+type BoardEntry struct {
+	QueueNumber int    `json:"queue_number"`
+	Initials    string `json:"initials"`
+}
+
+// Board is what the waiting room screen shows for today
+type Board struct {
+	Date      string       `json:"date"`
+	Ready     []BoardEntry `json:"ready"`
+	Preparing []BoardEntry `json:"preparing"`
+	Waiting   []BoardEntry `json:"waiting"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type BoardUseCase interface {
+	Snapshot() *Board
+	Subscribe() (<-chan *Board, func())
+}
+
+type boardUseCase struct {
+	prescriptions *utils.PrescriptionStore
+
+	mu          sync.Mutex
+	nextID      int
+	subscribers map[int]chan *Board
+}
+
+func NewBoardUseCase(prescriptions *utils.PrescriptionStore) BoardUseCase {
+	uc := &boardUseCase{
+		prescriptions: prescriptions,
+		subscribers:   make(map[int]chan *Board),
+	}
+
+	// Every new prescription or status change is pushed to the screens
+	prescriptions.OnChange(func(*utils.Prescription) {
+		uc.broadcast()
+	})
+	return uc
+}
+
+// Snapshot builds the board from today's prescriptions
+func (uc *boardUseCase) Snapshot() *Board {
+	now := utils.NowWIB()
+	today := now.Format("2006-01-02")
+
+	board := &Board{
+		Date:      today,
+		Ready:     []BoardEntry{},
+		Preparing: []BoardEntry{},
+		Waiting:   []BoardEntry{},
+		UpdatedAt: now,
+	}
+
+	prescriptions := uc.prescriptions.List(func(p *utils.Prescription) bool {
+		return p.CreatedAt.In(utils.WIB).Format("2006-01-02") == today
+	})
+	for _, p := range prescriptions {
+		entry := BoardEntry{QueueNumber: p.QueueNumber, Initials: MaskInitials(p.Details.PatientName)}
+		switch p.Status {
+		case utils.StatusReady:
+			board.Ready = append(board.Ready, entry)
+		case utils.StatusPreparing:
+			board.Preparing = append(board.Preparing, entry)
+		case utils.StatusReceived:
+			board.Waiting = append(board.Waiting, entry)
+		}
+	}
+	return board
+}
+
+// Subscribe returns a channel that receives the board after every change, and a function to stop.
+func (uc *boardUseCase) Subscribe() (<-chan *Board, func()) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	id := uc.nextID
+	uc.nextID++
+	ch := make(chan *Board, 1)
+	uc.subscribers[id] = ch
+
+	unsubscribe := func() {
+		uc.mu.Lock()
+		defer uc.mu.Unlock()
+
+		if _, ok := uc.subscribers[id]; ok {
+			delete(uc.subscribers, id)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// broadcast sends the latest board to every subscriber, a slow screen only misses intermediate boards
+func (uc *boardUseCase) broadcast() {
+	board := uc.Snapshot()
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	for _, ch := range uc.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- board
+	}
+}
+
+// MaskInitials turns "Budi Santoso" into "B. S." so patients can recognise themselves without exposing names
+func MaskInitials(name string) string {
+	var initials []string
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) {
+				initials = append(initials, string(unicode.ToUpper(r))+".")
+				break
+			}
+		}
+		if len(initials) == 3 {
+			break
+		}
+	}
+	return strings.Join(initials, " ")
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"fmt"
+	"telegram-doctor-recipe-helper-bot/internal/app/utils"
+	"testing"
+	"time"
+)
+
+const testPharmacy = "6289999999999"
+
+// addTestPrescription adds a prescription created at createdAt and moves it to status
+func addTestPrescription(t *testing.T, store *utils.PrescriptionStore, queueNumber int, name string, createdAt time.Time, status string) {
+	t.Helper()
+
+	details := &utils.PatientDetails{
+		PatientName:        name,
+		RegistryNum:        fmt.Sprintf("RM-%03d", queueNumber),
+		PatientPhoneNumber: "6281234567890",
+		Medication:         "1. Amoxicillin 500 mg",
+		Medications:        []utils.MedicationItem{{Name: "Amoxicillin", Strength: "500 mg"}},
+	}
+	prescription, err := store.Add(utils.NewPrescriptionRecord(details, testPharmacy, queueNumber, createdAt), testPharmacy, "6281111111111")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if status != utils.StatusReceived {
+		if _, err := store.UpdateStatus(prescription.ID, status, testPharmacy); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+	}
+}
+
+func TestMaskInitials(t *testing.T) {
+	tests := map[string]string{
+		"Budi Santoso":               "B. S.",
+		"siti":                       "S.",
+		"  Ni  Made   Ayu Lestari  ": "N. M. A.",
+		"'Abdul (Udin) Rahman":       "A. U. R.",
+		"":                           "",
+		"123 ---":                    "",
+	}
+
+	for name, want := range tests {
+		if got := MaskInitials(name); got != want {
+			t.Errorf("MaskInitials(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBoardSnapshot(t *testing.T) {
+	store, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	now := utils.NowWIB()
+	addTestPrescription(t, store, 1, "Budi Santoso", now, utils.StatusReady)
+	addTestPrescription(t, store, 2, "Siti Aminah", now, utils.StatusPreparing)
+	addTestPrescription(t, store, 3, "Ani", now, utils.StatusReceived)
+	addTestPrescription(t, store, 4, "Dewi", now, utils.StatusPickedUp)
+	// Yesterday's prescriptions are not on today's board
+	addTestPrescription(t, store, 1, "Joko", now.AddDate(0, 0, -1), utils.StatusReady)
+
+	board := NewBoardUseCase(store).Snapshot()
+
+	if board.Date != now.Format("2006-01-02") {
+		t.Fatalf("date = %s", board.Date)
+	}
+	check := func(column string, entries []BoardEntry, want ...BoardEntry) {
+		t.Helper()
+		if len(entries) != len(want) {
+			t.Fatalf("%s = %+v, want %+v", column, entries, want)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Fatalf("%s = %+v, want %+v", column, entries, want)
+			}
+		}
+	}
+	check("ready", board.Ready, BoardEntry{QueueNumber: 1, Initials: "B. S."})
+	check("preparing", board.Preparing, BoardEntry{QueueNumber: 2, Initials: "S. A."})
+	check("waiting", board.Waiting, BoardEntry{QueueNumber: 3, Initials: "A."})
+}
+
+func TestBoardBroadcastsChanges(t *testing.T) {
+	store, err := utils.NewPrescriptionStore("", 0)
+	if err != nil {
+		t.Fatalf("NewPrescriptionStore: %v", err)
+	}
+	board := NewBoardUseCase(store)
+	updates, unsubscribe := board.Subscribe()
+
+	addTestPrescription(t, store, 1, "Budi Santoso", utils.NowWIB(), utils.StatusReceived)
+	addTestPrescription(t, store, 2, "Siti Aminah", utils.NowWIB(), utils.StatusReceived)
+
+	// A screen that did not read yet only gets the latest board
+	select {
+	case latest := <-updates:
+		if len(latest.Waiting) != 2 {
+			t.Fatalf("waiting = %+v, want both prescriptions", latest.Waiting)
+		}
+	default:
+		t.Fatal("no board was pushed after a change")
+	}
+
+	unsubscribe()
+	if _, ok := <-updates; ok {
+		t.Fatal("the channel is open after unsubscribe")
+	}
+	// Changes after unsubscribing do not block or panic
+	addTestPrescription(t, store, 3, "Ani", utils.NowWIB(), utils.StatusReceived)
+}